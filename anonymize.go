@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// anonymizeConfig is the anonymize section of the configuration file
+type anonymizeConfig struct {
+	// Terms identify the company, like organization names, domains and
+	// internal hostnames, matched regardless of case
+	Terms []string `yaml:"terms"`
+
+	// HashDirectories replaces the name of every directory in paths
+	HashDirectories bool `yaml:"hash-directories"`
+}
+
+// anonymizer rewrites what identifies the company behind the code with
+// --anonymize: every term becomes a pseudonym derived from it, so the same
+// term always reads the same, and directory names are optionally hashed
+// too. A nil anonymizer leaves everything as is.
+type anonymizer struct {
+	terms    *regexp.Regexp
+	hashDirs bool
+}
+
+// newAnonymizer returns an anonymizer replacing terms and, when hashDirs
+// is set, hashing directory names
+func newAnonymizer(terms []string, hashDirs bool) *anonymizer {
+	a := &anonymizer{hashDirs: hashDirs}
+
+	// Match longer terms first, so a hostname is replaced as a whole
+	// rather than just the domain it ends with
+	terms = slices.DeleteFunc(slices.Clone(terms), func(t string) bool { return strings.TrimSpace(t) == "" })
+	slices.SortFunc(terms, func(a, b string) int { return len(b) - len(a) })
+
+	if len(terms) > 0 {
+		quoted := make([]string, len(terms))
+		for i, t := range terms {
+			quoted[i] = regexp.QuoteMeta(t)
+		}
+
+		a.terms = regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+	}
+
+	return a
+}
+
+// pseudonym returns a short name standing for s, the same every time
+func pseudonym(prefix, s string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(s)))
+	return prefix + hex.EncodeToString(sum[:4])
+}
+
+// text replaces the terms found in s
+func (a *anonymizer) text(s string) string {
+	if a == nil || a.terms == nil {
+		return s
+	}
+
+	return a.terms.ReplaceAllStringFunc(s, func(term string) string {
+		return pseudonym("anon-", term)
+	})
+}
+
+// path replaces the terms found in path and, with hashed directories,
+// the name of every directory in it, keeping the file name when isDir is
+// false
+func (a *anonymizer) path(path string, isDir bool) string {
+	if a == nil {
+		return path
+	}
+
+	if !a.hashDirs {
+		return a.text(path)
+	}
+
+	parts := strings.FieldsFunc(path, isPathSeparator)
+	last := len(parts)
+	if !isDir {
+		last--
+	}
+
+	// Rebuild the path keeping its separators, hashing the names between
+	var b strings.Builder
+	part := 0
+	for i := 0; i < len(path); {
+		if isPathSeparator(rune(path[i])) {
+			b.WriteByte(path[i])
+			i++
+			continue
+		}
+
+		end := strings.IndexFunc(path[i:], isPathSeparator)
+		if end < 0 {
+			end = len(path)
+		} else {
+			end += i
+		}
+
+		name := path[i:end]
+		switch {
+		case name == "." || name == "..":
+			b.WriteString(name)
+		case part < last:
+			b.WriteString(pseudonym("dir-", name))
+		default:
+			b.WriteString(a.text(name))
+		}
+
+		part++
+		i = end
+	}
+
+	return b.String()
+}
+
+// isPathSeparator reports whether r separates the names in a path, in
+// either style
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+// anonymize rewrites what identifies the company in the file f and the
+// information about it
+func (a *anonymizer) anonymize(f *contextFile) {
+	if a == nil {
+		return
+	}
+
+	f.Content = a.text(f.Content)
+	f.Link = a.text(f.Link)
+
+	if f.Git != nil {
+		git := *f.Git
+		git.Author = a.text(git.Author)
+		f.Git = &git
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+
+	"github.com/patrickdappollonio/context-generator/internal/archive"
+)
+
+// isArchiveFile reports whether path is a file that looks like a
+// supported archive, to be read in place of a directory
+func isArchiveFile(path string) bool {
+	if !archive.IsArchive(path) {
+		return false
+	}
+
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// useArchive makes the run read the archive at path in place of a
+// directory, without extracting it. Paths in the context start with the
+// path of the archive.
+func (o *options) useArchive(path string) error {
+	fsys, err := archive.Open(path)
+	if err != nil {
+		return err
+	}
+
+	o.root = path
+	o.filters.FS = fsys
+
+	// Archives don't change, so there's nothing to cache, and they aren't
+	// a checkout of a repository
+	o.useCache = false
+	o.gitMetadata = false
+	o.withGitInfo = false
+
+	return nil
+}
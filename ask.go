@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// LLM APIs the ask command can send questions to
+const (
+	providerOpenAI    = "openai"
+	providerAnthropic = "anthropic"
+)
+
+// askSystemPrompt tells the model how to use the context it's given
+const askSystemPrompt = "You are answering questions about a code base, whose files are given below, each one under a header with its path. " +
+	"Base your answer on the code, quoting the files and the lines it refers to, and say so when the files don't hold enough to answer."
+
+// providerFlag is the value of the --provider flag. It implements
+// pflag.Value so unknown providers are rejected while parsing flags.
+type providerFlag string
+
+func (p *providerFlag) String() string {
+	return string(*p)
+}
+
+func (p *providerFlag) Set(value string) error {
+	switch value {
+	case providerOpenAI, providerAnthropic:
+		*p = providerFlag(value)
+		return nil
+	}
+
+	return fmt.Errorf("must be one of: %s, %s", providerOpenAI, providerAnthropic)
+}
+
+func (p *providerFlag) Type() string {
+	return "provider"
+}
+
+// chatClient streams answers from an OpenAI-compatible chat completions
+// API or the Anthropic messages API
+type chatClient struct {
+	http      *http.Client
+	provider  providerFlag
+	baseURL   string
+	apiKey    string
+	model     string
+	maxTokens int
+}
+
+// newChatClient returns a client for provider configured from the
+// environment: OPENAI_BASE_URL, OPENAI_API_KEY and OPENAI_MODEL, or
+// ANTHROPIC_BASE_URL, ANTHROPIC_API_KEY and ANTHROPIC_MODEL. Without a
+// provider, Anthropic is used when only its key is set.
+func newChatClient(provider providerFlag, model string, maxTokens int) (*chatClient, error) {
+	if provider == "" {
+		provider = providerOpenAI
+		if os.Getenv("OPENAI_API_KEY") == "" && os.Getenv("ANTHROPIC_API_KEY") != "" {
+			provider = providerAnthropic
+		}
+	}
+
+	c := &chatClient{http: http.DefaultClient, provider: provider, maxTokens: maxTokens}
+
+	switch provider {
+	case providerAnthropic:
+		c.baseURL = strings.TrimSuffix(envOr("https://api.anthropic.com", "ANTHROPIC_BASE_URL"), "/")
+		c.apiKey = envOr("", "ANTHROPIC_API_KEY")
+		c.model = envOr("claude-3-5-sonnet-latest", "ANTHROPIC_MODEL")
+
+		if c.apiKey == "" {
+			return nil, fmt.Errorf("set ANTHROPIC_API_KEY to ask with the %s provider", providerAnthropic)
+		}
+	default:
+		c.baseURL = strings.TrimSuffix(envOr("https://api.openai.com/v1", "OPENAI_BASE_URL"), "/")
+		c.apiKey = envOr("", "OPENAI_API_KEY")
+		c.model = envOr("gpt-4o", "OPENAI_MODEL")
+
+		// Local servers often need no key, but the default endpoint does
+		if c.apiKey == "" && c.baseURL == "https://api.openai.com/v1" {
+			return nil, fmt.Errorf("set OPENAI_API_KEY to ask with the %s provider, or OPENAI_BASE_URL to use another endpoint", providerOpenAI)
+		}
+	}
+
+	if model != "" {
+		c.model = model
+	}
+
+	return c, nil
+}
+
+// request returns the streaming request asking question about the
+// context in content
+func (c *chatClient) request(ctx context.Context, content, question string) (*http.Request, error) {
+	prompt := content + "\n\nQuestion: " + question
+
+	var (
+		address string
+		body    map[string]any
+	)
+
+	if c.provider == providerAnthropic {
+		address = c.baseURL + "/v1/messages"
+		body = map[string]any{
+			"model":      c.model,
+			"max_tokens": c.maxTokens,
+			"stream":     true,
+			"system":     askSystemPrompt,
+			"messages":   []map[string]string{{"role": "user", "content": prompt}},
+		}
+	} else {
+		address = c.baseURL + "/chat/completions"
+		body = map[string]any{
+			"model":      c.model,
+			"max_tokens": c.maxTokens,
+			"stream":     true,
+			"messages": []map[string]string{
+				{"role": "system", "content": askSystemPrompt},
+				{"role": "user", "content": prompt},
+			},
+		}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request to %q: %w", address, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request to %q: %w", address, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.provider == providerAnthropic {
+		req.Header.Set("x-api-key", c.apiKey)
+		req.Header.Set("anthropic-version", "2023-06-01")
+	} else if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	return req, nil
+}
+
+// ask sends question about the context in content and writes the answer
+// to w as it's streamed back
+func (c *chatClient) ask(ctx context.Context, w io.Writer, content, question string) error {
+	req, err := c.request(ctx, content, question)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling the %s API: %w", c.provider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s API returned %s for %q: %s", c.provider, resp.Status, req.URL, strings.TrimSpace(string(msg)))
+	}
+
+	// Both APIs stream server-sent events, one JSON value per data line
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		data, found := strings.CutPrefix(scanner.Text(), "data:")
+		if !found {
+			continue
+		}
+
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		text, err := c.parseEvent([]byte(data))
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, text); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading the answer from the %s API: %w", c.provider, err)
+	}
+
+	// Answers rarely end with a newline, which the shell prompt needs
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// parseEvent returns the text an event of the stream adds to the answer
+func (c *chatClient) parseEvent(data []byte) (string, error) {
+	var event struct {
+		// Anthropic
+		Type  string `json:"type"`
+		Delta struct {
+			Text string `json:"text"`
+		} `json:"delta"`
+
+		// OpenAI
+		Choices []struct {
+			Delta struct {
+				Content string `json:"content"`
+			} `json:"delta"`
+		} `json:"choices"`
+
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(data, &event); err != nil {
+		return "", fmt.Errorf("error reading the answer from the %s API: %w", c.provider, err)
+	}
+
+	if event.Error != nil {
+		return "", fmt.Errorf("%s API failed while answering: %s", c.provider, event.Error.Message)
+	}
+
+	if c.provider == providerAnthropic {
+		if event.Type == "content_block_delta" {
+			return event.Delta.Text, nil
+		}
+
+		return "", nil
+	}
+
+	var b strings.Builder
+	for _, choice := range event.Choices {
+		b.WriteString(choice.Delta.Content)
+	}
+
+	return b.String(), nil
+}
+
+func newAskCommand(opts *options) *cobra.Command {
+	var (
+		provider  providerFlag
+		model     string
+		maxTokens int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "ask question [directory]",
+		Short: "Generate a context with the current filters and ask a question about it to an LLM, streaming the answer: an OpenAI-compatible API set with OPENAI_BASE_URL, OPENAI_API_KEY and OPENAI_MODEL, or Anthropic with ANTHROPIC_API_KEY and ANTHROPIC_MODEL",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			question := strings.TrimSpace(args[0])
+			if question == "" {
+				return fmt.Errorf("the question can't be empty")
+			}
+
+			if len(args) == 2 {
+				opts.root = args[1]
+			}
+
+			if maxTokens <= 0 {
+				return fmt.Errorf("flag --max-tokens must be 1 or more, got %d", maxTokens)
+			}
+
+			// Check the API is usable before scanning anything
+			client, err := newChatClient(provider, model, maxTokens)
+			if err != nil {
+				return err
+			}
+
+			// Models read Markdown code blocks best
+			var buf bytes.Buffer
+			opts.format = formatMarkdown
+			if err := run(cmd.Context(), *opts, &buf); err != nil {
+				return err
+			}
+
+			return client.ask(cmd.Context(), cmd.OutOrStdout(), buf.String(), question)
+		},
+	}
+
+	cmd.Flags().Var(&provider, "provider", "API to ask: "+providerOpenAI+", or any compatible one, or "+providerAnthropic+"; defaults to "+providerAnthropic+" when only ANTHROPIC_API_KEY is set, "+providerOpenAI+" otherwise")
+	cmd.Flags().StringVar(&model, "model", "", "model to ask, instead of OPENAI_MODEL or ANTHROPIC_MODEL")
+	cmd.Flags().IntVar(&maxTokens, "max-tokens", 4096, "how many tokens the answer can take at most")
+	_ = cmd.RegisterFlagCompletionFunc("provider", fixedCompletion(providerOpenAI, providerAnthropic))
+
+	return cmd
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// benchRun is what a single run of the bench subcommand measured
+type benchRun struct {
+	elapsed     time.Duration
+	files       int
+	size        int64
+	allocated   uint64
+	allocations uint64
+}
+
+// filesPerSecond and bytesPerSecond tell how fast the run went
+func (b benchRun) filesPerSecond() float64 {
+	return float64(b.files) / b.elapsed.Seconds()
+}
+
+func (b benchRun) bytesPerSecond() int64 {
+	return int64(float64(b.size) / b.elapsed.Seconds())
+}
+
+// benchOnce generates a context with opts, discarding it, and measures
+// how long it took and how much memory it allocated
+func benchOnce(cmd *cobra.Command, opts options) (benchRun, error) {
+	opts.summary = newScanSummary("")
+
+	// Start every run from a clean heap so they can be compared
+	runtime.GC()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	if err := run(cmd.Context(), opts, io.Discard); err != nil {
+		return benchRun{}, err
+	}
+
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	return benchRun{
+		elapsed:     elapsed,
+		files:       opts.summary.files,
+		size:        opts.summary.size,
+		allocated:   after.TotalAlloc - before.TotalAlloc,
+		allocations: after.Mallocs - before.Mallocs,
+	}, nil
+}
+
+// printBenchRuns writes a table with every run and their mean
+func printBenchRuns(w io.Writer, runs []benchRun) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "RUN\tTIME\tFILES\tFILES/S\tSIZE/S\tALLOCATED\tALLOCATIONS\t")
+
+	var mean benchRun
+	for i, r := range runs {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%.0f\t%s/s\t%s\t%d\t\n", i+1, r.elapsed.Round(time.Microsecond), r.files, r.filesPerSecond(), humanBytes(r.bytesPerSecond()), humanBytes(int64(r.allocated)), r.allocations)
+
+		mean.elapsed += r.elapsed
+		mean.files += r.files
+		mean.size += r.size
+		mean.allocated += r.allocated
+		mean.allocations += r.allocations
+	}
+
+	n := len(runs)
+	mean.elapsed /= time.Duration(n)
+	mean.files /= n
+	mean.size /= int64(n)
+	mean.allocated /= uint64(n)
+	mean.allocations /= uint64(n)
+
+	fmt.Fprintf(tw, "mean\t%s\t%d\t%.0f\t%s/s\t%s\t%d\t\n", mean.elapsed.Round(time.Microsecond), mean.files, mean.filesPerSecond(), humanBytes(mean.bytesPerSecond()), humanBytes(int64(mean.allocated)), mean.allocations)
+
+	return tw.Flush()
+}
+
+func newBenchCommand(opts *options) *cobra.Command {
+	var (
+		runs      int
+		withCache bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "bench [directory]",
+		Short: "Generate a context several times with the current filters, discarding it, and report how long each run took, how many files and bytes per second it read and how much memory it allocated",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				opts.root = args[0]
+			}
+
+			if runs <= 0 {
+				return fmt.Errorf("flag --runs must be 1 or more, got %d", runs)
+			}
+
+			// Every run reads every file unless the cache is being
+			// measured, and none of them is worth searching later
+			opts.useCache = withCache
+			opts.noIndex = true
+
+			results := make([]benchRun, 0, runs)
+			for range runs {
+				r, err := benchOnce(cmd, *opts)
+				if err != nil {
+					return err
+				}
+
+				results = append(results, r)
+			}
+
+			return printBenchRuns(cmd.OutOrStdout(), results)
+		},
+	}
+
+	cmd.Flags().IntVar(&runs, "runs", 5, "how many times to generate the context")
+	cmd.Flags().BoolVar(&withCache, "with-cache", false, "reuse the contents cached by previous runs, like a run with --cache does, instead of reading every file every time")
+
+	return cmd
+}
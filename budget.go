@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/patrickdappollonio/context-generator/filter"
+)
+
+// exitBudgetExceeded is the exit code used when a context goes over the
+// token budget set with --fail-over-tokens, so CI jobs can tell it apart
+// from other failures
+const exitBudgetExceeded = 3
+
+// budgetError is returned when a context goes over its token budget
+type budgetError struct {
+	tokens int64
+	limit  int64
+}
+
+func (e *budgetError) Error() string {
+	return fmt.Sprintf("context is estimated at %d tokens, over the limit of %d set with --fail-over-tokens", e.tokens, e.limit)
+}
+
+// Is tells errors.Is a budget error is filter.ErrBudgetExceeded
+func (e *budgetError) Is(target error) bool {
+	return target == filter.ErrBudgetExceeded
+}
+
+// ExitCode returns the code the process exits with
+func (e *budgetError) ExitCode() int {
+	return exitBudgetExceeded
+}
+
+// checkBudget returns an error when tokens go over the limit; a limit of
+// zero or less means there's no limit
+func checkBudget(tokens, limit int64) error {
+	if limit > 0 && tokens > limit {
+		return &budgetError{tokens: tokens, limit: limit}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/patrickdappollonio/context-generator/internal/store"
+	"github.com/spf13/cobra"
+)
+
+// cacheKeyPrefix starts the keys, inside the store, of cached file reads
+const cacheKeyPrefix = "content-"
+
+// contentCache keeps the contents of text files read by previous runs, so
+// unchanged files don't have to be read again. Files are looked up by
+// their path and only reused if their size and modification time are the
+// same, so each file is cached once no matter how often it changes. A nil
+// cache is valid and caches nothing.
+type contentCache struct {
+	store store.Store
+}
+
+// cachedFile is a file read, as saved in the cache
+type cachedFile struct {
+	Size    int64         `json:"size"`
+	ModTime int64         `json:"mod_time"`
+	Entry   manifestEntry `json:"entry"`
+	Content string        `json:"content"`
+}
+
+// openContentCache opens the cache kept in the store of the given backend
+func openContentCache(backend string) (*contentCache, error) {
+	st, err := openStore(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contentCache{store: st}, nil
+}
+
+// key returns the key the file at path is cached under. Whether it was
+// transcoded is part of the key since it changes the contents read.
+func (c *contentCache) key(path string, transcode bool) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+
+	h := sha256.New()
+	io.WriteString(h, abs+"\x00"+strconv.FormatBool(transcode))
+
+	return cacheKeyPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns the cached read of the file at path, if it hasn't changed
+// since it was cached
+func (c *contentCache) get(path string, info os.FileInfo, transcode bool) (contextFile, bool) {
+	if c == nil {
+		return contextFile{}, false
+	}
+
+	r, err := c.store.Get(c.key(path, transcode))
+	if err != nil {
+		return contextFile{}, false
+	}
+	defer r.Close()
+
+	var cached cachedFile
+	if err := json.NewDecoder(r).Decode(&cached); err != nil {
+		return contextFile{}, false
+	}
+
+	// A changed file is read again, and replaces this copy once cached
+	if cached.Size != info.Size() || cached.ModTime != info.ModTime().UnixNano() {
+		return contextFile{}, false
+	}
+
+	// The path is the one given this time, which may be spelled
+	// differently than when it was cached
+	cached.Entry.Path = path
+	return contextFile{manifestEntry: cached.Entry, Content: cached.Content}, true
+}
+
+// put caches the read of the file at path, in the state given by info,
+// replacing any earlier copy of it. Failing to cache a file isn't worth
+// failing the run over.
+func (c *contentCache) put(path string, info os.FileInfo, transcode bool, f contextFile) {
+	if c == nil {
+		return
+	}
+
+	data, err := json.Marshal(cachedFile{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Entry: f.manifestEntry, Content: f.Content})
+	if err != nil {
+		return
+	}
+
+	c.store.Put(c.key(path, transcode), bytes.NewReader(data))
+}
+
+// close releases the store holding the cache
+func (c *contentCache) close() error {
+	if c == nil {
+		return nil
+	}
+
+	return c.store.Close()
+}
+
+// clearCache removes every cached file read from the store of the given
+// backend, returning how many were removed
+func clearCache(backend string) (int, error) {
+	st, err := openStore(backend)
+	if err != nil {
+		return 0, err
+	}
+	defer st.Close()
+
+	keys, err := st.Keys(cacheKeyPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, key := range keys {
+		if err := st.Delete(key); err != nil {
+			return i, err
+		}
+	}
+
+	return len(keys), nil
+}
+
+func newCacheCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the cache of file contents read by previous runs",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Remove every cached file read",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, err := clearCache(opts.store)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "removed %d cached files\n", n)
+			return nil
+		},
+	})
+
+	return cmd
+}
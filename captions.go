@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxCaptionLength is how long the description of an image is at most,
+// in bytes, so a chatty captioning command can't flood the context
+const maxCaptionLength = 300
+
+// imageExtensions lists the extensions of the images --caption-cmd
+// describes. SVG files are text, so they're included as they are.
+var imageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+	".bmp":  true,
+	".ico":  true,
+	".tif":  true,
+	".tiff": true,
+	".avif": true,
+	".heic": true,
+}
+
+// captions reports whether the image at path is described with
+// --caption-cmd rather than left out like other binary files
+func (o options) captions(path string) bool {
+	return o.captionCmd != "" && imageExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// captionImage describes the image at path with the command of
+// --caption-cmd, which gets the image on its stdin and its path in
+// CONTEXT_GENERATOR_FILE, like a script calling a captioning model. The
+// first line it prints becomes a one-line placeholder for the image in
+// the context. It returns false, like readFile does for binary files,
+// for images it can't describe.
+func captionImage(ctx context.Context, path string, opts options) (contextFile, bool, bool, error) {
+	file, err := opts.filters.Open(opts.root, path)
+	if err != nil {
+		return contextFile{}, false, false, err
+	}
+	defer file.Close()
+
+	// Read the image whole, since archives have no path to hand over
+	hash := sha256.New()
+	image, err := io.ReadAll(io.TeeReader(file, hash))
+	if err != nil {
+		return contextFile{}, false, false, fmt.Errorf("error reading file %q: %w", path, err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := shellCommand(ctx, opts.captionCmd)
+	cmd.Stdin = bytes.NewReader(image)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "CONTEXT_GENERATOR_FILE="+path)
+
+	if err := cmd.Run(); err != nil {
+		warnf(opts, "leaving out %q, --caption-cmd %q failed: %s", path, opts.captionCmd, err)
+		return contextFile{}, false, true, nil
+	}
+
+	caption := firstLine(stdout.String())
+	if caption == "" {
+		warnf(opts, "leaving out %q, --caption-cmd %q didn't describe it", path, opts.captionCmd)
+		return contextFile{}, false, true, nil
+	}
+
+	if len(caption) > maxCaptionLength {
+		cut := maxCaptionLength
+		for cut > 0 && !utf8.RuneStart(caption[cut]) {
+			cut--
+		}
+
+		caption = caption[:cut] + truncationMarker
+	}
+
+	f := contextFile{
+		manifestEntry: manifestEntry{
+			Path:   path,
+			Size:   int64(len(image)),
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		},
+		Content: "[image: " + caption + "]\n",
+	}
+
+	return f, true, true, nil
+}
+
+// firstLine returns the first line of s that isn't blank, trimmed
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+
+	return ""
+}
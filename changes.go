@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Ways of handling files that change while they're being read
+const (
+	changedRetry = "retry"
+	changedSkip  = "skip"
+	changedNote  = "note"
+)
+
+// changedAttempts is how many times a changing file is read before
+// giving up on getting a consistent copy of it
+const changedAttempts = 3
+
+// changedNoteText is appended to the contents of a file that changed
+// while being read, when it's included anyway
+const changedNoteText = "[context-generator: this file changed while it was being read, its contents may be incomplete or mix versions]\n"
+
+// changeModeFlag is the value of the --on-change flag. It implements
+// pflag.Value so invalid modes are rejected while parsing flags.
+type changeModeFlag string
+
+func (c *changeModeFlag) String() string {
+	if *c == "" {
+		return changedRetry
+	}
+
+	return string(*c)
+}
+
+func (c *changeModeFlag) Set(value string) error {
+	switch value {
+	case changedRetry, changedSkip, changedNote:
+		*c = changeModeFlag(value)
+		return nil
+	}
+
+	return fmt.Errorf("must be one of: %s, %s, %s", changedRetry, changedSkip, changedNote)
+}
+
+func (c *changeModeFlag) Type() string {
+	return "mode"
+}
+
+// unchanged reports whether the file at path still looks like it did
+// when it was opened, with before being its state at the time and read
+// the number of bytes read from it. Files replaced by a new one, like
+// those written atomically, count as changed too.
+func unchanged(path string, before os.FileInfo, read int64) bool {
+	after, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	return read == before.Size() &&
+		after.Size() == before.Size() &&
+		after.ModTime().Equal(before.ModTime()) &&
+		os.SameFile(before, after)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// readContextHeader reads the header of the context at path
+func readContextHeader(path string) (contextHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return contextHeader{}, fmt.Errorf("error opening context %q: %w", path, err)
+	}
+	defer f.Close()
+
+	header, _, err := parseContext(f)
+	if err != nil {
+		return contextHeader{}, fmt.Errorf("error parsing context %q: %w", path, err)
+	}
+
+	return header, nil
+}
+
+// warnExpired warns when the context at path, with header, is past its
+// expiry, since it may no longer match the code it came from
+func warnExpired(path string, header contextHeader) {
+	if header.expired(time.Now()) {
+		warnf(options{}, "context %q expired at %s and may not match the current code", path, header.Expires.Format(time.RFC3339))
+	}
+}
+
+func newCheckCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check context",
+		Short: "Check whether a generated context is past the expiry set with --expires",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			header, err := readContextHeader(args[0])
+			if err != nil {
+				return err
+			}
+
+			if header.Expires == nil {
+				fmt.Fprintf(cmd.OutOrStdout(), "context %q has no expiry\n", args[0])
+				return nil
+			}
+
+			if header.expired(time.Now()) {
+				return fmt.Errorf("context %q expired at %s", args[0], header.Expires.Format(time.RFC3339))
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "context %q is valid until %s\n", args[0], header.Expires.Format(time.RFC3339))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpointVersion is bumped whenever the checkpoint format changes in
+// an incompatible way
+const checkpointVersion = 1
+
+// checkpointInterval is the minimum time between two checkpoint saves
+// while the walk is in progress; saving after every file would make
+// checkpointing enormous trees quadratic
+const checkpointInterval = time.Second
+
+// manifestEntry describes a single file included in a generated context
+type manifestEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// checkpoint records the progress of a walk so an interrupted run can
+// be resumed. A nil checkpoint is valid and records nothing.
+type checkpoint struct {
+	Version  int             `json:"version"`
+	Root     string          `json:"root"`
+	LastPath string          `json:"last_path,omitempty"`
+	Complete bool            `json:"complete"`
+	Files    []manifestEntry `json:"files"`
+
+	path      string
+	absPath   string
+	seen      map[string]struct{}
+	lastSaved time.Time
+}
+
+// openCheckpoint creates a new checkpoint stored at path for a walk of
+// root, or loads the existing one when resuming. It returns a nil
+// checkpoint when path is empty.
+func openCheckpoint(path, root string, resume bool) (*checkpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving checkpoint path %q: %w", path, err)
+	}
+
+	cp := &checkpoint{
+		Version: checkpointVersion,
+		Root:    root,
+		Files:   []manifestEntry{},
+		path:    path,
+		absPath: absPath,
+		seen:    make(map[string]struct{}),
+	}
+
+	// Start from scratch unless we were asked to resume
+	if !resume {
+		return cp, cp.write()
+	}
+
+	loaded, err := readCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded.path, loaded.absPath, loaded.seen = cp.path, cp.absPath, cp.seen
+	cp = loaded
+
+	if cp.Root != root {
+		return nil, fmt.Errorf("checkpoint %q was created for directory %q, not %q", path, cp.Root, root)
+	}
+
+	if cp.Complete {
+		return nil, fmt.Errorf("checkpoint %q belongs to a completed run, nothing to resume", path)
+	}
+
+	for _, f := range cp.Files {
+		cp.seen[f.Path] = struct{}{}
+	}
+
+	return cp, nil
+}
+
+// readCheckpoint loads a checkpoint previously saved at path
+func readCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("checkpoint %q does not exist", path)
+		}
+
+		return nil, fmt.Errorf("error reading checkpoint %q: %w", path, err)
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("error parsing checkpoint %q: %w", path, err)
+	}
+
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint %q has unsupported version %d", path, cp.Version)
+	}
+
+	return &cp, nil
+}
+
+// done reports whether path was already emitted by a previous run, or
+// is the checkpoint file itself, which must never end up in the output
+func (c *checkpoint) done(path string) bool {
+	if c == nil {
+		return false
+	}
+
+	if abs, err := filepath.Abs(path); err == nil && (abs == c.absPath || abs == c.absPath+".tmp") {
+		return true
+	}
+
+	_, found := c.seen[path]
+	return found
+}
+
+// entries returns the files emitted so far, including those of the runs
+// being resumed
+func (c *checkpoint) entries() []manifestEntry {
+	if c == nil {
+		return nil
+	}
+
+	return c.Files
+}
+
+// record marks a file as emitted, saving the checkpoint if enough time
+// has passed since the last save
+func (c *checkpoint) record(entry manifestEntry) error {
+	if c == nil {
+		return nil
+	}
+
+	c.Files = append(c.Files, entry)
+	c.LastPath = entry.Path
+	c.seen[entry.Path] = struct{}{}
+
+	if time.Since(c.lastSaved) < checkpointInterval {
+		return nil
+	}
+
+	return c.save()
+}
+
+// save writes the checkpoint to disk
+func (c *checkpoint) save() error {
+	if c == nil {
+		return nil
+	}
+
+	return c.write()
+}
+
+// finish marks the walk as complete and saves the checkpoint
+func (c *checkpoint) finish() error {
+	if c == nil {
+		return nil
+	}
+
+	c.Complete = true
+	return c.write()
+}
+
+// write atomically replaces the checkpoint file so an interruption
+// mid-write never leaves a corrupted checkpoint behind
+func (c *checkpoint) write() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding checkpoint: %w", err)
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing checkpoint %q: %w", c.path, err)
+	}
+
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("error writing checkpoint %q: %w", c.path, err)
+	}
+
+	c.lastSaved = time.Now()
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// formatChunksJSONL splits files into overlapping chunks, one JSON value
+// per line, for embedding pipelines. Chunks are sized with flags of the
+// main command, so it's only available when generating a context.
+const formatChunksJSONL = "chunks-jsonl"
+
+// contextChunk is a slice of the lines of a file, with enough metadata to
+// point back to where it came from once retrieved
+type contextChunk struct {
+	Path      string `json:"path"`
+	Chunk     int    `json:"chunk"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Language  string `json:"language,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+	Content   string `json:"content"`
+}
+
+// lineRange is a range of lines, from start up to but not including end,
+// counting from zero
+type lineRange struct {
+	start int
+	end   int
+}
+
+// splitChunks splits lines into ranges of up to size lines, each one
+// repeating the last overlap lines of the previous one. A chunk ends at
+// a blank line in its last quarter when there's one, so chunks tend to
+// hold whole functions or paragraphs rather than cutting them in two.
+func splitChunks(lines []string, size, overlap int) []lineRange {
+	var chunks []lineRange
+
+	for start := 0; start < len(lines); {
+		end := min(start+size, len(lines))
+
+		if end < len(lines) {
+			for i := end - 1; i >= start+size*3/4 && i > start; i-- {
+				if strings.TrimSpace(lines[i]) == "" {
+					end = i + 1
+					break
+				}
+			}
+		}
+
+		chunks = append(chunks, lineRange{start: start, end: end})
+		if end == len(lines) {
+			break
+		}
+
+		// Always move forward, even when the overlap covers the chunk
+		start = max(end-overlap, start+1)
+	}
+
+	return chunks
+}
+
+// chunkWriter writes files as overlapping chunks of lines in the JSON
+// Lines format
+type chunkWriter struct {
+	w       io.Writer
+	size    int
+	overlap int
+}
+
+func (c *chunkWriter) writeFile(f contextFile) error {
+	// Collapsed files have nothing of their own to embed
+	if f.Content == "" {
+		return nil
+	}
+
+	var lines []string
+	eachLine(f.Content, func(line string) {
+		lines = append(lines, line)
+	})
+
+	language := detectLanguage(f.Path)
+	for i, r := range splitChunks(lines, c.size, c.overlap) {
+		data, err := json.Marshal(contextChunk{
+			Path:      f.Path,
+			Chunk:     i,
+			StartLine: r.start + 1,
+			EndLine:   r.end,
+			Language:  language,
+			SHA256:    f.SHA256,
+			Content:   strings.Join(lines[r.start:r.end], "\n") + "\n",
+		})
+		if err != nil {
+			return fmt.Errorf("error encoding chunk %d of file %q: %w", i, f.Path, err)
+		}
+
+		if _, err := fmt.Fprintf(c.w, "%s\n", data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *chunkWriter) close() error {
+	return nil
+}
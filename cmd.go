@@ -1,19 +1,28 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"log/slog"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/patrickdappollonio/context-generator/filter"
+	"github.com/patrickdappollonio/context-generator/internal/store"
+	"github.com/patrickdappollonio/context-generator/internal/transform"
 	"github.com/spf13/cobra"
 )
 
-const separator = "--------------------"
-
 func contains[T comparable](slice []T, value T) bool {
 	for _, item := range slice {
 		if item == value {
@@ -24,124 +33,1286 @@ func contains[T comparable](slice []T, value T) bool {
 	return false
 }
 
-func run(excludedFolderNames, excludedFileNames []string, w io.Writer) error {
-	currentDirectory := "."
+// options holds the settings for a single context generation run
+type options struct {
+	root           string
+	filters        filter.Options
+	checkpointPath string
+	resume         bool
+	filesFrom      string
+	fromArchive    string
+	rev            string
+	trackedOnly    bool
+	followImports  []string
+	entries        []string
+	pathStyle      pathStyleFlag
+
+	// workspace holds the roots of the configuration file, walked in
+	// place of root, and rootPrefix replaces the walked root in paths
+	workspace  []workspaceRoot
+	rootPrefix string
+
+	// anonymize rewrites what identifies the company behind the code,
+	// with the anonymizer built once the configuration file is loaded
+	anonymize       bool
+	anonymizeTerms  []string
+	hashDirectories bool
+	anonymizer      *anonymizer
+
+	// layout tunes the text format, set from the flags and then the
+	// configuration file
+	layout           textLayout
+	noTests          bool
+	testsOnly        bool
+	store            string
+	forceText        filter.Patterns
+	forceBinary      filter.Patterns
+	textExtensions   extensionList
+	binaryExtensions extensionList
+
+	// configBinaryExtensions come from the treat-as-binary list of the
+	// configuration file, kept apart to tell where they come from
+	configBinaryExtensions extensionList
+
+	summaryPath      string
+	failOverTokens   int64
+	useCache         bool
+	includeGenerated bool
+	maxLines         int
+	largeSample      largeFileSample
+	dataRows         int
+	summarizeConfig  bool
+	captionCmd       string
+	summarizeOver    filter.FileSize
+	maxLineLength    int
+	stripComments    bool
+	compact          bool
+	signaturesOnly   bool
+	minify           filter.Patterns
+	transformCmds    []string
+	filterPlugins    []string
+	noHooks          bool
+	format           string
+	label            string
+	noIndex          bool
+	noWarnings       bool
+	verbose          bool
+	quiet            bool
+	dryRun           bool
+	configPath       string
+	transcode        bool
+	linkFiles        bool
+	withMetadata     bool
+	vault            string
+	chunkLines       int
+	chunkOverlap     int
+	frontMatter      bool
+	onChange         changeModeFlag
+	exclusionSummary bool
+	noSummary        bool
+	dedupe           bool
+	collapseSimilar  bool
+	similarity       float64
+	assertReadOnly   bool
+	expires          time.Duration
+	gitMetadata      bool
+	withGitInfo      bool
+	promptPreset     string
+	outputs          []string
+	stdout           bool
+	clipboard        bool
+	upload           uploadTargetFlag
+	manifest         bool
+	appendOutput     bool
+	printHash        bool
+	checkHash        string
+
+	// validators are loaded from the configuration file
+	validators []validator
+
+	// prompt wraps the context, resolved from --prompt-preset once the
+	// configuration file is loaded
+	prompt *promptPreset
+
+	// uploader uploads the context with --upload once it's written
+	uploader *uploader
+
+	// hookConfig holds the hooks of the configuration file, and
+	// hookResult gets what the post hooks need once the run is done
+	hookConfig hooksConfig
+	hookResult *hookResult
+
+	// plugins are the processes of --filter-plugin, started for the run
+	plugins []*filterPlugin
+
+	// hash hashes the files written with --print-hash and --check
+	hash *contextHash
+
+	// summary collects what --summary-md reports about the run
+	summary *scanSummary
+
+	// cache holds the contents of files read by previous runs
+	cache *contentCache
+
+	// logger writes what the run is doing to stderr with --verbose
+	logger *slog.Logger
+
+	// explicitFolderNames and explicitFileNames are set when the
+	// exclusions were given by the user rather than being the defaults
+	explicitFolderNames bool
+	explicitFileNames   bool
+
+	// rootLoaded is set once the scanned directory was checked and its
+	// configuration file applied
+	rootLoaded bool
+}
+
+func run(ctx context.Context, opts options, w io.Writer) (err error) {
+	started := time.Now()
 
-	// Fetch a second parameter from the command line
-	if len(os.Args) == 2 {
-		currentDirectory = os.Args[1]
+	// Load the configuration file, if there's one
+	if err := opts.loadRoot(); err != nil {
+		return err
 	}
+	currentDirectory := opts.root
 
-	// Check if the directory provided exists
-	if _, err := os.Stat(currentDirectory); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("directory %q does not exist", currentDirectory)
+	// Refuse to run if anything would be written inside the scan root,
+	// before any command of the configuration file runs
+	if err := opts.checkReadOnly(); err != nil {
+		return err
+	}
+
+	// Prepare what the scan needs, like generated code, when generating
+	// a context rather than for the other commands
+	if opts.hookResult != nil {
+		if err := runHooks(ctx, "pre", currentDirectory, opts.hookConfig.Pre, []string{"CONTEXT_GENERATOR_ROOT=" + currentDirectory}); err != nil {
+			return err
+		}
+	}
+
+	// Start the filter plugins once for the whole run
+	if opts.plugins, err = startFilterPlugins(ctx, currentDirectory, opts.filterPlugins); err != nil {
+		return err
+	}
+
+	defer func() {
+		if stopErr := stopFilterPlugins(opts.plugins); stopErr != nil && err == nil {
+			err = stopErr
+		}
+	}()
+
+	// Reuse the contents of files that haven't changed since they were
+	// last read, when asked to; without a cache they're just read again.
+	// Every root shares it, so it's opened before they're set up.
+	if opts.useCache {
+		if opts.cache, err = openContentCache(opts.store); err != nil {
+			warnf(opts, "file contents won't be cached: %s", err)
+		}
+		defer opts.cache.close()
+	}
+
+	// Find out which files git tracks, or which are reachable from the
+	// entry points, before anything is written, since failing to is an
+	// error
+	roots, err := opts.walkRoots(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Wrap the context in the instructions of the prompt preset, asking
+	// the question once the whole context is out
+	if err := opts.prompt.writePreamble(w); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err == nil {
+			err = opts.prompt.writeQuestion(w)
 		}
+	}()
 
-		return fmt.Errorf("error checking directory %q: %w", currentDirectory, err)
+	// Standard output can be redirected into the scan root too, which is
+	// only found out once the walk reaches it
+	var stdout os.FileInfo
+	if opts.assertReadOnly && opts.stdout {
+		stdout, _ = os.Stdout.Stat()
 	}
 
-	// Walk through all files and directories starting from the current directory
-	err := filepath.Walk(currentDirectory, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			// Return the error to be handled by the caller
+	// Files the context is written to are never part of it
+	var outputs []os.FileInfo
+	for _, path := range opts.outputs {
+		if info, err := os.Stat(path); err == nil {
+			outputs = append(outputs, info)
+		}
+	}
+
+	// Load or create the checkpoint, if one was requested
+	cp, err := openCheckpoint(opts.checkpointPath, currentDirectory, opts.resume)
+	if err != nil {
+		return err
+	}
+
+	// Hold the output back until the walk ends when it has to be preceded
+	// by a summary of it, and write whatever was generated even if the
+	// walk failed
+	var summary *frontMatter
+	if opts.frontMatter {
+		spool, spoolErr := newSpoolFile()
+		if spoolErr != nil {
+			return spoolErr
+		}
+		defer spool.remove()
+
+		summary = newFrontMatter(opts, time.Now())
+		out := w
+		w = spool
+
+		defer func() {
+			// A context over budget is still complete
+			var budget *budgetError
+			complete := err == nil || errors.As(err, &budget)
+
+			if ctx.Err() == nil && complete {
+				if writeErr := summary.write(out); writeErr != nil {
+					err = writeErr
+				}
+			}
+
+			if copyErr := spool.copyTo(out); copyErr != nil && err == nil {
+				err = copyErr
+			}
+		}()
+	}
+
+	// Create the writer for the requested output format
+	header := contextHeader{Label: opts.label, Root: opts.anonymizer.path(currentDirectory, true)}
+	if opts.expires > 0 {
+		expires := time.Now().Add(opts.expires).UTC().Truncate(time.Second)
+		header.Expires = &expires
+	}
+
+	// Record which version of the code this is; a resumed run already
+	// wrote its header, and an appended one would write it mid-context
+	if opts.gitMetadata && !opts.resume && !opts.appendOutput {
+		if header.Git, err = readGitMetadata(ctx, currentDirectory, opts.rev); err != nil {
+			warnf(opts, "the context won't record its git version: %s", err)
+		}
+	}
+
+	// Text-like formats carry their header as front matter
+	if summary != nil {
+		summary.Expires, summary.Git = header.Expires, header.Git
+	} else if (header.Expires != nil || header.Git != nil) && isDocumentFormat(opts.format) {
+		if err := writeHeaderFrontMatter(w, header); err != nil {
 			return err
 		}
+	}
+
+	// List what goes into the context for --manifest as it's written
+	var manifest *contextManifest
+	if opts.manifest {
+		manifest = newContextManifest(opts, header.Git, time.Now())
+	}
+
+	var cw contextWriter
+	if opts.format == formatObsidian {
+		cw, err = newObsidianWriter(opts.vault, header)
+	} else if opts.format == formatSQLite {
+		cw, err = newSQLiteWriter(opts.outputs[0], header)
+	} else if opts.format == formatChunksJSONL {
+		cw = &chunkWriter{w: w, size: opts.chunkLines, overlap: opts.chunkOverlap}
+	} else {
+		cw, err = newContextWriter(opts.format, w, header, &opts.layout)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Keep a searchable copy of the context; failing to do so shouldn't
+	// prevent the context from being generated
+	var idx *contextIndex
+	if !opts.noIndex {
+		if idx, err = openIndex(opts.store, header); err != nil {
+			warnf(opts, "the context won't be searchable: %s", err)
+		}
+	}
 
-		// Check if the directory should be excluded
-		if info.IsDir() && contains(excludedFolderNames, info.Name()) {
-			// Skip the directory and its contents
-			return filepath.SkipDir
+	// Find out where files can be browsed online to link to them
+	var linker *fileLinker
+	if opts.linkFiles {
+		if linker, err = newFileLinker(ctx, currentDirectory, opts.rev); err != nil {
+			warnf(opts, "files won't be linked: %s", err)
 		}
+	}
+
+	// Emit files identical to one already in the context only once,
+	// including those emitted before the run was resumed
+	var duplicates duplicateFinder
+	if opts.dedupe {
+		duplicates = newDuplicateFinder(cp.entries())
+	}
 
-		// Skip files that are in the excludedFileNames list
-		if !info.IsDir() && contains(excludedFileNames, info.Name()) {
-			return nil
+	// Files nearly the same as one already emitted are collapsed into
+	// it; those emitted before a resumed run aren't known anymore
+	var similars *similarFinder
+	if opts.collapseSimilar {
+		similars = newSimilarFinder(opts.similarity)
+	}
+
+	// Find out who last changed each file, reading the git log once
+	var history *gitHistory
+	if opts.withGitInfo {
+		if history, err = readGitHistory(ctx, currentDirectory, opts.rev); err != nil {
+			warnf(opts, "files won't show their last commit: %s", err)
 		}
+	}
+
+	// Walk through all files starting from the current directory, or
+	// from every root of the workspace, each with its own options
+	unread := make(filter.Exclusions)
+	var totals contextTotals
+	var skipped skippedFiles
+	report := &filter.Report{Excluded: make(filter.Exclusions)}
+	for _, opts := range roots {
+		var rootReport *filter.Report
+		rootReport, err = walkFiles(ctx, opts.root, opts, func(path string, info os.FileInfo) error {
+			// Skip files already emitted by a previous, interrupted run
+			if cp.done(opts.displayPath(path)) {
+				return nil
+			}
+
+			if err := checkNotOutput(path, info, stdout); err != nil {
+				return err
+			}
+
+			if slices.ContainsFunc(outputs, func(o os.FileInfo) bool { return os.SameFile(info, o) }) {
+				return nil
+			}
+
+			start := time.Now()
+			f, excluded, err := processFile(ctx, path, opts)
+			if err != nil {
+				opts.log().Debug("read", "path", path, "error", err)
+				return skipped.skip(opts, path, err)
+			}
+
+			opts.log().Debug("read", "path", path, "bytes", len(f.Content), "excluded", excluded, "elapsed", time.Since(start))
+
+			// Skip files that weren't included, like binary files
+			if excluded != "" {
+				unread[excluded]++
+				return nil
+			}
 
-		// Skip directories; process only files
-		if info.IsDir() {
-			return nil
+			f.Path = opts.displayPath(path)
+			f.Link = linker.link(path, countLines(f.Content))
+			f.Git = history.info(path)
+
+			if opts.withMetadata {
+				f.Metadata = &fileMetadata{
+					Size:     info.Size(),
+					Modified: info.ModTime().UTC().Truncate(time.Second),
+					Lines:    countLines(f.Content),
+					Language: detectLanguage(path),
+				}
+			}
+
+			opts.anonymizer.anonymize(&f)
+			duplicates.dedupe(&f)
+			similars.collapse(&f)
+
+			if err := cw.writeFile(f); err != nil {
+				return err
+			}
+			opts.hash.add(f)
+			manifest.add(f)
+			summary.add(f)
+			opts.summary.add(opts.contextRoot(), f)
+			totals.add(f)
+
+			if err := idx.writeFile(f); err != nil {
+				return err
+			}
+
+			// Record the file as processed so a resumed run won't emit it again
+			return cp.record(f.manifestEntry)
+		})
+
+		report.Merge(rootReport)
+		if err != nil || ctx.Err() != nil {
+			break
 		}
+	}
+
+	// If the run was interrupted, save the progress made so far and
+	// leave the output open so a resumed run can continue appending to it
+	if ctx.Err() != nil {
+		idx.discard()
 
-		// Open the file for reading
-		file, err := os.Open(path)
-		if err != nil {
+		if err := cp.save(); err != nil {
 			return err
 		}
-		defer file.Close()
 
-		// Read the first 512 bytes to detect content type
-		buffer := make([]byte, 512)
-		n, err := file.Read(buffer)
-		if err != nil && err != io.EOF {
+		return fmt.Errorf("scan interrupted: %w", ctx.Err())
+	}
+
+	// Finish the output, like writing the closing line of dashes
+	if closeErr := cw.close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	// Mark the checkpoint complete and save the index when the walk
+	// finished cleanly
+	if err == nil {
+		warnUnmatched(opts, report.Excluded)
+		warnSkipped(opts, report.Skipped, skipped)
+		for key, n := range unread {
+			report.Excluded[key] += n
+		}
+
+		if opts.exclusionSummary {
+			if err := writeExclusionSummary(w, opts.format, report.Excluded); err != nil {
+				return err
+			}
+		}
+
+		// Totals of a resumed or appended run would only count part of
+		// the context
+		if !opts.noSummary && !opts.resume && !opts.appendOutput && isDocumentFormat(opts.format) {
+			if err := writeFooter(w, opts.format, totals); err != nil {
+				return err
+			}
+		}
+
+		if err := opts.summary.write(report.Excluded); err != nil {
 			return err
 		}
 
-		// Reset the file pointer to the beginning
-		if _, err := file.Seek(0, io.SeekStart); err != nil {
+		if err := manifest.write(totals); err != nil {
 			return err
 		}
 
-		// Detect content type
-		contentType := http.DetectContentType(buffer[:n])
+		if err := idx.commit(); err != nil {
+			return err
+		}
 
-		// Check if the content type indicates a text file
-		if !strings.HasPrefix(contentType, "text/") {
-			// Skip binary files
-			return nil
+		if err := cp.finish(); err != nil {
+			return err
 		}
 
-		// Write the first line of dashes
-		fmt.Fprintln(w, separator)
-		// Write the relative file path
-		fmt.Fprintln(w, "file:", path)
-		// Write the second line of dashes
-		fmt.Fprintln(w, separator)
+		opts.log().Debug("done", "files", totals.files, "tokens", totals.tokens(), "elapsed", time.Since(started))
 
-		// Create a scanner to read the file line by line
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			// Write each line with 4 spaces indentation
-			fmt.Fprintf(w, "    %s\n", scanner.Text())
+		if opts.hookResult != nil {
+			*opts.hookResult = hookResult{post: opts.hookConfig.Post, root: currentDirectory, totals: totals}
 		}
 
-		// Check for errors during scanning
-		if err := scanner.Err(); err != nil {
-			return fmt.Errorf("error reading file %q: %w", path, err)
-		}
+		// The context is still written, so it can be inspected
+		return checkBudget(totals.tokens(), opts.failOverTokens)
+	}
 
-		return nil
-	})
+	idx.discard()
 
-	// Write the third line of dashes
-	fmt.Fprintln(w, separator)
+	// Save the progress so the run can be resumed after fixing the error
+	if saveErr := cp.save(); saveErr != nil {
+		return saveErr
+	}
 
 	// Return any error encountered during the file walk
 	return err
 }
 
+// checkRoot validates the directory to scan, defaulting to the current
+// directory when none was given
+func checkRoot(dir string) (string, error) {
+	if dir == "" {
+		dir = "."
+	}
+
+	// Walk reports paths below the root in their clean form, so the root
+	// has to be clean too for them to be compared
+	dir = filepath.Clean(dir)
+
+	// Check if the directory provided exists
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("directory %q %w", dir, filter.ErrDirNotFound)
+		}
+
+		return "", fmt.Errorf("error checking directory %q: %w", dir, err)
+	}
+
+	return dir, nil
+}
+
+// processFile reads the contents of a text file, returning them along
+// with the key it's counted under in the exclusions when it shouldn't be
+// included at all
+func processFile(ctx context.Context, path string, opts options) (contextFile, string, error) {
+	// Read the file again if it changes while being read, like build
+	// outputs and logs do, so its contents don't mix versions
+	var (
+		f      contextFile
+		ok     bool
+		stable bool
+		err    error
+	)
+	read := readFile
+	if opts.captions(path) {
+		read = func(path string, opts options) (contextFile, bool, bool, error) {
+			return captionImage(ctx, path, opts)
+		}
+	}
+
+	for attempt := 1; attempt <= changedAttempts; attempt++ {
+		if f, ok, stable, err = read(path, opts); err != nil || !ok || stable {
+			break
+		}
+
+		if opts.onChange != changedRetry && opts.onChange != "" {
+			break
+		}
+	}
+
+	if err != nil || !ok {
+		return contextFile{}, contentExclusion, err
+	}
+
+	if !stable {
+		if opts.onChange == changedNote {
+			warnf(opts, "file %q changed while being read, it was included with a note", path)
+			f.Content += changedNoteText
+		} else {
+			warnf(opts, "file %q changed while being read, it was skipped", path)
+			return contextFile{}, contentExclusion, nil
+		}
+	}
+
+	// Leave out files that look generated or minified, which take a lot
+	// of tokens for little insight
+	if !opts.includeGenerated && detectGenerated(path, f.Content) != "" {
+		return contextFile{}, generatedExclusion, nil
+	}
+
+	// Keep the header and the first rows of data files with --data-rows,
+	// before they're measured against the limits
+	if opts.dataRows > 0 && !f.sampled {
+		if rows, truncated := transform.TruncateRows(path, []byte(f.Content), opts.dataRows); truncated {
+			f.Content = string(rows)
+		}
+	}
+
+	// Summarize the structure of large JSON and YAML files with
+	// --summarize-config-files, before they're measured against the
+	// limits too
+	if opts.summarizeConfig && !f.sampled && f.Size > int64(opts.summarizeOver) {
+		if summary, summarized := transform.SummarizeStructure(path, []byte(f.Content)); summarized {
+			f.Content = string(summary)
+		}
+	}
+
+	// Keep only the start and the end of files over the limits with
+	// --sample-large-files, or leave out the ones too long to be worth
+	// their tokens, like lockfiles
+	lines := countLines(f.Content)
+	if f.Content, f.sampled = opts.sampleLarge(f, lines); !f.sampled && opts.overMaxLines(lines) {
+		return contextFile{}, opts.maxLinesExclusion(), nil
+	}
+
+	// Let the filter plugins leave the file out for reasons of their own
+	if len(opts.plugins) > 0 {
+		if key, err := opts.applyFilterPlugins(f); err != nil || key != "" {
+			return contextFile{}, key, err
+		}
+	}
+
+	// The contents are only copied when something may rewrite them
+	if len(opts.validators) == 0 && !opts.transforms() && opts.maxLineLength <= 0 {
+		return f, "", nil
+	}
+
+	content := []byte(f.Content)
+
+	// Check the contents against the configured validators, which may
+	// redact them or leave the file out entirely
+	if len(opts.validators) > 0 {
+		var include bool
+		if content, include, err = validateContent(ctx, opts.root, path, content, opts); err != nil || !include {
+			return contextFile{}, contentExclusion, err
+		}
+	}
+
+	// Apply any content transformations
+	if opts.transforms() {
+		content = transformContent(path, content, opts)
+	}
+
+	// Cut lines too long to be worth their tokens, like those of
+	// minified bundles, rather than emitting them whole
+	var cut int
+	if content, cut = opts.truncateLongLines(content); cut > 0 {
+		warnf(opts, "truncated %s of %q longer than %d bytes", plural(cut, "line"), path, opts.maxLineLength)
+	}
+
+	f.Content = string(content)
+	return f, "", nil
+}
+
+// readFile reads a text file as it is on disk, returning whether it
+// should be included at all and whether it stayed the same while being
+// read
+func readFile(path string, opts options) (contextFile, bool, bool, error) {
+	// Open the file for reading
+	file, err := opts.filters.Open(opts.root, path)
+	if err != nil {
+		return contextFile{}, false, false, err
+	}
+	defer file.Close()
+
+	// Remember what the file looked like before reading it
+	before, err := file.Stat()
+	if err != nil {
+		return contextFile{}, false, false, fmt.Errorf("error checking file %q: %w", path, err)
+	}
+
+	// Unchanged files read by a previous run come from the cache
+	if f, found := opts.cache.get(path, before, opts.transcode); found {
+		if opts.overrideEncoding(opts.root, path, encodingUTF8) == "" {
+			return contextFile{}, false, false, nil
+		}
+
+		return f, true, true, nil
+	}
+
+	// Detect whether the file contains text, and in which encoding
+	encoding, err := sniffEncoding(file, opts.transcode)
+	if err != nil {
+		return contextFile{}, false, false, err
+	}
+	encoding = opts.overrideEncoding(opts.root, path, encoding)
+
+	// Skip binary files
+	if encoding == "" {
+		return contextFile{}, false, false, nil
+	}
+
+	// Reset the file pointer to the beginning
+	seeker, ok := file.(io.Seeker)
+	if !ok {
+		return contextFile{}, false, false, fmt.Errorf("error reading file %q: it can't be read twice", path)
+	}
+
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return contextFile{}, false, false, err
+	}
+
+	// Hash and count the contents while they're being read
+	hash := sha256.New()
+	counter := &countingReader{r: io.TeeReader(file, hash)}
+
+	// Only the sample of large files is kept rather than the whole of
+	// them, unless they need decoding, which works on whole files
+	var (
+		content string
+		sampled bool
+	)
+	if opts.largeSample.enabled() && opts.overMaxFileSize(before.Size()) && encoding == encodingUTF8 {
+		content, err = opts.largeSample.read(counter)
+		sampled = true
+	} else {
+		content, err = readContent(counter, before.Size())
+	}
+	if err != nil {
+		return contextFile{}, false, false, fmt.Errorf("error reading file %q: %w", path, err)
+	}
+
+	// Convert the contents to UTF-8 so they aren't garbled; most files
+	// already are, and they're kept as they were read
+	if opts.transcode && (encoding != encodingUTF8 || !utf8.ValidString(content)) {
+		decoded, _ := decodeText([]byte(content), encoding)
+		content = string(decoded)
+	}
+
+	f := contextFile{
+		manifestEntry: manifestEntry{
+			Path:   path,
+			Size:   counter.n,
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		},
+		Content: content,
+		sampled: sampled,
+	}
+
+	// Only cache what was read in one go, so the cache never holds a
+	// mix of versions; archives can't change while being read
+	stable := opts.filters.FS != nil || unchanged(path, before, counter.n)
+	if stable && !sampled {
+		opts.cache.put(path, before, opts.transcode, f)
+	}
+
+	return f, true, stable, nil
+}
+
+// copyBuffers reuses the buffers file contents are read through
+var copyBuffers = sync.Pool{New: func() any { return new([32 * 1024]byte) }}
+
+// readContent reads everything in r, expected to hold about size bytes,
+// straight into the string it returns through a pooled buffer, rather
+// than growing a slice and copying it into a string afterwards
+func readContent(r io.Reader, size int64) (string, error) {
+	var b strings.Builder
+	if size > 0 {
+		b.Grow(int(size))
+	}
+
+	buf := copyBuffers.Get().(*[32 * 1024]byte)
+	defer copyBuffers.Put(buf)
+
+	if _, err := io.CopyBuffer(&b, r, buf[:]); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// randomSeed picks a seed for runs that didn't set --seed, so their
+// sample can still be reproduced by passing it back
+func randomSeed() uint64 {
+	for {
+		if seed := rand.Uint64(); seed != 0 {
+			return seed
+		}
+	}
+}
+
 func getAppName() string {
 	n := filepath.Base(os.Args[0])
 	return strings.TrimFunc(n, func(r rune) bool { return r == '/' || r == '.' })
 }
 
 func getMainCommand() *cobra.Command {
-	var excludedFolderNames []string
-	var excludedFileNames []string
+	var opts options
+	var forceText, forceBinary, minify, textExtensions, binaryExtensions []string
+	var textSeparator, fileHeaderFormat string
+	var indent int
 
 	cmd := &cobra.Command{
-		Use:           getAppName(),
+		Use:           getAppName() + " [directory | glob...]",
 		Short:         fmt.Sprintf("%s allows you to quickly create contexts to be given to GPT-like apps from your source code", getAppName()),
 		SilenceErrors: true,
 		SilenceUsage:  true,
+		Args:          cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return run(excludedFolderNames, excludedFileNames, os.Stdout)
+			// Globs pick files below the current directory; otherwise use
+			// the directory given as an argument, if any
+			if slices.ContainsFunc(args, filter.IsGlob) {
+				if opts.filesFrom != "" {
+					return fmt.Errorf("globs can't be used with --files-from")
+				}
+
+				opts.filters.Globs = args
+			} else if len(args) > 1 {
+				return fmt.Errorf("accepts a single directory or any number of globs, received %d arguments without globs", len(args))
+			} else if len(args) == 1 {
+				opts.root = args[0]
+			}
+
+			// Archives are read in place of a directory, when given with
+			// --from-archive or as the argument
+			if opts.fromArchive != "" && len(args) > 0 {
+				return fmt.Errorf("flag --from-archive can't be used with a directory or globs")
+			}
+
+			if opts.fromArchive == "" && len(args) == 1 && isArchiveFile(args[0]) {
+				opts.fromArchive = args[0]
+			}
+
+			if opts.fromArchive != "" && opts.rev != "" {
+				return fmt.Errorf("flag --rev can't be used with an archive")
+			}
+
+			if opts.fromArchive != "" && opts.trackedOnly {
+				return fmt.Errorf("flag --tracked-only can't be used with an archive")
+			}
+
+			if opts.fromArchive != "" {
+				if err := opts.useArchive(opts.fromArchive); err != nil {
+					return err
+				}
+			}
+
+			if opts.rev != "" {
+				if err := opts.useRevision(cmd.Context(), opts.rev); err != nil {
+					return err
+				}
+			}
+
+			if opts.resume && opts.checkpointPath == "" {
+				return fmt.Errorf("flag --resume requires --checkpoint to be set")
+			}
+
+			// Resumed output is appended to the partial one, which only
+			// works for formats without a closing structure
+			if opts.resume && (opts.format == formatJSON || opts.format == formatHTML || opts.format == formatObsidian || opts.format == formatSQLite) {
+				return fmt.Errorf("flag --resume can't be used with --format %s", opts.format)
+			}
+
+			// The summary is YAML front matter, which only makes sense at
+			// the start of text-like documents, and it's only known once
+			// every file has been read
+			if opts.frontMatter && !isDocumentFormat(opts.format) {
+				return fmt.Errorf("flag --front-matter can only be used with --format %s", strings.Join(documentFormats, ", "))
+			}
+
+			if opts.exclusionSummary && !isDocumentFormat(opts.format) {
+				return fmt.Errorf("flag --with-exclusion-summary can only be used with --format %s", strings.Join(documentFormats, ", "))
+			}
+
+			// Expiry is recorded in the header, which these formats lack
+			if opts.expires > 0 && (opts.format == formatJSONL || opts.format == formatChunksJSONL || opts.format == formatObsidian) {
+				return fmt.Errorf("flag --expires can't be used with --format %s", opts.format)
+			}
+
+			if opts.expires > 0 && opts.resume {
+				return fmt.Errorf("flag --expires can't be used with --resume")
+			}
+
+			if opts.frontMatter && opts.resume {
+				return fmt.Errorf("flag --front-matter can't be used with --resume")
+			}
+
+			// Prompts are meant to be pasted, so they only wrap text and
+			// complete contexts
+			if opts.promptPreset != "" && !isDocumentFormat(opts.format) {
+				return fmt.Errorf("flag --prompt-preset can only be used with --format %s", strings.Join(documentFormats, ", "))
+			}
+
+			if opts.promptPreset != "" && opts.resume {
+				return fmt.Errorf("flag --prompt-preset can't be used with --resume")
+			}
+
+			if opts.similarity <= 0 || opts.similarity > 1 {
+				return fmt.Errorf("flag --similarity must be between 0 and 1, got %g", opts.similarity)
+			}
+
+			// The layout flags override the text section of the
+			// configuration file, so only the ones given are kept
+			for name, set := range map[string]func(){
+				"separator":          func() { opts.layout.Separator = &textSeparator },
+				"file-header-format": func() { opts.layout.FileHeader = &fileHeaderFormat },
+				"indent":             func() { opts.layout.Indent = &indent },
+			} {
+				if !cmd.Flags().Changed(name) {
+					continue
+				}
+
+				if opts.format != formatText {
+					return fmt.Errorf("flag --%s can only be used with --format %s", name, formatText)
+				}
+
+				set()
+			}
+
+			if cmd.Flags().Changed("similarity") && !opts.collapseSimilar {
+				return fmt.Errorf("flag --similarity requires --collapse-similar")
+			}
+
+			if cmd.Flags().Changed("chunk-lines") || cmd.Flags().Changed("chunk-overlap") {
+				if opts.format != formatChunksJSONL {
+					return fmt.Errorf("flags --chunk-lines and --chunk-overlap can only be used with --format %s", formatChunksJSONL)
+				}
+			}
+
+			if opts.chunkLines <= 0 {
+				return fmt.Errorf("flag --chunk-lines must be 1 or more, got %d", opts.chunkLines)
+			}
+
+			if opts.chunkOverlap < 0 || opts.chunkOverlap >= opts.chunkLines {
+				return fmt.Errorf("flag --chunk-overlap must be at least 0 and less than --chunk-lines, got %d", opts.chunkOverlap)
+			}
+
+			if opts.vault != "" && opts.format != formatObsidian {
+				return fmt.Errorf("flag --vault can only be used with --format %s", formatObsidian)
+			}
+
+			// Appended runs build up a single context, which only works for
+			// formats without a header or a closing structure
+			if opts.appendOutput && len(opts.outputs) == 0 {
+				return fmt.Errorf("flag --append requires --output to be set")
+			}
+
+			if opts.appendOutput && !isDocumentFormat(opts.format) && opts.format != formatJSONL && opts.format != formatChunksJSONL {
+				return fmt.Errorf("flag --append can't be used with --format %s", opts.format)
+			}
+
+			for _, name := range []string{"front-matter", "expires", "prompt-preset"} {
+				if opts.appendOutput && cmd.Flags().Changed(name) {
+					return fmt.Errorf("flag --%s can't be used with --append", name)
+				}
+			}
+
+			// The hash takes the place of the context on stdout, which is
+			// still written to the other outputs, if any
+			if opts.printHash || opts.checkHash != "" {
+				if opts.printHash && opts.checkHash != "" {
+					return fmt.Errorf("flags --print-hash and --check can't be used together")
+				}
+
+				if opts.dryRun || opts.resume || opts.appendOutput {
+					return fmt.Errorf("flags --print-hash and --check need a whole context, so they can't be used with --dry-run, --resume or --append")
+				}
+
+				opts.hash = newContextHash()
+				opts.stdout = false
+			}
+
+			// A manifest lists the files of a whole context
+			if opts.manifest && (opts.dryRun || opts.resume || opts.appendOutput) {
+				return fmt.Errorf("flag --manifest needs a whole context, so it can't be used with --dry-run, --resume or --append")
+			}
+
+			// The URL of the upload takes the place of the context on
+			// stdout too, so it can be captured by scripts
+			if opts.upload != "" {
+				if opts.hash != nil {
+					return fmt.Errorf("flag --upload can't be used with --print-hash or --check")
+				}
+
+				if opts.dryRun || opts.resume || opts.appendOutput {
+					return fmt.Errorf("flag --upload needs a whole context, so it can't be used with --dry-run, --resume or --append")
+				}
+
+				if opts.format == formatSQLite || opts.format == formatObsidian {
+					return fmt.Errorf("flag --upload can't be used with --format %s", opts.format)
+				}
+
+				u, err := newUploader(opts.upload)
+				if err != nil {
+					return err
+				}
+
+				opts.uploader = u
+
+				opts.stdout = false
+			}
+
+			if opts.dryRun {
+				return dryRun(cmd.Context(), opts, os.Stdout)
+			}
+
+			opts.hookResult = &hookResult{}
+
+			// Databases are written in place rather than streamed to the
+			// outputs, and one cut short is of no use
+			if opts.format == formatSQLite {
+				if len(opts.outputs) != 1 || opts.clipboard || opts.appendOutput {
+					return fmt.Errorf("format %s requires a single --output, like corpus.db, and can't be used with --clipboard or --append", formatSQLite)
+				}
+
+				err := run(cmd.Context(), opts, io.Discard)
+				if cmd.Context().Err() != nil {
+					os.Remove(opts.outputs[0])
+				}
+
+				if err == nil {
+					err = opts.hash.finish(os.Stdout, opts.checkHash)
+				}
+
+				if err == nil {
+					err = opts.hookResult.runPostHooks(cmd.Context(), opts)
+				}
+
+				return err
+			}
+
+			sinks, err := openSinks(opts, os.Stdout)
+			if err != nil {
+				return err
+			}
+
+			err = run(cmd.Context(), opts, sinks.writer())
+			if closeErr := sinks.close(cmd.Context(), cmd.Context().Err() == nil); closeErr != nil && err == nil {
+				err = closeErr
+			}
+
+			// Without a checkpoint to resume from, the output files of an
+			// interrupted run hold a context cut short that's best gone
+			if cmd.Context().Err() != nil && opts.checkpointPath == "" {
+				sinks.discard()
+			}
+
+			if err == nil {
+				err = opts.hash.finish(os.Stdout, opts.checkHash)
+			}
+
+			if err == nil && opts.uploader != nil {
+				description := opts.label
+				if description == "" {
+					description = "Context generated with context-generator"
+				}
+
+				if opts.hookResult.url, err = opts.uploader.upload(cmd.Context(), uploadFileName(opts.format), description, sinks.upload.Bytes()); err == nil {
+					fmt.Fprintln(os.Stdout, opts.hookResult.url)
+				}
+			}
+
+			// Post hooks see the outputs once they're complete
+			if err == nil {
+				err = opts.hookResult.runPostHooks(cmd.Context(), opts)
+			}
+
+			return err
 		},
 	}
 
-	cmd.Flags().StringSliceVar(&excludedFolderNames, "exclude-folder", []string{".git", "node_modules"}, "exclude folders with these names")
-	cmd.Flags().StringSliceVar(&excludedFileNames, "exclude-file", []string{".DS_Store"}, "exclude files with these names")
+	// Tell apart exclusions given by the user from the defaults, which
+	// aren't expected to match anything in every tree
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		opts.explicitFolderNames = cmd.Flags().Changed("exclude-folder")
+		opts.explicitFileNames = cmd.Flags().Changed("exclude-file")
+
+		// Set up logging before anything worth logging happens
+		if opts.verbose && opts.quiet {
+			return fmt.Errorf("flags --verbose and --quiet can't be used together")
+		}
+
+		if !opts.anonymize && (cmd.Flags().Changed("anonymize-term") || cmd.Flags().Changed("hash-directories")) {
+			return fmt.Errorf("flags --anonymize-term and --hash-directories require --anonymize")
+		}
+
+		if len(opts.followImports) > 0 && len(opts.entries) > 0 {
+			return fmt.Errorf("flags --follow-imports and --entry can't be used together")
+		}
+
+		if opts.quiet {
+			opts.noWarnings = true
+		}
+
+		if opts.verbose {
+			opts.logger = newVerboseLogger()
+			opts.filters.Trace = traceDecisions(opts.logger)
+		}
+
+		// Start collecting warnings for the summary right away
+		if opts.summaryPath != "" {
+			opts.summary = newScanSummary(opts.summaryPath)
+		}
+
+		if opts.dataRows < 0 {
+			return fmt.Errorf("flag --data-rows must be 0 or more, got %d", opts.dataRows)
+		}
+
+		// Large files are read partially rather than left out
+		opts.filters.IncludeLarge = opts.largeSample.enabled()
+
+		if err := opts.filters.Sample.Check(); err != nil {
+			return err
+		}
+
+		// Compile the overrides for the binary detection
+		var err error
+		if opts.forceText, err = filter.ParsePatterns(filter.SplitPatterns(forceText)); err != nil {
+			return fmt.Errorf("invalid --force-text pattern: %w", err)
+		}
+
+		if opts.forceBinary, err = filter.ParsePatterns(filter.SplitPatterns(forceBinary)); err != nil {
+			return fmt.Errorf("invalid --force-binary pattern: %w", err)
+		}
+
+		if opts.textExtensions, err = parseExtensions("treat-as-text", textExtensions); err != nil {
+			return err
+		}
+
+		if opts.binaryExtensions, err = parseExtensions("treat-as-binary", binaryExtensions); err != nil {
+			return err
+		}
+
+		if opts.minify, err = filter.ParsePatterns(filter.SplitPatterns(minify)); err != nil {
+			return fmt.Errorf("invalid --minify pattern: %w", err)
+		}
+
+		for _, command := range opts.transformCmds {
+			opts.filters.Transformers = append(opts.filters.Transformers, commandTransformer{ctx: cmd.Context(), command: command, opts: opts})
+		}
+
+		// --no-tests and --tests-only are shorthands for the --tests modes
+		if opts.noTests && opts.testsOnly {
+			return fmt.Errorf("flags --no-tests and --tests-only can't be used together")
+		}
+
+		if opts.noTests {
+			if cmd.Flags().Changed("tests") && opts.filters.Tests != filter.TestsExclude {
+				return fmt.Errorf("flag --no-tests can't be used with --tests %s", opts.filters.Tests.String())
+			}
+
+			opts.filters.Tests = filter.TestsExclude
+		}
+
+		if opts.testsOnly {
+			if cmd.Flags().Changed("tests") && opts.filters.Tests != filter.TestsOnly {
+				return fmt.Errorf("flag --tests-only can't be used with --tests %s", opts.filters.Tests.String())
+			}
+
+			opts.filters.Tests = filter.TestsOnly
+		}
+
+		if opts.filters.WithTestedFiles && opts.filters.Tests != filter.TestsOnly {
+			return fmt.Errorf("flag --with-tested-files requires --tests-only")
+		}
+
+		// Listed files aren't walked, so the walk filters would be
+		// silently ignored
+		if opts.filesFrom != "" {
+			for _, name := range []string{"exclude-folder", "exclude-file", "exclude", "preset", "max-depth", "max-file-size", "tracked-only", "follow-imports", "entry", "codeowners", "hidden", "tests", "no-tests", "tests-only", "with-tested-files"} {
+				if cmd.Flags().Changed(name) {
+					return fmt.Errorf("flag --%s can't be used with --files-from", name)
+				}
+			}
+
+			list, err := readFileList(opts.filesFrom, cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+
+			opts.filters.Files = list
+		}
+
+		// Pick a seed when none was given, and tell it so the same
+		// sample can be taken again
+		if opts.filters.Sample.Enabled() && !cmd.Flags().Changed("seed") {
+			opts.filters.Sample.Seed = randomSeed()
+			warnf(opts, "sampling files with --seed %d, pass it again to reproduce this selection", opts.filters.Sample.Seed)
+		}
+
+		return nil
+	}
+
+	// Filters are shared by all subcommands that walk the tree
+	cmd.PersistentFlags().StringSliceVar(&opts.filters.ExcludeFolders, "exclude-folder", []string{".git", "node_modules"}, "exclude folders with these names")
+	cmd.PersistentFlags().StringSliceVar(&opts.filters.ExcludeFiles, "exclude-file", []string{".DS_Store"}, "exclude files with these names")
+	cmd.PersistentFlags().StringArrayVar(&opts.filters.Exclude, "exclude", nil, "exclude paths matching this gitignore-style pattern, relative to the directory, like docs/**; repeat it to add more, where later patterns win and one starting with ! brings back what earlier ones excluded")
+	cmd.PersistentFlags().StringVar(&opts.configPath, "config", "", "configuration file to use instead of "+configFileName+" at the root of the directory")
+	cmd.PersistentFlags().StringArrayVar(&forceText, "force-text", nil, "treat files matching these patterns, like *.svg or *.ipynb, as text even if they look binary")
+	cmd.PersistentFlags().StringSliceVar(&textExtensions, "treat-as-text", nil, "treat files with these extensions, like .svg,.proto,.graphql, as text even if they look binary, regardless of case")
+	cmd.PersistentFlags().StringSliceVar(&binaryExtensions, "treat-as-binary", nil, "treat files with these extensions, like .pem,.min.js,.lock, as binary and leave them out even if they look like text, regardless of case; the treat-as-binary list of the config file adds to them. Takes precedence over --treat-as-text")
+	cmd.PersistentFlags().StringArrayVar(&forceBinary, "force-binary", nil, "treat files matching these patterns as binary and leave them out even if they look like text; takes precedence over --force-text")
+	cmd.PersistentFlags().BoolVar(&opts.transcode, "transcode", true, "detect UTF-16 and Latin-1 files and convert them to UTF-8; when disabled they're skipped as binary or emitted as-is")
+	cmd.PersistentFlags().Var(&opts.filters.Tests, "tests", "whether to "+filter.TestsInclude+" test files, "+filter.TestsExclude+" them, or include "+filter.TestsOnly+" them")
+	cmd.PersistentFlags().Var(&opts.filters.Hidden, "hidden", "whether to "+filter.HiddenInclude+" hidden files and folders, the ones starting with a dot like .github or .env, "+filter.HiddenSkip+" them, or include "+filter.HiddenOnly+" them; folders excluded by name, like .git, stay excluded")
+	cmd.PersistentFlags().BoolVar(&opts.noTests, "no-tests", false, "leave test files out, like *_test.go, *.spec.ts, test_*.py and the contents of __tests__/ and spec/; same as --tests "+filter.TestsExclude)
+	cmd.PersistentFlags().BoolVar(&opts.testsOnly, "tests-only", false, "only include test files, to review or extend a test suite; same as --tests "+filter.TestsOnly)
+	cmd.PersistentFlags().BoolVar(&opts.filters.WithTestedFiles, "with-tested-files", false, "with --tests-only, also include the files tests are named after, like foo.go for foo_test.go")
+	cmd.PersistentFlags().StringVar(&opts.filters.Preset, "preset", "", "also exclude a curated bundle of patterns: "+strings.Join(filter.PresetNames(), ", "))
+	cmd.PersistentFlags().BoolVar(&opts.trackedOnly, "tracked-only", false, "only include files tracked by git, like git ls-files lists them, leaving out build outputs and scratch files without listing patterns for them")
+	cmd.PersistentFlags().StringSliceVar(&opts.followImports, "follow-imports", nil, "only include the Go files reachable from these entry points, files or package directories below the directory, by following the imports within the module")
+	cmd.PersistentFlags().StringSliceVar(&opts.entries, "entry", nil, "only include the files reachable from these JavaScript or TypeScript modules, like src/index.ts, by following relative imports and the path aliases of tsconfig.json")
+	cmd.PersistentFlags().StringVar(&opts.filters.Owner, "codeowners", "", "only include the files owned by this team or user, like @org/backend, according to the CODEOWNERS file of the directory")
+	cmd.PersistentFlags().IntVar(&opts.filters.MaxDepth, "max-depth", 0, "only include files up to this many directory levels below the root, where 1 is the root itself; 0 means no limit")
+	cmd.PersistentFlags().BoolVar(&opts.filters.NoContextIgnore, "no-contextignore", false, "don't apply the exclusions in "+filter.ContextIgnoreFileName+" files")
+	opts.filters.MaxFileSize = 1 << 20
+	cmd.PersistentFlags().Var(&opts.filters.MaxFileSize, "max-file-size", "exclude files larger than this, like 512KB or 2MB; 0 means no limit")
+	cmd.PersistentFlags().IntVar(&opts.dataRows, "data-rows", 0, "only include the header and the first this many rows of CSV, TSV and JSON Lines files, ending them with a marker like \"… (12,340 more rows)\"; 0 includes every row")
+	cmd.PersistentFlags().BoolVar(&opts.summarizeConfig, "summarize-config-files", false, "replace JSON and YAML files larger than --summarize-over with the structure of the document: every path in it with its type and a sample value, to keep it visible at a fraction of the tokens")
+	opts.summarizeOver = 8 << 10
+	cmd.PersistentFlags().Var(&opts.summarizeOver, "summarize-over", "with --summarize-config-files, the size JSON and YAML files are summarized over, like 8KB or 1MB")
+	cmd.PersistentFlags().Var(&opts.largeSample, "sample-large-files", "include files over --max-file-size or --max-lines partially instead of leaving them out, keeping their first and last lines, like head:200,tail:50, with a marker telling how many lines were left out between")
+	cmd.PersistentFlags().IntVar(&opts.maxLines, "max-lines", 10000, "exclude files with more lines than this, like lockfiles; 0 means no limit")
+	cmd.PersistentFlags().IntVar(&opts.maxLineLength, "max-line-length", 0, "cut lines longer than this many bytes, like those of minified bundles or data files, ending them with "+truncationMarker+"; 0 means no limit")
+	cmd.PersistentFlags().BoolVar(&opts.includeGenerated, "include-generated", false, "include files that look generated or minified, like those with a \"Code generated\" header, a source map reference, very long lines or a content hash in their name")
+	cmd.PersistentFlags().BoolVar(&opts.filters.ReadmeFirst, "readme-first", false, "emit the README of every directory before its other files and folders, so it introduces them")
+	cmd.PersistentFlags().BoolVar(&opts.filters.SkipErrors, "skip-errors", true, "keep going past files and folders that can't be read, like those without permissions, and list them on stderr at the end instead of failing")
+	cmd.PersistentFlags().BoolVar(&opts.noWarnings, "no-warnings", false, "don't print warnings, like exclusions that matched nothing, to stderr")
+	cmd.PersistentFlags().BoolVar(&opts.quiet, "quiet", false, "print nothing to stderr but errors; implies --no-warnings")
+	cmd.PersistentFlags().BoolVar(&opts.verbose, "verbose", false, "log every decision of the filters and how long each file took to read to stderr")
+	cmd.PersistentFlags().Var(&opts.pathStyle, "path-style", "how to write the paths of files: "+pathStyleUnix+", with forward slashes on every platform, or "+pathStyleOS+", with the separator of the operating system")
+	cmd.PersistentFlags().BoolVar(&opts.anonymize, "anonymize", false, "replace what identifies the company behind the code, like organization names, domains and internal hostnames listed with --anonymize-term or in the anonymize section of the configuration file, with pseudonyms")
+	cmd.PersistentFlags().StringSliceVar(&opts.anonymizeTerms, "anonymize-term", nil, "with --anonymize, a term to replace wherever it appears, regardless of case, on top of the configuration file ones")
+	cmd.PersistentFlags().BoolVar(&opts.hashDirectories, "hash-directories", false, "with --anonymize, also replace the name of every directory in paths with a hash")
+	cmd.PersistentFlags().Var(&opts.onChange, "on-change", "what to do with files that change while being read: "+changedRetry+" reading them, then skip them; "+changedSkip+" them right away; or include them with a "+changedNote)
+	cmd.PersistentFlags().Int64Var(&opts.failOverTokens, "fail-over-tokens", 0, fmt.Sprintf("exit with code %d when the context is estimated at more than this many tokens, for CI checks; 0 means no limit", exitBudgetExceeded))
+	cmd.PersistentFlags().StringVar(&opts.store, "store", store.BackendFile, "where to keep state between runs, like the index searched by the search subcommand: "+strings.Join(store.Backends, ", "))
+	cmd.PersistentFlags().BoolVar(&opts.assertReadOnly, "assert-read-only", false, "fail instead of writing anything inside the scan root, like a checkpoint or the output itself, or running validator commands")
+	cmd.PersistentFlags().StringVar(&opts.filesFrom, "files-from", "", "emit only the files listed in this file, one path per line, or read them from stdin with \"-\"; relative paths are resolved against the directory and the walk filters don't apply")
+	cmd.PersistentFlags().Float64Var(&opts.filters.Sample.Fraction, "sample", 0, "only include a random fraction of the files, like 0.2; 0 or 1 includes them all")
+	cmd.PersistentFlags().Uint64Var(&opts.filters.Sample.Seed, "seed", 0, "seed for --sample, so runs with the same seed pick the same files; a random one is used and printed when not set")
+	cmd.PersistentFlags().Var(&opts.filters.Shard, "shard", "only include the files of one deterministic shard, like 2/8, to split generation across workers")
+	cmd.Flags().BoolVar(&opts.stripComments, "strip-comments", false, "remove comments from Go, JavaScript, TypeScript, Python, C-like, shell and YAML files to save tokens")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "print the tree of files that would be included, and why notable paths were left out, without emitting any content")
+	cmd.Flags().BoolVar(&opts.signaturesOnly, "signatures-only", false, "emit only the declarations and doc comments of supported languages (Go), without function bodies")
+	cmd.Flags().BoolVar(&opts.noHooks, "no-hooks", false, "don't run the pre and post hooks of the configuration file")
+	cmd.Flags().StringArrayVar(&opts.filterPlugins, "filter-plugin", nil, "leave out the files this executable or script rejects, like an organization policy, started once with the shell and sent a line of JSON with the path, size, language and content of each file, to answer with a line like {\"include\": false, \"reason\": \"holds PII\"}; repeat it to add more")
+	cmd.Flags().StringVar(&opts.captionCmd, "caption-cmd", "", "describe images, like PNG and JPEG files, with this shell command instead of leaving them out as binary files: it gets the image on stdin and its path in $CONTEXT_GENERATOR_FILE, like a script calling a captioning model, and the first line it prints becomes a placeholder like \"[image: a login form with two fields]\"")
+	cmd.Flags().StringArrayVar(&opts.transformCmds, "transform-cmd", nil, "pipe the contents of every file through this shell command, like 'prettier --stdin-filepath \"$CONTEXT_GENERATOR_FILE\"', using what it prints instead; files it fails on are left as is. Repeat it to chain commands, which run before the other transformations")
+	cmd.Flags().BoolVar(&opts.compact, "compact", false, "collapse runs of blank lines into one and trim trailing whitespace to save tokens")
+	cmd.Flags().StringArrayVar(&minify, "minify", nil, "drop blank lines and redundant whitespace, keeping comments, in files of supported languages (Go, JavaScript, TypeScript, Python) matching these patterns, like *.go, *.{js,ts} or src/**")
+	cmd.Flags().BoolVar(&opts.dedupe, "dedupe", false, "emit the contents of byte-identical files once, leaving the other copies with a note pointing to the first one")
+	cmd.Flags().BoolVar(&opts.collapseSimilar, "collapse-similar", false, "emit the contents of nearly identical files, like generated stubs or boilerplate, once, leaving the others with a note pointing to the first one")
+	cmd.Flags().Float64Var(&opts.similarity, "similarity", 0.9, "how alike, from 0 to 1, files must be for --collapse-similar to collapse them")
+	cmd.Flags().BoolVar(&opts.withMetadata, "with-metadata", false, "include the size, modification time, line count and language of each file in its header")
+	cmd.Flags().BoolVar(&opts.frontMatter, "front-matter", false, "start the context with a YAML block summarizing it: project, scan time, root, file count, token estimate and the settings used")
+	cmd.Flags().StringVar(&textSeparator, "separator", separator, "line enclosing the header of each file in the text format, and closing the context; empty leaves it out")
+	cmd.Flags().StringVar(&fileHeaderFormat, "file-header-format", "file: "+pathPlaceholder, "header naming each file in the text format, like \"### FILE: "+pathPlaceholder+"\", where "+pathPlaceholder+" is replaced with its path")
+	cmd.Flags().IntVar(&indent, "indent", 4, "spaces indenting each line of file contents in the text format; 0 saves tokens")
+	cmd.Flags().BoolVar(&opts.noSummary, "no-summary", false, "don't end text and markdown contexts with a line totaling the files, lines, bytes and estimated tokens in them")
+	cmd.Flags().BoolVar(&opts.exclusionSummary, "with-exclusion-summary", false, "end the context with how many paths each exclusion left out, so it's clear what the context doesn't show")
+	cmd.Flags().StringVar(&opts.summaryPath, "summary-md", "", "also write a short Markdown report of the run to this file, with counts, the directories using the most tokens, exclusions and warnings, to be posted as a pull request comment")
+	cmd.Flags().DurationVar(&opts.expires, "expires", 0, "record that the context expires after this long, like 24h, so the check subcommand and other consumers can warn about stale contexts")
+	cmd.Flags().StringVar(&opts.rev, "rev", "", "read the files as they are in this git revision, like a tag, branch or commit, instead of the working tree, without checking it out")
+	cmd.Flags().StringVar(&opts.fromArchive, "from-archive", "", "read the files from this zip or tar archive, compressed with gzip or not, instead of a directory, without extracting it; archives named *.zip, *.tar, *.tar.gz or *.tgz can be given as the argument too")
+	cmd.Flags().StringArrayVar(&opts.outputs, "output", nil, "also write the context to this file; repeat it to write to several files")
+	cmd.Flags().BoolVar(&opts.stdout, "stdout", true, "write the context to stdout; disable it with --stdout=false when writing to --output or --clipboard")
+	cmd.Flags().BoolVar(&opts.manifest, "manifest", false, "also write a JSON manifest listing the path, size and SHA-256 of every file in the context and the settings it was generated with, to regenerate or audit it later; it's named after the first --output, like context.manifest.json for context.md, or context.manifest.json when writing to stdout")
+	cmd.Flags().Var(&opts.upload, "upload", "upload the context to a secret GitHub gist, with "+uploadGist+" and GITHUB_TOKEN, or to the paste service at PASTE_URL, with "+uploadPaste+", printing its URL to stdout instead of the context, which is still written to --output")
+	cmd.Flags().BoolVar(&opts.clipboard, "clipboard", false, "also copy the context to the clipboard, with pbcopy, clip.exe, wl-copy, xclip or xsel")
+	cmd.Flags().BoolVar(&opts.printHash, "print-hash", false, "print a hash of the paths and contents of the files in the context to stdout instead of the context, which is still written to --output; timestamps and the format don't change it")
+	cmd.Flags().StringVar(&opts.checkHash, "check", "", "compare the context with the hash --print-hash saved in this file, failing when it changed, to catch stale contexts in CI; the context is still written to --output")
+	cmd.Flags().BoolVar(&opts.appendOutput, "append", false, "append to the --output files instead of replacing them, to build up a context across several runs")
+	cmd.Flags().StringVar(&opts.promptPreset, "prompt-preset", "", "wrap the context with the instructions and closing question of a prompt preset, like "+strings.Join(promptNames(nil), ", ")+", to paste it as is; see list-prompts")
+	cmd.Flags().BoolVar(&opts.withGitInfo, "with-git-info", false, "include the hash, author and date of the last commit changing each file in its header; the branch and commit checked out are recorded by --git-metadata")
+	cmd.Flags().BoolVar(&opts.gitMetadata, "git-metadata", true, "record the branch, commit, tag and whether the checkout is dirty in the header when the directory is in a git repository")
+	cmd.Flags().BoolVar(&opts.linkFiles, "link-files", false, "annotate each file with a permalink to it on the GitHub, GitLab or Bitbucket remote of the repository")
+	cmd.Flags().StringVar(&opts.format, "format", formatText, "output format: "+strings.Join(append(formats, formatChunksJSONL, formatSQLite, formatObsidian), ", "))
+	cmd.Flags().IntVar(&opts.chunkLines, "chunk-lines", 80, "with --format "+formatChunksJSONL+", how many lines each chunk holds at most")
+	cmd.Flags().IntVar(&opts.chunkOverlap, "chunk-overlap", 10, "with --format "+formatChunksJSONL+", how many lines each chunk repeats from the one before it")
+	cmd.Flags().StringVar(&opts.vault, "vault", "", "directory to write the notes of --format "+formatObsidian+" to, one per file plus an index note")
+	cmd.Flags().StringVar(&opts.label, "label", "", "label recorded in JSON output, used by merge to prefix the paths of this context")
+	cmd.Flags().BoolVar(&opts.useCache, "cache", false, "reuse the contents of unchanged files cached by previous runs instead of reading them again; the cache keeps a copy of every file read, secrets included, in the user cache directory until cleared with the cache clear subcommand")
+	cmd.Flags().Bool("no-cache", false, "read every file again instead of reusing the contents cached by previous runs")
+	cmd.Flags().MarkDeprecated("no-cache", "the cache is only used with --cache")
+	cmd.Flags().BoolVar(&opts.noIndex, "no-index", false, "don't save a copy of the context for the search subcommand")
+	cmd.Flags().StringVar(&opts.checkpointPath, "checkpoint", "", "record walk progress in this file so an interrupted run can be resumed")
+	cmd.Flags().BoolVar(&opts.resume, "resume", false, "resume an interrupted run from the file given to --checkpoint, emitting only the remaining files")
+
+	cmd.AddCommand(newStatsCommand(&opts))
+	cmd.AddCommand(newMergeCommand())
+	cmd.AddCommand(newSearchCommand(&opts))
+	cmd.AddCommand(newVerifyRedactionCommand())
+	cmd.AddCommand(newCheckCommand())
+	cmd.AddCommand(newDepsCommand(&opts))
+	cmd.AddCommand(newCacheCommand(&opts))
+	cmd.AddCommand(newExplainCommand(&opts))
+	cmd.AddCommand(newDiffCommand(&opts))
+	cmd.AddCommand(newPullRequestCommand(&opts))
+	cmd.AddCommand(newEmbedCommand(&opts))
+	cmd.AddCommand(newListPromptsCommand(&opts))
+	cmd.AddCommand(newListPresetsCommand(&opts))
+	cmd.AddCommand(newBenchCommand(&opts))
+	cmd.AddCommand(newAskCommand(&opts))
+	cmd.AddCommand(newManifestDiffCommand())
+	cmd.AddCommand(newVersionCommand())
+
+	registerCompletions(cmd, &opts)
 
 	return cmd
 }
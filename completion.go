@@ -0,0 +1,87 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/patrickdappollonio/context-generator/filter"
+	"github.com/patrickdappollonio/context-generator/internal/store"
+	"github.com/spf13/cobra"
+)
+
+// fixedCompletion completes a flag with a fixed list of values
+func fixedCompletion(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completePrompts completes --prompt-preset with the built-in presets and
+// the ones in the configuration file of the current directory
+func completePrompts(opts *options) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		var configured map[string]promptPreset
+		if cfg, err := loadConfig(opts.configPath, "."); err == nil {
+			configured = cfg.Prompts
+		}
+
+		return promptNames(configured), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeModules completes the arguments of deps with the modules the
+// go.mod of the current directory requires
+func completeModules(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	out, err := goCommand(cmd.Context(), ".", "list", "-m", "-f", "{{if not .Main}}{{.Path}}{{end}}", "all")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var modules []string
+	for _, path := range strings.Fields(string(out)) {
+		if strings.HasPrefix(path, toComplete) {
+			modules = append(modules, path)
+		}
+	}
+
+	return modules, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeRevisions completes --rev with the branches and tags of the
+// git repository holding the current directory
+func completeRevisions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	out, err := gitOutput(cmd.Context(), ".", "for-each-ref", "--format=%(refname:short)", "refs/heads", "refs/tags")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var refs []string
+	for _, ref := range strings.Fields(out) {
+		if strings.HasPrefix(ref, toComplete) {
+			refs = append(refs, ref)
+		}
+	}
+
+	return refs, cobra.ShellCompDirectiveNoFileComp
+}
+
+// registerCompletions wires the completion of flag values that can only
+// take some values, so the completion subcommand can offer them
+func registerCompletions(cmd *cobra.Command, opts *options) {
+	completions := map[string]func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective){
+		"format":        fixedCompletion(append(slices.Clone(formats), formatChunksJSONL, formatSQLite, formatObsidian)...),
+		"preset":        fixedCompletion(filter.PresetNames()...),
+		"tests":         fixedCompletion(filter.TestsInclude, filter.TestsExclude, filter.TestsOnly),
+		"hidden":        fixedCompletion(filter.HiddenInclude, filter.HiddenSkip, filter.HiddenOnly),
+		"on-change":     fixedCompletion(changedRetry, changedSkip, changedNote),
+		"path-style":    fixedCompletion(pathStyleUnix, pathStyleOS),
+		"store":         fixedCompletion(store.Backends...),
+		"prompt-preset": completePrompts(opts),
+		"rev":           completeRevisions,
+	}
+
+	for name, fn := range completions {
+		// Every flag listed exists, so registering can't fail
+		_ = cmd.RegisterFlagCompletionFunc(name, fn)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the name of the optional configuration file looked up
+// at the root of the scanned directory
+const configFileName = ".context-generator.yaml"
+
+// config is the configuration file, holding settings that are easier to
+// commit alongside the code than to pass as flags every time
+type config struct {
+	// Validators are checked against the content of every included file
+	Validators []validatorConfig `yaml:"validators"`
+
+	// Prompts replace or add to the presets of --prompt-preset
+	Prompts map[string]promptPreset `yaml:"prompts"`
+
+	// Text tunes the layout of the text format, unless overridden by
+	// flags
+	Text textLayout `yaml:"text"`
+
+	// Anonymize lists what --anonymize replaces
+	Anonymize anonymizeConfig `yaml:"anonymize"`
+
+	// TreatAsBinary adds to the extensions of --treat-as-binary, like the
+	// ones the project keeps certificates or lockfiles in
+	TreatAsBinary []string `yaml:"treat-as-binary"`
+
+	// Hooks run shell commands before and after a run
+	Hooks hooksConfig `yaml:"hooks"`
+
+	// Roots turn the context into one of several directories, each with
+	// its own rules, instead of the scanned one
+	Roots []workspaceRoot `yaml:"roots"`
+}
+
+// loadConfig reads the configuration file at path or, when path is
+// empty, the one at the root of the scanned directory if there's one
+func loadConfig(path, root string) (*config, error) {
+	explicit := path != ""
+	if !explicit {
+		// Only directories can hold a configuration file
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			return &config{}, nil
+		}
+
+		path = filepath.Join(root, configFileName)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return &config{}, nil
+		}
+
+		return nil, fmt.Errorf("error reading config file %q: %w", path, err)
+	}
+
+	var cfg config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing config file %q: %w", path, err)
+	}
+
+	return &cfg, nil
+}
+
+// loadRoot checks the scanned directory exists and applies its
+// configuration file, like its roots, so every command walking it sees
+// the same files. Commands calling run after it don't load it again.
+func (o *options) loadRoot() error {
+	if o.rootLoaded {
+		return nil
+	}
+
+	root, err := checkRoot(o.root)
+	if err != nil {
+		return err
+	}
+	o.root = root
+
+	if err := o.applyConfig(); err != nil {
+		return err
+	}
+
+	o.rootLoaded = true
+	return nil
+}
+
+// applyConfig loads the configuration file for the scanned directory
+// and applies its settings to the options
+func (o *options) applyConfig() error {
+	cfg, err := loadConfig(o.configPath, o.root)
+	if err != nil {
+		return err
+	}
+
+	validators, err := compileValidators(cfg.Validators)
+	if err != nil {
+		return fmt.Errorf("invalid validator in config file: %w", err)
+	}
+
+	o.validators = validators
+
+	binary, err := parseExtensions("treat-as-binary", cfg.TreatAsBinary)
+	if err != nil {
+		return fmt.Errorf("invalid treat-as-binary in config file: %w", err)
+	}
+
+	o.configBinaryExtensions = binary
+
+	if !o.noHooks {
+		o.hookConfig = cfg.Hooks
+	}
+
+	// Roots are relative to the configuration file
+	base := o.root
+	if o.configPath != "" {
+		base = filepath.Dir(o.configPath)
+	}
+
+	if err := o.setWorkspace(base, cfg.Roots); err != nil {
+		return err
+	}
+
+	o.layout.merge(cfg.Text)
+	if err := o.layout.validate(); err != nil {
+		return fmt.Errorf("invalid text layout: %w", err)
+	}
+
+	if o.anonymize {
+		terms := append(slices.Clone(cfg.Anonymize.Terms), o.anonymizeTerms...)
+		hashDirs := o.hashDirectories || cfg.Anonymize.HashDirectories
+		if len(terms) == 0 && !hashDirs {
+			return fmt.Errorf("flag --anonymize needs terms to replace, given with --anonymize-term or in the anonymize section of the config file, or --hash-directories")
+		}
+
+		o.anonymizer = newAnonymizer(terms, hashDirs)
+	}
+
+	if o.promptPreset != "" {
+		p, err := lookupPrompt(o.promptPreset, cfg.Prompts)
+		if err != nil {
+			return err
+		}
+
+		o.prompt = &p
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// formatSQLite writes a SQLite database with full-text search rather
+// than a stream, so it's only available when generating a context to a
+// single --output file
+const formatSQLite = "sqlite"
+
+// corpusSchema creates the tables of a corpus database: the files, a
+// full-text index of their paths and contents, and the context they came
+// from as key and value pairs
+var corpusSchema = []string{
+	`CREATE TABLE files (id INTEGER PRIMARY KEY, path TEXT NOT NULL UNIQUE, content TEXT NOT NULL, language TEXT NOT NULL, size INTEGER NOT NULL, sha256 TEXT NOT NULL, identical_to TEXT, similar_to TEXT, scanned_at TEXT NOT NULL)`,
+	`CREATE VIRTUAL TABLE files_fts USING fts5(path, content, content='files', content_rowid='id')`,
+	`CREATE TABLE context (key TEXT PRIMARY KEY, value TEXT NOT NULL)`,
+}
+
+// sqliteWriter stores a context in a new SQLite database, searchable
+// right away with queries like:
+//
+//	SELECT path FROM files_fts WHERE files_fts MATCH 'token'
+//
+// Every file is written in a single transaction, committed when the
+// context is closed.
+type sqliteWriter struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	path    string
+	scanned string
+}
+
+// newSQLiteWriter creates the database at path, replacing any file there,
+// and records the header of the context in it
+func newSQLiteWriter(path string, header contextHeader) (*sqliteWriter, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error replacing database %q: %w", path, err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database %q: %w", path, err)
+	}
+
+	s := &sqliteWriter{db: db, path: path, scanned: time.Now().UTC().Format(time.RFC3339)}
+	if err := s.prepare(header); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error preparing database %q: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// prepare creates the tables and starts the transaction files are
+// written in
+func (s *sqliteWriter) prepare(header contextHeader) error {
+	for _, stmt := range corpusSchema {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	s.tx = tx
+
+	meta := map[string]string{
+		"label":      header.Label,
+		"root":       header.Root,
+		"scanned_at": s.scanned,
+	}
+	if header.Expires != nil {
+		meta["expires"] = header.Expires.Format(time.RFC3339)
+	}
+	if g := header.Git; g != nil {
+		meta["git_commit"], meta["git_branch"], meta["git_tag"] = g.Commit, g.Branch, g.Tag
+	}
+
+	for key, value := range meta {
+		if value == "" {
+			continue
+		}
+
+		if _, err := tx.Exec(`INSERT INTO context (key, value) VALUES (?, ?)`, key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqliteWriter) writeFile(f contextFile) error {
+	res, err := s.tx.Exec(`INSERT INTO files (path, content, language, size, sha256, identical_to, similar_to, scanned_at) VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?)`,
+		f.Path, f.Content, detectLanguage(f.Path), f.Size, f.SHA256, f.IdenticalTo, f.SimilarTo, s.scanned)
+	if err != nil {
+		return fmt.Errorf("error saving file %q to database %q: %w", f.Path, s.path, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error saving file %q to database %q: %w", f.Path, s.path, err)
+	}
+
+	if _, err := s.tx.Exec(`INSERT INTO files_fts (rowid, path, content) VALUES (?, ?, ?)`, id, f.Path, f.Content); err != nil {
+		return fmt.Errorf("error indexing file %q in database %q: %w", f.Path, s.path, err)
+	}
+
+	return nil
+}
+
+func (s *sqliteWriter) close() error {
+	if err := s.tx.Commit(); err != nil {
+		s.db.Close()
+		return fmt.Errorf("error saving database %q: %w", s.path, err)
+	}
+
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("error closing database %q: %w", s.path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import "github.com/patrickdappollonio/context-generator/internal/similar"
+
+// duplicateFinder remembers the first file seen with each content hash,
+// so byte-identical copies, like vendored licenses, are emitted once. A
+// nil duplicateFinder finds no duplicates.
+type duplicateFinder map[string]string
+
+// newDuplicateFinder returns a finder knowing about the files in
+// entries, like those emitted before a run was resumed
+func newDuplicateFinder(entries []manifestEntry) duplicateFinder {
+	d := make(duplicateFinder)
+	for _, e := range entries {
+		d.original(e)
+	}
+
+	return d
+}
+
+// original returns the path of the first file identical to the one in
+// entry, or an empty string when it's the first one seen
+func (d duplicateFinder) original(entry manifestEntry) string {
+	// Empty files are all identical but cost nothing to emit
+	if d == nil || entry.SHA256 == "" || entry.Size == 0 {
+		return ""
+	}
+
+	if path, found := d[entry.SHA256]; found {
+		return path
+	}
+
+	d[entry.SHA256] = entry.Path
+	return ""
+}
+
+// dedupe drops the contents of f when an identical file was already
+// emitted, pointing to it instead
+func (d duplicateFinder) dedupe(f *contextFile) {
+	if original := d.original(f.manifestEntry); original != "" {
+		f.IdenticalTo = original
+		f.Content = ""
+	}
+}
+
+// similarFinder collapses files nearly the same as one already emitted,
+// like generated stubs, into a note pointing to it. A nil similarFinder
+// collapses nothing.
+type similarFinder struct {
+	index *similar.Index
+}
+
+// newSimilarFinder returns a finder collapsing files at least threshold
+// alike, between 0 and 1
+func newSimilarFinder(threshold float64) *similarFinder {
+	return &similarFinder{index: similar.NewIndex(threshold)}
+}
+
+// collapse drops the contents of f when a file similar enough to it was
+// already emitted, pointing to it instead
+func (s *similarFinder) collapse(f *contextFile) {
+	if s == nil || f.collapsed() {
+		return
+	}
+
+	if original, score := s.index.Add(f.Path, []byte(f.Content)); original != "" {
+		f.SimilarTo, f.Similarity = original, score
+		f.Content = ""
+	}
+}
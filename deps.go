@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// goModule is the part of the output of "go list -m -json" describing
+// where the sources of a module are
+type goModule struct {
+	Path    string
+	Version string
+	Dir     string
+	Replace *goModule
+}
+
+// goCommand runs the go command with args in dir and returns its output
+func goCommand(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("go %s: %s", strings.Join(args, " "), msg)
+		}
+
+		// Not wrapped, so the exit code of go isn't taken for one of ours
+		return nil, fmt.Errorf("go %s: %v", strings.Join(args, " "), err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// listGoModules resolves the given module paths with the go.mod of the
+// project in dir, finding where their sources are in the module cache
+func listGoModules(ctx context.Context, dir string, paths []string) ([]goModule, error) {
+	out, err := goCommand(ctx, dir, append([]string{"list", "-m", "-json"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []goModule
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var m goModule
+		if err := dec.Decode(&m); err != nil {
+			if err == io.EOF {
+				return modules, nil
+			}
+
+			return nil, fmt.Errorf("error reading go list output: %w", err)
+		}
+
+		// Replaced modules keep their sources elsewhere
+		if m.Replace != nil {
+			m.Version, m.Dir = m.Replace.Version, m.Replace.Dir
+		}
+
+		if m.Dir == "" {
+			return nil, fmt.Errorf("module %s isn't downloaded, run \"go mod download %s\" first", m.Path, m.Path)
+		}
+
+		modules = append(modules, m)
+	}
+}
+
+// depsGlobs returns the globs selecting the sources of modules, relative
+// to the module cache at modCache
+func depsGlobs(modCache string, modules []goModule) ([]string, error) {
+	globs := make([]string, 0, len(modules))
+
+	for _, m := range modules {
+		rel, err := filepath.Rel(modCache, m.Dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("module %s is replaced with %q, outside the module cache; generate a context from that directory instead", m.Path, m.Dir)
+		}
+
+		globs = append(globs, filepath.ToSlash(rel)+"/**")
+	}
+
+	return globs, nil
+}
+
+func newDepsCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deps module...",
+		Short: "Generate a context from the sources of Go dependencies, as resolved by the go.mod in the current directory",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.filesFrom != "" {
+				return fmt.Errorf("flag --files-from can't be used with deps")
+			}
+
+			modules, err := listGoModules(cmd.Context(), ".", args)
+			if err != nil {
+				return err
+			}
+
+			out, err := goCommand(cmd.Context(), ".", "env", "GOMODCACHE")
+			if err != nil {
+				return err
+			}
+			modCache := strings.TrimSpace(string(out))
+
+			// Scan the module cache, restricted to the modules' sources, so
+			// every module ends up in a single context
+			globs, err := depsGlobs(modCache, modules)
+			if err != nil {
+				return err
+			}
+
+			opts.root = modCache
+			opts.filters.Globs = globs
+
+			return run(cmd.Context(), *opts, os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.format, "format", formatText, "output format: "+strings.Join(formats, ", "))
+	_ = cmd.RegisterFlagCompletionFunc("format", fixedCompletion(formats...))
+	cmd.ValidArgsFunction = completeModules
+
+	return cmd
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// detector finds one kind of secret or personal information in a line
+// of text
+type detector struct {
+	// name identifies the detector in findings and flags
+	name string
+
+	// pattern matches the sensitive value
+	pattern *regexp.Regexp
+
+	// validate, if set, filters out matches that only look sensitive,
+	// like numbers that fail a checksum
+	validate func(match string) bool
+}
+
+// detectors lists every built-in secret and personal information detector
+var detectors = []detector{
+	{name: "private-key", pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----`)},
+	{name: "aws-access-key", pattern: regexp.MustCompile(`\b(AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA)[A-Z0-9]{16}\b`)},
+	{name: "github-token", pattern: regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{60,})\b`)},
+	{name: "gitlab-token", pattern: regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`)},
+	{name: "slack-token", pattern: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{name: "stripe-key", pattern: regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{20,}\b`)},
+	{name: "google-api-key", pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{name: "openai-key", pattern: regexp.MustCompile(`\bsk-(proj-)?[A-Za-z0-9_-]{32,}\b`)},
+	{name: "jwt", pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\b`)},
+	{name: "url-credentials", pattern: regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^\s:/@]+:[^\s:/@]+@[^\s/]+`)},
+	{name: "generic-secret", pattern: regexp.MustCompile(`(?i)\b(api[_-]?key|secret|passwd|password|access[_-]?token|auth[_-]?token)\b["']?\s*[:=]\s*["'][^"'\s]{8,}["']`)},
+	{name: "email", pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{name: "us-ssn", pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{name: "credit-card", pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), validate: luhnValid},
+}
+
+// finding is a single match of a detector
+type finding struct {
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	Detector string `json:"detector"`
+	Excerpt  string `json:"excerpt"`
+}
+
+// detectInLine runs every enabled detector against a line and calls fn
+// with the detector name and the masked value of each match
+func detectInLine(line string, skip []string, fn func(name, masked string)) {
+	for _, d := range detectors {
+		if contains(skip, d.name) {
+			continue
+		}
+
+		for _, match := range d.pattern.FindAllString(line, -1) {
+			if d.validate != nil && !d.validate(match) {
+				continue
+			}
+
+			fn(d.name, maskSecret(match))
+		}
+	}
+}
+
+// maskSecret hides most of a sensitive value so reporting it doesn't leak
+// it, keeping just enough to locate it
+func maskSecret(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+
+	return s[:4] + strings.Repeat("*", len(s)-4)
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum used
+// by credit card numbers
+func luhnValid(s string) bool {
+	sum, double, digits := 0, false, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+
+		sum += d
+		double = !double
+		digits++
+	}
+
+	return digits >= 13 && sum%10 == 0
+}
+
+// detectorNames returns the names of all built-in detectors
+func detectorNames() []string {
+	names := make([]string, 0, len(detectors))
+	for _, d := range detectors {
+		names = append(names, d.name)
+	}
+
+	return names
+}
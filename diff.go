@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/patrickdappollonio/context-generator/filter"
+	"github.com/spf13/cobra"
+)
+
+// Changes a file can go through between two revisions, as shown in the
+// header of the file in a diff context
+const (
+	changeAdded    = "added"
+	changeModified = "modified"
+	changeDeleted  = "deleted"
+	changeRenamed  = "renamed from "
+)
+
+// diffFormats lists the output formats of the diff subcommand, the ones
+// able to tell a diff apart from the contents of a file
+var diffFormats = []string{formatText, formatPlainCompact, formatMarkdown, formatJSON, formatJSONL}
+
+// changedFile is a file changed between the two sides of a revision
+// range, relative to the directory the diff is made in
+type changedFile struct {
+	status  byte
+	path    string
+	oldPath string
+}
+
+// listChanges lists the files changed in the revision range rev, in any
+// form git diff takes, like main..feature, main...feature or a single
+// revision compared against the working tree
+func listChanges(ctx context.Context, root, rev string) ([]changedFile, error) {
+	out, err := gitBytes(ctx, root, "-c", "core.quotepath=off", "diff", "--name-status", "-z", "-M", "--relative", rev, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+
+	var changes []changedFile
+	for i := 0; i+1 < len(fields); i += 2 {
+		c := changedFile{status: fields[i][0], path: fields[i+1]}
+
+		// Renames and copies list the old path before the new one
+		if c.status == 'R' || c.status == 'C' {
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("error reading git diff: missing new path for %q", c.path)
+			}
+
+			c.oldPath, c.path = c.path, fields[i+2]
+			i++
+		}
+
+		changes = append(changes, c)
+	}
+
+	return changes, nil
+}
+
+// gitBytes runs git with args in dir and returns its output untouched,
+// unlike gitOutput, for when it's the contents of a file
+func gitBytes(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+		}
+
+		// Not wrapped, so the exit code of git isn't taken for one of ours
+		return nil, fmt.Errorf("git %s: %v", strings.Join(args, " "), err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// headRevision returns the revision on the new side of the range rev, or
+// an empty string when it's the working tree
+func headRevision(rev string) string {
+	i := strings.LastIndex(rev, "..")
+	if i < 0 {
+		return ""
+	}
+
+	if head := rev[i+2:]; head != "" {
+		return head
+	}
+
+	return "HEAD"
+}
+
+// diffFile builds the entry of a changed file: the unified diff of the
+// changes, or all of it when the file is new. It returns false for files
+// that shouldn't be shown, like binary ones.
+func diffFile(ctx context.Context, root, rev string, c changedFile, opts options) (contextFile, bool, error) {
+	path := filepath.Join(root, filepath.FromSlash(c.path))
+	f := contextFile{manifestEntry: manifestEntry{Path: opts.displayPath(path)}}
+
+	switch c.status {
+	case 'A':
+		content, err := readRevisionFile(ctx, root, headRevision(rev), c.path)
+		if err != nil {
+			return contextFile{}, false, err
+		}
+
+		encoding, err := sniffEncoding(bytes.NewReader(content), false)
+		if err != nil {
+			return contextFile{}, false, err
+		}
+
+		if opts.overrideEncoding(root, path, encoding) == "" {
+			return contextFile{}, false, nil
+		}
+
+		f.Change, f.Content = changeAdded, string(content)
+	case 'D':
+		f.Change = changeDeleted
+	default:
+		paths := []string{c.path}
+		f.Change = changeModified
+		if c.oldPath != "" {
+			paths = append(paths, c.oldPath)
+			f.Change = changeRenamed + opts.displayPath(filepath.Join(root, filepath.FromSlash(c.oldPath)))
+		}
+
+		out, err := gitBytes(ctx, root, append([]string{"diff", "-M", "--relative", "--no-color", rev, "--"}, paths...)...)
+		if err != nil {
+			return contextFile{}, false, err
+		}
+
+		// Renames without changes have nothing to show but the rename
+		f.Diff, f.Content = len(out) > 0, string(out)
+	}
+
+	return f, true, nil
+}
+
+// readRevisionFile reads the file at path, relative to root, as it is in
+// rev, or in the working tree when rev is empty
+func readRevisionFile(ctx context.Context, root, rev, path string) ([]byte, error) {
+	if rev == "" {
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil {
+			return nil, fmt.Errorf("error reading file %q: %w", path, err)
+		}
+
+		return content, nil
+	}
+
+	return gitBytes(ctx, root, "show", rev+":./"+path)
+}
+
+// leftOut reports whether the filters exclude the file at path, or a
+// folder holding it, according to the decisions of a walk of root
+func leftOut(root, path string, decisions map[string]filter.Decision) bool {
+	for p := path; p != root && p != filepath.Dir(p); p = filepath.Dir(p) {
+		if d, found := decisions[p]; found && !d.Included {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeDiff writes a context of the files changed in the revision range
+// rev to w, leaving out the ones the filters exclude in the working tree
+func writeDiff(ctx context.Context, w io.Writer, root, rev string, opts options) error {
+	changes, err := listChanges(ctx, root, rev)
+	if err != nil {
+		return err
+	}
+
+	if err := opts.restrictWalk(ctx, root); err != nil {
+		return err
+	}
+
+	decisions, err := filter.Simulate(ctx, root, opts.filters)
+	if err != nil {
+		return err
+	}
+
+	byPath := make(map[string]filter.Decision, len(decisions))
+	for _, d := range decisions {
+		byPath[d.Path] = d
+	}
+
+	cw, err := newContextWriter(opts.format, w, contextHeader{Label: opts.label, Root: root}, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		if leftOut(root, filepath.Join(root, filepath.FromSlash(c.path)), byPath) {
+			continue
+		}
+
+		f, ok, err := diffFile(ctx, root, rev, c, opts)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			warnf(opts, "skipped %q, it's a binary file", c.path)
+			continue
+		}
+
+		if err := cw.writeFile(f); err != nil {
+			return err
+		}
+	}
+
+	return cw.close()
+}
+
+func newDiffCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff revision-range",
+		Short: "Generate a context of the changes between two git revisions, like main..feature, with the diff of changed files and all of new ones, for code review prompts",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(diffFormats, opts.format) {
+				return fmt.Errorf("diff can only be generated with --format %s", strings.Join(diffFormats, ", "))
+			}
+
+			root, err := checkRoot(opts.root)
+			if err != nil {
+				return err
+			}
+
+			return writeDiff(cmd.Context(), cmd.OutOrStdout(), root, args[0], *opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.format, "format", formatText, "output format: "+strings.Join(diffFormats, ", "))
+	_ = cmd.RegisterFlagCompletionFunc("format", fixedCompletion(diffFormats...))
+	cmd.ValidArgsFunction = completeRevisions
+
+	return cmd
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dryRun prints the tree of files a context would include, along with
+// the notable paths the filters left out and what the files add up to by
+// language, without emitting any content
+func dryRun(ctx context.Context, opts options, w io.Writer) error {
+	if err := opts.loadRoot(); err != nil {
+		return err
+	}
+
+	// Show the tree of every root of the workspace in turn
+	roots, err := opts.walkRoots(ctx)
+	if err != nil {
+		return err
+	}
+
+	stats := &contextStats{}
+	for i, ro := range roots {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		if err := dryRunRoot(ctx, ro, w, stats); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d files would be included\n\n", len(stats.files))
+	return stats.printLanguages(w)
+}
+
+// dryRunRoot prints the tree of files of the directory opts walks,
+// adding the ones that would be included to stats
+func dryRunRoot(ctx context.Context, opts options, w io.Writer, stats *contextStats) error {
+	root := opts.root
+
+	var paths []string
+	notes := make(map[string]string)
+
+	var skipped skippedFiles
+	report, err := walkFiles(ctx, root, opts, func(path string, info os.FileInfo) error {
+		// Only text files end up in the context
+		encoding, err := fileEncoding(root, path, opts)
+		if err != nil {
+			return skipped.skip(opts, path, err)
+		}
+
+		if encoding == "" {
+			return nil
+		}
+
+		// Show files that look generated in the tree, with the heuristic
+		// that fired
+		if !opts.includeGenerated {
+			heuristic, err := sniffGenerated(root, path, opts)
+			if err != nil {
+				return skipped.skip(opts, path, err)
+			}
+
+			if heuristic != "" {
+				notes[path] = "skipped: likely generated, " + heuristic
+				paths = append(paths, path)
+				return nil
+			}
+		}
+
+		// Point out files that will be converted to UTF-8
+		if encoding != encodingUTF8 {
+			notes[path] = "encoding: " + encoding + ", transcoded to utf-8"
+		}
+
+		lines, err := countFileLines(root, path, opts)
+		if err != nil {
+			return skipped.skip(opts, path, err)
+		}
+
+		if opts.overMaxLines(lines) {
+			notes[path] = fmt.Sprintf("skipped: %d lines, excluded by %s", lines, opts.maxLinesExclusion())
+			paths = append(paths, path)
+			return nil
+		}
+
+		stats.files = append(stats.files, fileStat{
+			path:     path,
+			language: detectLanguage(path),
+			size:     info.Size(),
+			lines:    lines,
+		})
+		stats.size += info.Size()
+
+		paths = append(paths, path)
+		return nil
+	})
+	if ctx.Err() != nil {
+		return fmt.Errorf("dry run interrupted: %w", ctx.Err())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Show the pruned directories in the tree too, marked with the reason
+	dirs := make(map[string]bool, len(report.Notes))
+	for _, n := range report.Notes {
+		notes[n.Path] = "skipped: " + n.Reason
+		dirs[n.Path] = true
+		paths = append(paths, n.Path)
+	}
+
+	sort.SliceStable(paths, func(i, j int) bool {
+		return opts.filters.Less(paths[i], paths[j])
+	})
+
+	// The files of a root with a prefix start with it in the context
+	title := root
+	if opts.rootPrefix != "" {
+		title += " (as " + opts.rootPrefix + "/)"
+	}
+
+	printTree(w, root, title, paths, dirs, notes)
+
+	warnUnmatched(opts, report.Excluded)
+	warnSkipped(opts, report.Skipped, skipped)
+	return nil
+}
+
+// treeNode is a directory or file in a tree of paths
+type treeNode struct {
+	name     string
+	path     string
+	note     string
+	dir      bool
+	children []*treeNode
+	index    map[string]*treeNode
+}
+
+// child returns the child with the given name, creating it if needed
+func (n *treeNode) child(name string) *treeNode {
+	if c, found := n.index[name]; found {
+		return c
+	}
+
+	c := &treeNode{name: name, index: make(map[string]*treeNode)}
+	n.children = append(n.children, c)
+	n.index[name] = c
+
+	return c
+}
+
+// isDir reports whether the node should be shown as a directory
+func (n *treeNode) isDir() bool {
+	return n.dir || len(n.children) > 0
+}
+
+// buildTree arranges paths, which must be sorted in walk order, in a
+// tree rooted at root. Every node keeps the full path it stands for.
+func buildTree(root string, paths []string) (*treeNode, map[string]*treeNode) {
+	top := &treeNode{name: root, path: root, index: make(map[string]*treeNode)}
+	nodes := make(map[string]*treeNode, len(paths))
+
+	for _, path := range paths {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = path
+		}
+
+		node := top
+		for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+			node = node.child(part)
+		}
+
+		node.path = path
+		nodes[path] = node
+	}
+
+	return top, nodes
+}
+
+// printTree prints paths, which must be sorted in walk order, as a tree
+// rooted at root and headed by title. Paths in dirs are shown as
+// directories even if they have no children, and paths with a note are
+// printed along with it.
+func printTree(w io.Writer, root, title string, paths []string, dirs map[string]bool, notes map[string]string) {
+	top, nodes := buildTree(root, paths)
+
+	for path, node := range nodes {
+		node.note = notes[path]
+		node.dir = dirs[path]
+	}
+
+	fmt.Fprintln(w, title)
+	printTreeChildren(w, top, "")
+}
+
+func printTreeChildren(w io.Writer, n *treeNode, prefix string) {
+	for i, c := range n.children {
+		branch, indent := "├── ", "│   "
+		if i == len(n.children)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		name := c.name
+		if c.isDir() {
+			name += "/"
+		}
+
+		if c.note != "" {
+			name += " (" + c.note + ")"
+		}
+
+		fmt.Fprintln(w, prefix+branch+name)
+		printTreeChildren(w, c, prefix+indent)
+	}
+}
@@ -0,0 +1,947 @@
+#!/usr/bin/env bash
+#
+# Runs the end-to-end tests: builds the binary and checks real invocations
+# of it against the fixture trees in e2e/testdata, covering flags, exit
+# codes, what goes to stdout and stderr, and the output formats.
+#
+# Usage: e2e/run.sh [name-filter]
+
+set -uo pipefail
+
+cd "$(dirname "$0")/.."
+
+work="$(mktemp -d)"
+trap 'rm -rf "$work"' EXIT
+
+bin="$work/context-generator"
+if ! go build -o "$bin" .; then
+  echo "FAIL: unable to build the binary" >&2
+  exit 1
+fi
+
+# Keep the index and the cache of the runs away from the user's own
+export XDG_CACHE_HOME="$work/cache"
+export HOME="$work/home"
+
+tree="e2e/testdata/tree"
+filter="${1:-}"
+failed=0
+passed=0
+
+# run invokes the binary, keeping its stdout, stderr and exit code for the
+# assertions that follow
+run() {
+  "$bin" "$@" >"$work/stdout" 2>"$work/stderr"
+  code=$?
+}
+
+fail() {
+  echo "    $*" >&2
+  case_failed=1
+}
+
+expect_code() {
+  [ "$code" -eq "$1" ] || fail "expected exit code $1, got $code; stderr: $(cat "$work/stderr")"
+}
+
+expect_stdout() {
+  grep -qF -- "$1" "$work/stdout" || fail "expected stdout to contain \"$1\""
+}
+
+expect_no_stdout() {
+  ! grep -qF -- "$1" "$work/stdout" || fail "expected stdout not to contain \"$1\""
+}
+
+expect_stderr() {
+  grep -qF -- "$1" "$work/stderr" || fail "expected stderr to contain \"$1\""
+}
+
+expect_empty_stderr() {
+  [ ! -s "$work/stderr" ] || fail "expected stderr to be empty, got: $(cat "$work/stderr")"
+}
+
+# check runs a single test case, a function named after it
+check() {
+  local name="$1"
+  if [ -n "$filter" ] && [[ "$name" != *"$filter"* ]]; then
+    return
+  fi
+
+  case_failed=0
+  "test_$name"
+
+  if [ "$case_failed" -eq 0 ]; then
+    passed=$((passed + 1))
+    echo "ok   $name"
+  else
+    failed=$((failed + 1))
+    echo "FAIL $name"
+  fi
+}
+
+test_text_output() {
+  run "$tree" --git-metadata=false
+  expect_code 0
+  expect_stdout "file: $tree/src/main.go"
+  expect_stdout "func main() {}"
+  expect_no_stdout "node_modules"
+  expect_empty_stderr
+}
+
+test_summary_footer() {
+  run "$tree"
+  expect_code 0
+  expect_stdout "Context total: 3 files"
+
+  run "$tree" --no-summary
+  expect_code 0
+  expect_no_stdout "Context total:"
+
+  local dir="$work/tiny"
+  mkdir -p "$dir"
+  echo "a" >"$dir/a.txt"
+  run "$dir" --git-metadata=false
+  expect_code 0
+  expect_stdout "Context total: 1 file, 1 line, 2 B, ~1 token"
+
+  # Tokens are estimated once from every byte, like stats does, rather
+  # than rounded up file by file
+  echo "abcd" >"$dir/b.txt"
+  echo "efgh" >"$dir/c.txt"
+  echo "ijkl" >"$dir/d.txt"
+  rm "$dir/a.txt"
+  run "$dir" --git-metadata=false --fail-over-tokens 4
+  expect_code 0
+  expect_stdout "Context total: 3 files, 3 lines, 15 B, ~4 tokens"
+
+  run stats "$dir"
+  expect_code 0
+  expect_stdout "Estimated tokens:  4"
+}
+
+test_json_output() {
+  run "$tree" --git-metadata=false --format json
+  expect_code 0
+  expect_stdout '"path":'
+  python3 -m json.tool "$work/stdout" >/dev/null 2>&1 || fail "expected stdout to be valid JSON"
+}
+
+test_plain_compact_output() {
+  run "$tree" --git-metadata=false --format plain-compact
+  expect_code 0
+  expect_stdout "== $tree/src/main.go =="
+  grep -qx "func main() {}" "$work/stdout" || fail "expected stdout to have unindented contents"
+  expect_no_stdout "--------------------"
+
+  # Nothing tells its headers apart from contents, so it isn't read back
+  cp "$work/stdout" "$work/compact.txt"
+  run merge "$work/compact.txt"
+  expect_code 1
+  expect_stderr "plain-compact contexts can't be read back"
+
+  run search main --context "$work/compact.txt"
+  expect_code 1
+  expect_stderr "plain-compact contexts can't be read back"
+}
+
+test_chunks_output() {
+  run "$tree" --git-metadata=false --format chunks-jsonl --chunk-lines 2 --chunk-overlap 1
+  expect_code 0
+  expect_stdout '"path":"'"$tree"'/src/main.go","chunk":1,"start_line":2,"end_line":3'
+  python3 -c 'import json, sys; [json.loads(l) for l in sys.stdin]' <"$work/stdout" || fail "expected every line of stdout to be valid JSON"
+
+  run "$tree" --chunk-lines 2
+  expect_code 1
+  expect_stderr "can only be used with --format chunks-jsonl"
+}
+
+test_sqlite_output() {
+  run "$tree" --git-metadata=false --format sqlite --output "$work/corpus.db"
+  expect_code 0
+  [ "$(python3 -c 'import sqlite3, sys; print(sqlite3.connect(sys.argv[1]).execute("SELECT path FROM files_fts WHERE files_fts MATCH ?", ("TestMain",)).fetchone()[0])' "$work/corpus.db")" = "$tree/src/main_test.go" ] || fail "expected the full-text index to find main_test.go"
+
+  run "$tree" --format sqlite
+  expect_code 1
+  expect_stderr "requires a single --output"
+}
+
+test_markdown_output() {
+  run "$tree" --git-metadata=false --format markdown
+  expect_code 0
+  expect_stdout '```go'
+}
+
+test_max_line_length() {
+  local dir="$work/long"
+  rm -rf "$dir"
+  mkdir -p "$dir"
+  head -c 100000 /dev/zero | tr '\0' 'a' >"$dir/data.txt"
+  echo >>"$dir/data.txt"
+
+  run "$dir" --git-metadata=false --include-generated --max-line-length 10
+  expect_code 0
+  expect_stdout "    aaaaaaaaaa… [99990 bytes truncated]"
+  expect_stderr "truncated 1 line"
+}
+
+test_anonymize() {
+  run "$tree" --git-metadata=false --anonymize --anonymize-term fixture --hash-directories
+  expect_code 0
+  expect_no_stdout "Fixture"
+  expect_no_stdout "src/"
+  expect_stdout "/main.go"
+
+  run "$tree" --anonymize
+  expect_code 1
+  expect_stderr "needs terms to replace"
+}
+
+test_hidden() {
+  local dir="$work/hidden"
+  rm -rf "$dir"
+  mkdir -p "$dir/.github/workflows"
+  echo "on: push" >"$dir/.github/workflows/ci.yml"
+  echo "visible" >"$dir/visible.txt"
+
+  run "$dir" --git-metadata=false --hidden skip
+  expect_code 0
+  expect_stdout "visible.txt"
+  expect_no_stdout "ci.yml"
+
+  run "$dir" --git-metadata=false --hidden only
+  expect_code 0
+  expect_stdout "ci.yml"
+  expect_no_stdout "visible.txt"
+}
+
+test_codeowners() {
+  local dir="$work/codeowners"
+  rm -rf "$dir"
+  mkdir -p "$dir/.github" "$dir/api/internal" "$dir/web" "$dir/docs/guides"
+  printf '* @org/everyone\n/api/ @org/backend\n/api/internal/legacy.go @org/legacy\ndocs/* @org/backend\n' >"$dir/.github/CODEOWNERS"
+  echo "package api" >"$dir/api/server.go"
+  echo "package internal" >"$dir/api/internal/db.go"
+  echo "package internal" >"$dir/api/internal/legacy.go"
+  echo "<html></html>" >"$dir/web/index.html"
+  echo "# docs" >"$dir/docs/overview.md"
+  echo "# setup" >"$dir/docs/guides/setup.md"
+
+  run "$dir" --git-metadata=false --codeowners @Org/Backend
+  expect_code 0
+  expect_stdout "server.go"
+  expect_stdout "db.go"
+  expect_stdout "overview.md"
+  expect_no_stdout "legacy.go"
+  expect_no_stdout "index.html"
+  expect_no_stdout "setup.md"
+
+  rm "$dir/.github/CODEOWNERS"
+  run "$dir" --git-metadata=false --codeowners @org/backend
+  expect_code 1
+  expect_stderr "requires a CODEOWNERS file"
+}
+
+test_workspace() {
+  local dir="$work/workspace"
+  rm -rf "$dir"
+  mkdir -p "$dir/ws" "$dir/api/vendor" "$dir/web"
+  echo "package api" >"$dir/api/server.go"
+  echo "package vendored" >"$dir/api/vendor/lib.go"
+  echo "# api" >"$dir/api/README.md"
+  echo "export {}" >"$dir/web/app.js"
+  cat >"$dir/ws/.context-generator.yaml" <<'YAML'
+roots:
+  - path: ../api
+    prefix: backend
+    include: ["**/*.go"]
+    exclude: ["vendor/"]
+  - path: ../web
+    prefix: frontend
+YAML
+
+  run "$dir/ws" --git-metadata=false
+  expect_code 0
+  expect_stdout "backend/server.go"
+  expect_stdout "frontend/app.js"
+  expect_no_stdout "lib.go"
+  expect_no_stdout "README.md"
+
+  # The other commands walking files see the same roots
+  run "$dir/ws" --dry-run
+  expect_code 0
+  expect_stdout "(as backend/)"
+  expect_stdout "server.go"
+  expect_stdout "2 files would be included"
+  expect_no_stdout "lib.go"
+
+  run stats "$dir/ws"
+  expect_code 0
+  expect_stdout "backend/server.go"
+  expect_stdout "frontend/app.js"
+  expect_no_stdout "README.md"
+
+  run explain --config "$dir/ws/.context-generator.yaml" "$dir/api/server.go" "$dir/api/README.md" "$dir/ws/notes.txt"
+  expect_code 0
+  expect_stdout "$dir/api/server.go: included"
+  expect_stdout "$dir/api/README.md: excluded"
+  expect_stdout "$dir/ws/notes.txt: excluded, it isn't below any root of the config file"
+
+  printf 'roots:\n  - path: ../missing\n' >"$dir/ws/.context-generator.yaml"
+  run "$dir/ws" --git-metadata=false
+  expect_code 1
+  expect_stderr "invalid root in config file"
+}
+
+test_hooks() {
+  local dir="$work/hooks"
+  mkdir -p "$dir"
+  printf 'package main\n' >"$dir/main.go"
+  cat >"$dir/.context-generator.yaml" <<'YAML'
+hooks:
+  pre:
+    - printf 'package main\n\nconst generated = true\n' > gen.go
+  post:
+    - env | grep '^CONTEXT_GENERATOR_' > "$CONTEXT_GENERATOR_ROOT/../hooks.env"
+YAML
+
+  run "$dir" --git-metadata=false --output "$work/hooks.md"
+  expect_code 0
+  grep -q "const generated = true" "$work/hooks.md" || fail "pre hook didn't run before the scan"
+  grep -q "CONTEXT_GENERATOR_OUTPUT=$work/hooks.md" "$work/hooks.env" || fail "post hook didn't get the output"
+  grep -q "CONTEXT_GENERATOR_FILES=3" "$work/hooks.env" || fail "post hook didn't get the totals"
+
+  rm -f "$work/hooks.env"
+  run "$dir" --git-metadata=false --no-hooks
+  expect_code 0
+  [ ! -e "$work/hooks.env" ] || fail "--no-hooks still ran the hooks"
+
+  printf 'hooks:\n  pre:\n    - exit 3\n' >"$dir/.context-generator.yaml"
+  run "$dir" --git-metadata=false
+  expect_code 1
+  expect_stderr 'pre hook "exit 3" failed'
+
+  # Hooks could write anything, so they don't run under --assert-read-only
+  printf 'hooks:\n  pre:\n    - touch written\n' >"$dir/.context-generator.yaml"
+  run "$dir" --git-metadata=false --assert-read-only --no-index
+  expect_code 1
+  expect_stderr "the config file has hooks"
+  [ ! -e "$dir/written" ] || fail "a pre hook ran under --assert-read-only"
+
+  run "$dir" --git-metadata=false --assert-read-only --no-index --no-hooks
+  expect_code 0
+  [ ! -e "$dir/written" ] || fail "--no-hooks still ran the hooks"
+}
+
+test_upload() {
+  GITHUB_TOKEN= GH_TOKEN= run "$tree" --upload gist
+  expect_code 1
+  expect_stderr "set GITHUB_TOKEN or GH_TOKEN"
+
+  PASTE_URL= run "$tree" --upload paste
+  expect_code 1
+  expect_stderr "set PASTE_URL"
+
+  run "$tree" --upload pastebin
+  expect_code 1
+  expect_stderr "must be one of: gist, paste"
+
+  PASTE_URL=http://127.0.0.1:9 run "$tree" --upload paste --print-hash
+  expect_code 1
+  expect_stderr "can't be used with --print-hash"
+}
+
+test_ask() {
+  OPENAI_API_KEY= OPENAI_BASE_URL= ANTHROPIC_API_KEY= run ask "why does it fail?" "$tree"
+  expect_code 1
+  expect_stderr "set OPENAI_API_KEY"
+
+  ANTHROPIC_API_KEY= run ask "why does it fail?" "$tree" --provider anthropic
+  expect_code 1
+  expect_stderr "set ANTHROPIC_API_KEY"
+
+  run ask "why does it fail?" "$tree" --provider gemini
+  expect_code 1
+  expect_stderr "must be one of: openai, anthropic"
+
+  run ask " " "$tree"
+  expect_code 1
+  expect_stderr "the question can't be empty"
+}
+
+test_manifest() {
+  run "$tree" --git-metadata=false --stdout=false --output "$work/ctx.md" --manifest
+  expect_code 0
+  grep -q '"path": ".*src/main.go"' "$work/ctx.manifest.json" || fail "manifest doesn't list src/main.go"
+  grep -q '"sha256": "' "$work/ctx.manifest.json" || fail "manifest doesn't hash the files"
+  grep -q '"exclude-folder": \[' "$work/ctx.manifest.json" || fail "manifest doesn't record the settings"
+
+  run "$tree" --manifest --dry-run
+  expect_code 1
+  expect_stderr "flag --manifest needs a whole context"
+
+  # Without --output the manifest goes in the current directory, which
+  # may be the scan root
+  local dir="$work/manifest-root"
+  mkdir -p "$dir"
+  echo "a" >"$dir/a.txt"
+  cd "$dir" || return
+  run . --git-metadata=false --assert-read-only --no-index --manifest
+  cd - >/dev/null || return
+  expect_code 1
+  expect_stderr "the manifest \"context.manifest.json\" is inside the scan root"
+  [ ! -e "$dir/context.manifest.json" ] || fail "expected no manifest to be written inside the scan root"
+}
+
+test_manifest_diff() {
+  local dir="$work/manifest-diff"
+  cp -r "$tree" "$dir"
+  run "$dir" --git-metadata=false --stdout=false --output "$work/old.md" --manifest
+  expect_code 0
+
+  echo "more" >>"$dir/README.md"
+  mv "$dir/src/main.go" "$dir/src/app.go"
+  run "$dir" --git-metadata=false --stdout=false --output "$work/new.md" --manifest
+  expect_code 0
+
+  run manifest-diff "$work/old.manifest.json" "$work/new.manifest.json"
+  expect_code 0
+  expect_stdout "modified"
+  expect_stdout "renamed from $dir/src/main.go"
+  expect_stdout "0 added, 0 deleted, 1 modified, 1 renamed"
+
+  run manifest-diff "$work/old.manifest.json" "$work/new.manifest.json" --exit-code
+  expect_code 1
+  expect_stderr "2 files changed"
+}
+
+test_sample_large_files() {
+  local dir="$work/large"
+  mkdir -p "$dir"
+  seq 1 5000 >"$dir/big.log"
+
+  run "$dir" --git-metadata=false --max-file-size 1KB
+  expect_code 0
+  expect_no_stdout "big.log"
+
+  run "$dir" --git-metadata=false --max-file-size 1KB --sample-large-files head:2,tail:1
+  expect_code 0
+  expect_stdout "big.log"
+  expect_stdout "… [4997 lines left out by --sample-large-files]"
+  expect_stdout "    5000"
+  expect_no_stdout "    2500"
+
+  run "$dir" --git-metadata=false --max-lines 100 --sample-large-files head:10
+  expect_code 0
+  expect_stdout "… [4990 lines left out by --sample-large-files]"
+
+  run "$dir" --sample-large-files middle:10
+  expect_code 1
+  expect_stderr "must be like head:200,tail:50"
+}
+
+test_data_rows() {
+  local dir="$work/data"
+  mkdir -p "$dir"
+  { echo "id,name"; seq 1 12345 | sed 's/$/,row/'; } >"$dir/data.csv"
+  printf '{"a":1}\n{"a":2}\n' >"$dir/events.jsonl"
+
+  run "$dir" --git-metadata=false --data-rows 3
+  expect_code 0
+  expect_stdout "id,name"
+  expect_stdout "3,row"
+  expect_no_stdout "4,row"
+  expect_stdout "… (12,342 more rows)"
+  expect_stdout '{"a":2}'
+
+  run "$dir" --git-metadata=false --data-rows 1
+  expect_code 0
+  expect_stdout "… (1 more row)"
+}
+
+test_summarize_config_files() {
+  local dir="$work/config-files"
+  mkdir -p "$dir"
+  {
+    echo '{"name": "app", "items": ['
+    for i in $(seq 1 300); do echo "{\"id\": $i, \"enabled\": true},"; done
+    echo '{"id": 0, "enabled": false}]}'
+  } >"$dir/data.json"
+  printf 'name: small\n' >"$dir/small.yaml"
+
+  run "$dir" --git-metadata=false --summarize-config-files
+  expect_code 0
+  expect_stdout ".items: array, 301 items"
+  expect_stdout ".items[].id: number = 1"
+  expect_stdout ".items[].enabled: boolean = true"
+  expect_no_stdout '"id": 150'
+  expect_stdout "name: small"
+
+  run "$dir" --git-metadata=false --summarize-config-files --summarize-over 1MB
+  expect_code 0
+  expect_stdout '"id": 150'
+}
+
+test_caption_cmd() {
+  local dir="$work/images"
+  mkdir -p "$dir"
+  printf '\211PNG\r\n\032\n\000\000' >"$dir/logo.png"
+
+  run "$dir" --git-metadata=false
+  expect_code 0
+  expect_no_stdout "logo.png"
+
+  run "$dir" --git-metadata=false --caption-cmd 'echo "the logo, $(wc -c | tr -d " ") bytes"'
+  expect_code 0
+  expect_stdout "logo.png"
+  expect_stdout "[image: the logo, 10 bytes]"
+
+  run "$dir" --git-metadata=false --caption-cmd 'exit 1'
+  expect_code 0
+  expect_no_stdout "logo.png"
+  expect_stderr "--caption-cmd \"exit 1\" failed"
+
+  run "$dir" --git-metadata=false --assert-read-only --caption-cmd "touch $dir/written"
+  expect_code 1
+  expect_stderr "--caption-cmd runs a command"
+  [ ! -e "$dir/written" ] || fail "--caption-cmd ran under --assert-read-only"
+}
+
+test_treat_as_text() {
+  local dir="$work/text-like"
+  mkdir -p "$dir"
+  echo "BMI calculator notes" >"$dir/notes.txt"
+  printf 'syntax = "proto3";\000\n' >"$dir/api.proto"
+
+  run "$dir" --git-metadata=false
+  expect_code 0
+  expect_stdout "BMI calculator notes"
+  expect_no_stdout "api.proto"
+
+  run "$dir" --git-metadata=false --treat-as-text .PROTO
+  expect_code 0
+  expect_stdout "api.proto"
+
+  run "$dir" --treat-as-text '*.proto'
+  expect_code 1
+  expect_stderr "must be like .svg"
+}
+
+test_treat_as_binary() {
+  local dir="$work/binary-like"
+  mkdir -p "$dir"
+  echo "-----BEGIN CERTIFICATE-----" >"$dir/server.pem"
+  echo "pinned: 1.0.0" >"$dir/deps.lock"
+  echo "var b=2;" >"$dir/app.js"
+
+  run "$dir" --git-metadata=false --treat-as-binary .PEM,lock
+  expect_code 0
+  expect_no_stdout "server.pem"
+  expect_no_stdout "deps.lock"
+  expect_stdout "app.js"
+
+  (cd "$dir" && "$bin" explain --treat-as-binary .lock deps.lock >"$work/stdout" 2>"$work/stderr")
+  code=$?
+  expect_code 0
+  expect_stdout "forced to be binary by --treat-as-binary"
+
+  (cd "$dir" && "$bin" explain --force-binary '*.lock' deps.lock >"$work/stdout" 2>"$work/stderr")
+  code=$?
+  expect_code 0
+  expect_stdout "forced to be binary by --force-binary"
+
+  printf 'treat-as-binary: [.pem]\n' >"$dir/.context-generator.yaml"
+  run "$dir" --git-metadata=false
+  expect_code 0
+  expect_no_stdout "server.pem"
+  expect_stdout "deps.lock"
+
+  (cd "$dir" && "$bin" explain server.pem >"$work/stdout" 2>"$work/stderr")
+  code=$?
+  expect_code 0
+  expect_stdout "forced to be binary by the treat-as-binary list of the config file"
+
+  printf 'treat-as-binary: ["*"]\n' >"$dir/.context-generator.yaml"
+  run "$dir" --git-metadata=false
+  expect_code 1
+  expect_stderr "invalid treat-as-binary in config file"
+}
+
+test_hash() {
+  local hash="$work/context.hash"
+
+  run "$tree" --print-hash
+  expect_code 0
+  expect_stdout "sha256:"
+  expect_no_stdout "func main() {}"
+  cp "$work/stdout" "$hash"
+
+  run "$tree" --git-metadata=false --format json --check "$hash"
+  expect_code 0
+  expect_stdout "context matches"
+
+  run "$tree" --check "$hash" --exclude README.md
+  expect_code 1
+  expect_stderr "context changed"
+}
+
+test_resume() {
+  local out="$work/resume.txt" checkpoint="$work/resume.json"
+
+  run "$tree" --git-metadata=false --no-summary --stdout=false --output "$out" --checkpoint "$checkpoint"
+  expect_code 0
+
+  # Pretend the run was interrupted after its first file
+  python3 - "$checkpoint" <<'PY'
+import json, sys
+cp = json.load(open(sys.argv[1]))
+cp["complete"], cp["files"] = False, cp["files"][:1]
+json.dump(cp, open(sys.argv[1], "w"))
+PY
+  echo "partial context" >"$out"
+
+  run "$tree" --git-metadata=false --no-summary --stdout=false --output "$out" --checkpoint "$checkpoint" --resume
+  expect_code 0
+  grep -qF "partial context" "$out" || fail "expected the resumed run to keep the output it continues"
+  grep -qF "func main() {}" "$out" || fail "expected the resumed run to write the remaining files"
+}
+
+test_version() {
+  run version --json
+  expect_code 0
+  expect_stdout '"go_version": "go'
+  expect_stdout '"platform":'
+}
+
+test_cache() {
+  local dir="$work/cached"
+  rm -rf "$dir"
+  mkdir -p "$dir"
+  echo "first" >"$dir/notes.txt"
+  echo "package main" >"$dir/main.go"
+
+  run cache clear
+  expect_code 0
+
+  run "$dir" --git-metadata=false --no-index
+  expect_code 0
+  run cache clear
+  expect_stdout "removed 0 cached files"
+
+  run "$dir" --git-metadata=false --no-index --cache
+  expect_code 0
+  echo "second, longer" >"$dir/notes.txt"
+  run "$dir" --git-metadata=false --no-index --cache
+  expect_code 0
+  expect_stdout "second, longer"
+  expect_no_stdout "first"
+
+  # A changed file replaces its copy instead of adding another
+  run cache clear
+  expect_stdout "removed 2 cached files"
+}
+
+test_bench() {
+  run bench --runs 2 "$tree"
+  expect_code 0
+  expect_stdout "FILES/S"
+  expect_stdout "mean"
+  expect_no_stdout "func main() {}"
+}
+
+test_transform_cmd() {
+  run "$tree" --git-metadata=false --transform-cmd 'tr a-z A-Z'
+  expect_code 0
+  expect_stdout "FUNC MAIN() {}"
+
+  run "$tree" --git-metadata=false --transform-cmd 'exit 1'
+  expect_code 0
+  expect_stdout "func main() {}"
+  expect_stderr "failed"
+
+  run "$tree" --git-metadata=false --assert-read-only --transform-cmd "touch $tree/written"
+  expect_code 1
+  expect_stderr "--transform-cmd runs a command"
+  [ ! -e "$tree/written" ] || { rm -f "$tree/written"; fail "--transform-cmd ran under --assert-read-only"; }
+}
+
+test_filter_plugin() {
+  local plugin="$work/plugin.sh"
+  cat >"$plugin" <<'SH'
+while read -r line; do
+  case "$line" in
+    *'"path":"src/main.go"'*) echo '{"include":false,"reason":"holds secrets"}' ;;
+    *) echo '{"include":true}' ;;
+  esac
+done
+SH
+
+  run "$tree" --git-metadata=false --with-exclusion-summary --filter-plugin "sh $plugin"
+  expect_code 0
+  expect_stdout "main_test.go"
+  expect_no_stdout "func main() {}"
+  expect_stdout "holds secrets (--filter-plugin=sh $plugin): 1 path"
+
+  run "$tree" --git-metadata=false --filter-plugin 'while read -r line; do echo nope; done'
+  expect_code 1
+  expect_stderr "invalid JSON"
+
+  run "$tree" --git-metadata=false --filter-plugin '  '
+  expect_code 1
+  expect_stderr "filter plugin command is empty"
+
+  # The exit code of the plugin isn't taken for one of ours
+  run "$tree" --git-metadata=false --filter-plugin 'while read -r line; do echo "{\"include\":true}"; done; exit 3'
+  expect_code 1
+  expect_stderr "exit status 3"
+
+  run "$tree" --git-metadata=false --assert-read-only --filter-plugin "touch $tree/written"
+  expect_code 1
+  expect_stderr "--filter-plugin runs a command"
+  [ ! -e "$tree/written" ] || { rm -f "$tree/written"; fail "--filter-plugin ran under --assert-read-only"; }
+}
+
+test_braced_patterns() {
+  # Commas inside braces don't split the lists of patterns flags take
+  run "$tree" --git-metadata=false --force-binary '*.{md,txt}'
+  expect_code 0
+  expect_no_stdout "# Fixture"
+  expect_stdout "func main() {}"
+
+  run "$tree" --git-metadata=false --force-binary 'README.md,src/*_test.{go,js}'
+  expect_code 0
+  expect_no_stdout "# Fixture"
+  expect_no_stdout "TestMain"
+  expect_stdout "func main() {}"
+
+  run "$tree" --git-metadata=false --minify '*.{go,js}'
+  expect_code 0
+  expect_stdout "func main() {}"
+}
+
+test_dry_run() {
+  run "$tree" --git-metadata=false --dry-run
+  expect_code 0
+  expect_stdout "main.go"
+  expect_no_stdout "func main() {}"
+}
+
+test_no_tests() {
+  run "$tree" --git-metadata=false --no-tests
+  expect_code 0
+  expect_stdout "src/main.go"
+  expect_no_stdout "main_test.go"
+}
+
+test_tests_only() {
+  run "$tree" --git-metadata=false --tests-only
+  expect_code 0
+  expect_stdout "main_test.go"
+  expect_no_stdout "file: $tree/src/main.go"
+}
+
+test_glob_args() {
+  run "$tree/**/*.md" --git-metadata=false
+  expect_code 0
+  expect_stdout "README.md"
+  expect_no_stdout "main.go"
+}
+
+test_warnings_go_to_stderr() {
+  run "$tree" --git-metadata=false --exclude-folder does-not-exist
+  expect_code 0
+  expect_no_stdout "does-not-exist"
+  expect_stderr "does-not-exist"
+}
+
+test_no_warnings() {
+  run "$tree" --git-metadata=false --exclude-folder does-not-exist --no-warnings
+  expect_code 0
+  expect_empty_stderr
+}
+
+test_fail_over_tokens() {
+  run "$tree" --git-metadata=false --fail-over-tokens 1
+  expect_code 3
+  expect_stderr "tokens"
+}
+
+test_conflicting_flags() {
+  run "$tree" --no-tests --tests-only
+  expect_code 1
+  expect_stderr "can't be used together"
+}
+
+test_unknown_format() {
+  run "$tree" --format nope
+  expect_code 1
+  [ ! -s "$work/stdout" ] || fail "expected no output on stdout"
+}
+
+test_missing_directory() {
+  run "$work/does-not-exist"
+  expect_code 1
+}
+
+test_archive() {
+  tar czf "$work/tree.tar.gz" -C "$tree" .
+  run "$work/tree.tar.gz" --no-index
+  expect_code 0
+  expect_stdout "file: $work/tree.tar.gz/src/main.go"
+  expect_no_stdout "node_modules"
+}
+
+test_explain() {
+  (cd "$tree" && "$bin" explain src/main.go node_modules/dep/index.js >"$work/stdout" 2>"$work/stderr")
+  code=$?
+  expect_code 0
+  expect_stdout "src/main.go: included"
+  expect_stdout "node_modules/dep/index.js: excluded"
+  expect_stdout "--exclude-folder=node_modules"
+}
+
+test_follow_imports() {
+  local mod="$work/mod"
+  rm -rf "$mod"
+  mkdir -p "$mod/cmd" "$mod/used" "$mod/unused"
+  printf 'module example.com/mod\n\ngo 1.23\n' >"$mod/go.mod"
+  printf 'package main\n\nimport _ "example.com/mod/used"\n' >"$mod/cmd/main.go"
+  printf 'package used\n' >"$mod/used/used.go"
+  printf 'package unused\n' >"$mod/unused/unused.go"
+
+  run "$mod" --git-metadata=false --follow-imports cmd
+  expect_code 0
+  expect_stdout "file: $mod/used/used.go"
+  expect_no_stdout "unused.go"
+}
+
+test_entry() {
+  local app="$work/app"
+  rm -rf "$app"
+  mkdir -p "$app/src/lib"
+  printf '{ "compilerOptions": { "paths": { "@/*": ["src/*"] } } }\n' >"$app/tsconfig.json"
+  printf 'import { a } from "./lib/a";\nimport b from "@/lib/b";\n' >"$app/src/index.ts"
+  printf 'export const a = 1;\n' >"$app/src/lib/a.ts"
+  printf 'export default 2;\n' >"$app/src/lib/b.ts"
+  printf 'export const c = 3;\n' >"$app/src/lib/c.ts"
+
+  run "$app" --git-metadata=false --entry src/index.ts
+  expect_code 0
+  expect_stdout "file: $app/src/lib/a.ts"
+  expect_stdout "file: $app/src/lib/b.ts"
+  expect_no_stdout "c.ts"
+}
+
+test_list_presets() {
+  run list-presets --with-counts --dir "$tree"
+  expect_code 0
+  expect_stdout "PRESET"
+  grep -qE '^aggressive +[0-9]+ +1 +63 B$' "$work/stdout" || fail "expected the aggressive preset to leave out the test file"
+}
+
+test_diff() {
+  local repo="$work/repo"
+  rm -rf "$repo"
+  cp -r "$tree" "$repo"
+  git -C "$repo" init -q
+  git -C "$repo" -c user.name=e2e -c user.email=e2e@example.com add .
+  git -C "$repo" -c user.name=e2e -c user.email=e2e@example.com commit -qm base
+  echo "func helper() {}" >>"$repo/src/main.go"
+  echo "# Notes" >"$repo/NOTES.md"
+  git -C "$repo" add .
+  git -C "$repo" -c user.name=e2e -c user.email=e2e@example.com commit -qm change
+
+  (cd "$repo" && "$bin" diff HEAD~1..HEAD >"$work/stdout" 2>"$work/stderr")
+  code=$?
+  expect_code 0
+  expect_stdout "change: modified"
+  expect_stdout "+func helper() {}"
+  expect_stdout "change: added"
+  expect_stdout "    # Notes"
+}
+
+test_link_files() {
+  local repo="$work/linked"
+  rm -rf "$repo"
+  cp -r "$tree" "$repo"
+  git -C "$repo" init -q
+  git -C "$repo" remote add origin git@github.com:org/repo.git
+  git -C "$repo" -c user.name=e2e -c user.email=e2e@example.com add .
+  git -C "$repo" -c user.name=e2e -c user.email=e2e@example.com commit -qm base
+  git -C "$repo" tag base
+  echo "# Notes" >"$repo/NOTES.md"
+  git -C "$repo" add .
+  git -C "$repo" -c user.name=e2e -c user.email=e2e@example.com commit -qm change
+
+  local base head
+  base=$(git -C "$repo" rev-parse base)
+  head=$(git -C "$repo" rev-parse HEAD)
+
+  run "$repo" --no-index --link-files
+  expect_code 0
+  expect_stdout "https://github.com/org/repo/blob/$head/src/main.go"
+
+  run "$repo" --no-index --link-files --rev base
+  expect_code 0
+  expect_stdout "https://github.com/org/repo/blob/$base/src/main.go"
+  expect_no_stdout "$head"
+}
+
+test_verbose_goes_to_stderr() {
+  run "$tree" --git-metadata=false --verbose --no-index
+  expect_code 0
+  expect_stderr "msg=walk"
+  expect_no_stdout "msg="
+}
+
+check text_output
+check summary_footer
+check json_output
+check plain_compact_output
+check chunks_output
+check sqlite_output
+check markdown_output
+check max_line_length
+check anonymize
+check hidden
+check codeowners
+check workspace
+check hooks
+check upload
+check ask
+check hash
+check resume
+check manifest
+check manifest_diff
+check sample_large_files
+check data_rows
+check summarize_config_files
+check caption_cmd
+check treat_as_text
+check treat_as_binary
+check version
+check cache
+check bench
+check transform_cmd
+check filter_plugin
+check braced_patterns
+check dry_run
+check no_tests
+check tests_only
+check glob_args
+check warnings_go_to_stderr
+check no_warnings
+check fail_over_tokens
+check conflicting_flags
+check unknown_format
+check missing_directory
+check archive
+check explain
+check follow_imports
+check entry
+check list_presets
+check diff
+check link_files
+check verbose_goes_to_stderr
+
+echo "$passed passed, $failed failed"
+[ "$failed" -eq 0 ]
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	_ "modernc.org/sqlite"
+)
+
+// defaultEmbeddingsModel is the model used when EMBEDDINGS_MODEL isn't set
+const defaultEmbeddingsModel = "text-embedding-3-small"
+
+// envOr returns the value of the first environment variable set among
+// names, or fallback when none is
+func envOr(fallback string, names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+
+	return fallback
+}
+
+// embeddingsClient calls an OpenAI-compatible embeddings API
+type embeddingsClient struct {
+	http    *http.Client
+	baseURL string
+	apiKey  string
+	model   string
+}
+
+// newEmbeddingsClient returns a client configured from the environment:
+// EMBEDDINGS_BASE_URL, EMBEDDINGS_API_KEY and EMBEDDINGS_MODEL, falling
+// back to OPENAI_BASE_URL and OPENAI_API_KEY
+func newEmbeddingsClient() (*embeddingsClient, error) {
+	c := &embeddingsClient{
+		http:    http.DefaultClient,
+		baseURL: strings.TrimSuffix(envOr("https://api.openai.com/v1", "EMBEDDINGS_BASE_URL", "OPENAI_BASE_URL"), "/"),
+		apiKey:  envOr("", "EMBEDDINGS_API_KEY", "OPENAI_API_KEY"),
+		model:   envOr(defaultEmbeddingsModel, "EMBEDDINGS_MODEL"),
+	}
+
+	// Local servers often need no key, but the default endpoint does
+	if c.apiKey == "" && c.baseURL == "https://api.openai.com/v1" {
+		return nil, fmt.Errorf("set EMBEDDINGS_API_KEY or OPENAI_API_KEY to call the embeddings API, or EMBEDDINGS_BASE_URL to use another endpoint")
+	}
+
+	return c, nil
+}
+
+// embed returns the embedding of every input, in the same order
+func (c *embeddingsClient) embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": c.model, "input": inputs})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding embeddings request: %w", err)
+	}
+
+	address := c.baseURL + "/embeddings"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request to %q: %w", address, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling the embeddings API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("embeddings API returned %s for %q: %s", resp.Status, address, strings.TrimSpace(string(msg)))
+	}
+
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("error reading embeddings API response from %q: %w", address, err)
+	}
+
+	vectors := make([][]float32, len(inputs))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embeddings API returned an embedding for input %d of %d", d.Index, len(inputs))
+		}
+
+		vectors[d.Index] = d.Embedding
+	}
+
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("embeddings API returned no embedding for input %d of %d", i, len(inputs))
+		}
+	}
+
+	return vectors, nil
+}
+
+// vectorSink stores chunks along with their embeddings
+type vectorSink interface {
+	write(c contextChunk, vector []float32) error
+	close() error
+}
+
+// openVectorSink opens the file at path to store embeddings in: a SQLite
+// database when it's named like one, or JSON Lines otherwise
+func openVectorSink(path, model string) (vectorSink, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".db", ".sqlite", ".sqlite3":
+		return openSQLiteVectors(path, model)
+	default:
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("error creating output file %q: %w", path, err)
+		}
+
+		return &jsonlVectors{f: f, model: model}, nil
+	}
+}
+
+// embeddedChunk is a chunk stored with its embedding in JSON Lines
+type embeddedChunk struct {
+	contextChunk
+	Model     string    `json:"model"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// jsonlVectors stores embeddings as JSON Lines, one chunk per line
+type jsonlVectors struct {
+	f     *os.File
+	model string
+}
+
+func (j *jsonlVectors) write(c contextChunk, vector []float32) error {
+	data, err := json.Marshal(embeddedChunk{contextChunk: c, Model: j.model, Embedding: vector})
+	if err != nil {
+		return fmt.Errorf("error encoding chunk %d of file %q: %w", c.Chunk, c.Path, err)
+	}
+
+	if _, err := fmt.Fprintf(j.f, "%s\n", data); err != nil {
+		return fmt.Errorf("error writing output file %q: %w", j.f.Name(), err)
+	}
+
+	return nil
+}
+
+func (j *jsonlVectors) close() error {
+	return j.f.Close()
+}
+
+// sqliteVectors stores embeddings in a table of a SQLite database, as
+// little-endian float32 blobs, replacing the chunks of previous runs
+type sqliteVectors struct {
+	db    *sql.DB
+	model string
+}
+
+func openSQLiteVectors(path, model string) (*sqliteVectors, error) {
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database %q: %w", path, err)
+	}
+
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS chunks (path TEXT NOT NULL, chunk INTEGER NOT NULL, start_line INTEGER NOT NULL, end_line INTEGER NOT NULL, language TEXT, sha256 TEXT, content TEXT NOT NULL, model TEXT NOT NULL, embedding BLOB NOT NULL, PRIMARY KEY (path, chunk))`,
+		`DELETE FROM chunks`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error preparing database %q: %w", path, err)
+		}
+	}
+
+	return &sqliteVectors{db: db, model: model}, nil
+}
+
+func (s *sqliteVectors) write(c contextChunk, vector []float32) error {
+	blob := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(blob[4*i:], math.Float32bits(v))
+	}
+
+	_, err := s.db.Exec(`INSERT INTO chunks (path, chunk, start_line, end_line, language, sha256, content, model, embedding) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.Path, c.Chunk, c.StartLine, c.EndLine, c.Language, c.SHA256, c.Content, s.model, blob)
+	if err != nil {
+		return fmt.Errorf("error saving chunk %d of file %q: %w", c.Chunk, c.Path, err)
+	}
+
+	return nil
+}
+
+func (s *sqliteVectors) close() error {
+	return s.db.Close()
+}
+
+// embedder receives the chunks-jsonl output of a run and embeds the
+// chunks in batches, storing them in a sink as they're embedded
+type embedder struct {
+	ctx       context.Context
+	client    *embeddingsClient
+	sink      vectorSink
+	batchSize int
+
+	pending []byte
+	batch   []contextChunk
+	count   int
+}
+
+func (e *embedder) Write(p []byte) (int, error) {
+	e.pending = append(e.pending, p...)
+
+	for {
+		i := bytes.IndexByte(e.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+
+		var c contextChunk
+		if err := json.Unmarshal(e.pending[:i], &c); err != nil {
+			return 0, fmt.Errorf("error reading chunk: %w", err)
+		}
+		e.pending = e.pending[i+1:]
+
+		e.batch = append(e.batch, c)
+		if len(e.batch) >= e.batchSize {
+			if err := e.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+// flush embeds and stores the chunks waiting in the batch
+func (e *embedder) flush() error {
+	if len(e.batch) == 0 {
+		return nil
+	}
+
+	inputs := make([]string, len(e.batch))
+	for i, c := range e.batch {
+		inputs[i] = c.Content
+	}
+
+	vectors, err := e.client.embed(e.ctx, inputs)
+	if err != nil {
+		return err
+	}
+
+	for i, c := range e.batch {
+		if err := e.sink.write(c, vectors[i]); err != nil {
+			return err
+		}
+	}
+
+	e.count += len(e.batch)
+	e.batch = e.batch[:0]
+	return nil
+}
+
+func newEmbedCommand(opts *options) *cobra.Command {
+	var (
+		output    string
+		batchSize int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "embed [directory]",
+		Short: "Split the files of a context into chunks and store their embeddings, from an OpenAI-compatible API set with EMBEDDINGS_BASE_URL, EMBEDDINGS_API_KEY and EMBEDDINGS_MODEL, in a JSON Lines file or a SQLite database",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				opts.root = args[0]
+			}
+
+			if output == "" {
+				return fmt.Errorf("flag --output is required, like vectors.jsonl or vectors.db")
+			}
+
+			if batchSize <= 0 {
+				return fmt.Errorf("flag --batch-size must be 1 or more, got %d", batchSize)
+			}
+
+			if opts.chunkLines <= 0 || opts.chunkOverlap < 0 || opts.chunkOverlap >= opts.chunkLines {
+				return fmt.Errorf("flag --chunk-overlap must be at least 0 and less than --chunk-lines, which must be 1 or more")
+			}
+
+			client, err := newEmbeddingsClient()
+			if err != nil {
+				return err
+			}
+
+			sink, err := openVectorSink(output, client.model)
+			if err != nil {
+				return err
+			}
+
+			e := &embedder{ctx: cmd.Context(), client: client, sink: sink, batchSize: batchSize}
+
+			opts.format = formatChunksJSONL
+			err = run(cmd.Context(), *opts, e)
+			if err == nil {
+				err = e.flush()
+			}
+
+			if closeErr := sink.close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "stored the embeddings of %s in %q\n", plural(e.count, "chunk"), output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "output", "", "file to store the embeddings in: a SQLite database when named *.db, *.sqlite or *.sqlite3, JSON Lines otherwise")
+	cmd.Flags().IntVar(&batchSize, "batch-size", 64, "how many chunks to send to the embeddings API in each request")
+	cmd.Flags().IntVar(&opts.chunkLines, "chunk-lines", 80, "how many lines each chunk holds at most")
+	cmd.Flags().IntVar(&opts.chunkOverlap, "chunk-overlap", 10, "how many lines each chunk repeats from the one before it")
+
+	return cmd
+}
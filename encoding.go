@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Text encodings recognized when sniffing files
+const (
+	encodingUTF8    = "utf-8"
+	encodingUTF8BOM = "utf-8 with BOM"
+	encodingUTF16LE = "utf-16le"
+	encodingUTF16BE = "utf-16be"
+	encodingLatin1  = "latin-1"
+)
+
+// sniffSize is how many bytes are read from the start of a file to tell
+// whether it's text, and in which encoding
+const sniffSize = 512
+
+// sniffBuffers reuses the buffers files are sniffed with, since every
+// file walked needs one but none outlives the sniffing
+var sniffBuffers = sync.Pool{New: func() any { return new([sniffSize]byte) }}
+
+// fileEncoding sniffs the file at path, below root, returning its text
+// encoding or an empty string if the file is binary
+func fileEncoding(root, path string, opts options) (string, error) {
+	// Files forced to be binary don't need to be read at all
+	if opts.overrideEncoding(root, path, encodingUTF8) == "" {
+		return "", nil
+	}
+
+	// Open the file for reading
+	file, err := opts.filters.Open(root, path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	encoding, err := sniffEncoding(file, opts.transcode)
+	if err != nil {
+		return "", err
+	}
+
+	return opts.overrideEncoding(root, path, encoding), nil
+}
+
+// overrideEncoding applies --force-text, --treat-as-text, --force-binary
+// and --treat-as-binary to the encoding sniffed for the file at path,
+// below root, since sniffing misclassifies some formats. Forced text is
+// read as UTF-8, falling back to Latin-1 when transcoding.
+func (o options) overrideEncoding(root, path, sniffed string) string {
+	if len(o.forceText) == 0 && len(o.forceBinary) == 0 && len(o.textExtensions) == 0 && len(o.binaryExtensions) == 0 && len(o.configBinaryExtensions) == 0 {
+		return sniffed
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+
+	switch {
+	case o.forcedBinaryBy(rel) != "":
+		return ""
+	case sniffed == "" && (o.forceText.Match(rel) || o.textExtensions.match(rel)):
+		return encodingUTF8
+	}
+
+	return sniffed
+}
+
+// forcedBinaryBy returns the setting forcing the file at rel, relative to
+// the scan root, to be treated as binary, or an empty string if none does
+func (o options) forcedBinaryBy(rel string) string {
+	switch {
+	case o.forceBinary.Match(rel):
+		return "--force-binary"
+	case o.binaryExtensions.match(rel):
+		return "--treat-as-binary"
+	case o.configBinaryExtensions.match(rel):
+		return "the treat-as-binary list of the config file"
+	}
+
+	return ""
+}
+
+// extensionList holds file extensions, like .svg or .min.js, matched
+// against the end of file names regardless of case
+type extensionList []string
+
+// parseExtensions returns the extensions given to flag, adding the dot
+// they start with when it's missing
+func parseExtensions(flag string, values []string) (extensionList, error) {
+	list := make(extensionList, 0, len(values))
+	for _, v := range values {
+		ext := strings.ToLower(strings.TrimSpace(v))
+		if strings.Trim(ext, ".") == "" || strings.ContainsAny(ext, `/\*?`) {
+			return nil, fmt.Errorf("invalid --%s extension %q, must be like .svg", flag, v)
+		}
+
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+
+		list = append(list, ext)
+	}
+
+	return list, nil
+}
+
+// match reports whether the name of the file at path ends with any of
+// the extensions
+func (l extensionList) match(path string) bool {
+	if len(l) == 0 {
+		return false
+	}
+
+	name := strings.ToLower(filepath.Base(path))
+	for _, ext := range l {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sniffEncoding reads the first bytes of r to detect whether it contains
+// text and in which encoding. Without transcoding, only text that can be
+// emitted as-is is recognized, and it's always reported as UTF-8.
+func sniffEncoding(r io.Reader, transcode bool) (string, error) {
+	// Read the first 512 bytes to detect content type
+	buffer := sniffBuffers.Get().(*[sniffSize]byte)
+	defer sniffBuffers.Put(buffer)
+
+	n, err := io.ReadFull(r, buffer[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	sample := buffer[:n]
+
+	// Check if the content type indicates a text file. Content types are
+	// told apart by their first bytes, so text starting like a binary
+	// format, like "BM" for bitmaps, needs a second look.
+	text := strings.HasPrefix(http.DetectContentType(sample), "text/") || looksLikeText(sample)
+
+	if !transcode {
+		if text {
+			return encodingUTF8, nil
+		}
+
+		return "", nil
+	}
+
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		return encodingUTF8BOM, nil
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return encodingUTF16LE, nil
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return encodingUTF16BE, nil
+	}
+
+	// UTF-16 without a byte order mark looks binary because of all the
+	// zero bytes, but they fall on every other byte for ASCII text
+	if enc := sniffUTF16(sample); enc != "" {
+		return enc, nil
+	}
+
+	if !text {
+		return "", nil
+	}
+
+	// Text that isn't valid UTF-8 is most likely in a legacy 8-bit encoding
+	if !validUTF8Prefix(sample) {
+		return encodingLatin1, nil
+	}
+
+	return encodingUTF8, nil
+}
+
+// looksLikeText reports whether sample reads as text: valid UTF-8 without
+// zero bytes and with hardly any control characters other than the ones
+// laying out text, like tabs, line breaks, form feeds and the escapes of
+// terminal colors
+func looksLikeText(sample []byte) bool {
+	if len(sample) == 0 || !validUTF8Prefix(sample) {
+		return false
+	}
+
+	var controls int
+	for _, b := range sample {
+		switch {
+		case b == 0:
+			return false
+		case b == '\t', b == '\n', b == '\r', b == '\f', b == '\v', b == 0x1B:
+		case b < 0x20 || b == 0x7F:
+			controls++
+		}
+	}
+
+	return controls*20 <= len(sample)
+}
+
+// sniffUTF16 detects UTF-16 text without a byte order mark by looking for
+// zero bytes in the high half of most characters
+func sniffUTF16(sample []byte) string {
+	pairs := len(sample) / 2
+	if pairs < 2 {
+		return ""
+	}
+
+	var evenZeros, oddZeros int
+	for i := 0; i+1 < len(sample); i += 2 {
+		if sample[i] == 0 && sample[i+1] != 0 {
+			evenZeros++
+		}
+
+		if sample[i+1] == 0 && sample[i] != 0 {
+			oddZeros++
+		}
+	}
+
+	// Require most characters to follow the pattern, so binary files
+	// with a lot of zeros aren't mistaken for text
+	switch {
+	case oddZeros*10 >= pairs*9 && evenZeros == 0:
+		return encodingUTF16LE
+	case evenZeros*10 >= pairs*9 && oddZeros == 0:
+		return encodingUTF16BE
+	}
+
+	return ""
+}
+
+// validUTF8Prefix reports whether sample is valid UTF-8, allowing for a
+// multi-byte character cut short at the end of the sample
+func validUTF8Prefix(sample []byte) bool {
+	for i := 0; i < utf8.UTFMax && i <= len(sample); i++ {
+		if utf8.Valid(sample[:len(sample)-i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// decodeText converts content in the given encoding to UTF-8. Since only
+// the start of the file was sniffed, content thought to be UTF-8 that
+// turns out not to be is treated as Latin-1. It returns the encoding the
+// content was actually decoded from.
+func decodeText(content []byte, encoding string) ([]byte, string) {
+	switch encoding {
+	case encodingUTF8BOM:
+		return bytes.TrimPrefix(content, []byte{0xEF, 0xBB, 0xBF}), encoding
+	case encodingUTF16LE, encodingUTF16BE:
+		return decodeUTF16(content, encoding == encodingUTF16BE), encoding
+	case encodingLatin1:
+		return decodeLatin1(content), encoding
+	}
+
+	if !utf8.Valid(content) {
+		return decodeLatin1(content), encodingLatin1
+	}
+
+	return content, encoding
+}
+
+// decodeUTF16 converts UTF-16 content, dropping its byte order mark
+func decodeUTF16(content []byte, bigEndian bool) []byte {
+	units := make([]uint16, 0, len(content)/2)
+	for i := 0; i+1 < len(content); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(content[i])<<8|uint16(content[i+1]))
+		} else {
+			units = append(units, uint16(content[i+1])<<8|uint16(content[i]))
+		}
+	}
+
+	if len(units) > 0 && units[0] == 0xFEFF {
+		units = units[1:]
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(units))
+	for _, r := range utf16.Decode(units) {
+		buf.WriteRune(r)
+	}
+
+	return buf.Bytes()
+}
+
+// decodeLatin1 converts ISO-8859-1 content, where every byte is the code
+// point of its character
+func decodeLatin1(content []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(content) + len(content)/4)
+
+	for _, b := range content {
+		buf.WriteRune(rune(b))
+	}
+
+	return buf.Bytes()
+}
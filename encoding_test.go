@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// utf16Bytes encodes the ASCII text s as UTF-16
+func utf16Bytes(s string, bigEndian bool) []byte {
+	var buf bytes.Buffer
+	for _, c := range []byte(s) {
+		if bigEndian {
+			buf.Write([]byte{0, c})
+		} else {
+			buf.Write([]byte{c, 0})
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func TestSniffEncoding(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        []byte
+		transcode bool
+		want      string
+	}{
+		{"ascii", []byte("package main\n"), false, encodingUTF8},
+		{"utf-8", []byte("héllo wörld\n"), false, encodingUTF8},
+		{"empty", nil, false, encodingUTF8},
+		{"binary", []byte{0x00, 0x01, 0x02, 0x03, 0xFF}, false, ""},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), false, ""},
+		{"text starting like a bitmap", []byte("BM is a bitmap header, but this is text\n"), false, encodingUTF8},
+		{"terminal colors", []byte("\x1b[31mred\x1b[0m\n"), false, encodingUTF8},
+		{"latin-1 without transcoding", []byte("caf\xe9 au lait\n"), false, encodingUTF8},
+		{"utf-16 without transcoding", utf16Bytes("hello world", false), false, ""},
+
+		{"ascii transcoded", []byte("package main\n"), true, encodingUTF8},
+		{"bom", []byte("\xEF\xBB\xBFhello\n"), true, encodingUTF8BOM},
+		{"utf-16le bom", append([]byte{0xFF, 0xFE}, utf16Bytes("hello", false)...), true, encodingUTF16LE},
+		{"utf-16be bom", append([]byte{0xFE, 0xFF}, utf16Bytes("hello", true)...), true, encodingUTF16BE},
+		{"utf-16le", utf16Bytes("hello world", false), true, encodingUTF16LE},
+		{"utf-16be", utf16Bytes("hello world", true), true, encodingUTF16BE},
+		{"latin-1", []byte("caf\xe9 au lait\n"), true, encodingLatin1},
+		{"binary transcoded", []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0xFF, 0x00}, true, ""},
+
+		// A character cut short by the end of the sample is still UTF-8
+		{"cut character", []byte(strings.Repeat("a", sniffSize-1) + "é"), true, encodingUTF8},
+	}
+
+	for _, tt := range tests {
+		got, err := sniffEncoding(bytes.NewReader(tt.in), tt.transcode)
+		if err != nil {
+			t.Errorf("%s: sniffEncoding failed: %v", tt.name, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("%s: sniffEncoding(transcode=%v) = %q, want %q", tt.name, tt.transcode, got, tt.want)
+		}
+	}
+}
+
+func TestLooksLikeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"plain text\n", true},
+		{"tabs\tand\r\nform\ffeeds\v", true},
+		{"", false},
+		{"zero\x00byte", false},
+		{"invalid \xff utf-8", false},
+		{"one control \x01 in a long enough line of text", true},
+		{"\x01\x02\x03 mostly controls", false},
+	}
+
+	for _, tt := range tests {
+		if got := looksLikeText([]byte(tt.in)); got != tt.want {
+			t.Errorf("looksLikeText(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSniffUTF16(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"little endian", utf16Bytes("some text", false), encodingUTF16LE},
+		{"big endian", utf16Bytes("some text", true), encodingUTF16BE},
+		{"too short", utf16Bytes("a", false), ""},
+		{"ascii", []byte("some text"), ""},
+		{"mixed zeros", []byte{'a', 0, 0, 'b', 'c', 0, 0, 'd'}, ""},
+		{"all zeros", make([]byte, 16), ""},
+	}
+
+	for _, tt := range tests {
+		if got := sniffUTF16(tt.in); got != tt.want {
+			t.Errorf("%s: sniffUTF16(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       []byte
+		encoding string
+		want     string
+		wantEnc  string
+	}{
+		{"utf-8", []byte("héllo"), encodingUTF8, "héllo", encodingUTF8},
+		{"bom", []byte("\xEF\xBB\xBFhello"), encodingUTF8BOM, "hello", encodingUTF8BOM},
+		{"utf-16le", append([]byte{0xFF, 0xFE}, utf16Bytes("hi", false)...), encodingUTF16LE, "hi", encodingUTF16LE},
+		{"utf-16be", utf16Bytes("hi", true), encodingUTF16BE, "hi", encodingUTF16BE},
+		{"utf-16 surrogates", []byte{0x3D, 0xD8, 0x00, 0xDE}, encodingUTF16LE, "😀", encodingUTF16LE},
+		{"latin-1", []byte("caf\xe9"), encodingLatin1, "café", encodingLatin1},
+
+		// Only the start of files is sniffed, so later invalid bytes
+		// turn out to be Latin-1
+		{"not utf-8 after all", []byte("ok \xe9"), encodingUTF8, "ok é", encodingLatin1},
+	}
+
+	for _, tt := range tests {
+		got, enc := decodeText(tt.in, tt.encoding)
+		if string(got) != tt.want || enc != tt.wantEnc {
+			t.Errorf("%s: decodeText = %q, %q, want %q, %q", tt.name, got, enc, tt.want, tt.wantEnc)
+		}
+	}
+}
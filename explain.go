@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/patrickdappollonio/context-generator/filter"
+	"github.com/spf13/cobra"
+)
+
+// explanation tells whether a single path ends up in a context, and why
+// not when it doesn't
+type explanation struct {
+	path     string
+	included bool
+	reason   string
+}
+
+// explainPaths decides whether each of paths, relative to the current
+// directory or absolute, would be included in a context of the directory
+// opts walks, or of the root of the workspace holding it, using the same
+// filters and content checks a run does
+func explainPaths(ctx context.Context, paths []string, opts options) ([]explanation, error) {
+	if err := opts.loadRoot(); err != nil {
+		return nil, err
+	}
+
+	roots, err := opts.walkRoots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The walk of each root is simulated once, when a path needs it
+	walks := make(map[int]*explainWalk)
+
+	explanations := make([]explanation, 0, len(paths))
+	for _, p := range paths {
+		i, target, err := findRoot(roots, p)
+		if err != nil {
+			return nil, err
+		}
+
+		if i < 0 {
+			if len(opts.workspace) == 0 {
+				return nil, fmt.Errorf("path %q is outside of the directory %q", p, opts.root)
+			}
+
+			explanations = append(explanations, explanation{path: p, reason: "it isn't below any root of the config file"})
+			continue
+		}
+
+		walk, found := walks[i]
+		if !found {
+			if walk, err = simulateWalk(ctx, roots[i]); err != nil {
+				return nil, err
+			}
+
+			walks[i] = walk
+		}
+
+		e, err := explainPath(roots[i].root, target, walk.decisions, walk.pruner, roots[i])
+		if err != nil {
+			return nil, err
+		}
+
+		e.path = p
+		explanations = append(explanations, e)
+	}
+
+	return explanations, nil
+}
+
+// explainWalk holds what the walk of a root decided about its paths
+type explainWalk struct {
+	decisions map[string]filter.Decision
+	pruner    *filter.Pruner
+}
+
+// simulateWalk decides on every path the walk of the directory opts
+// walks reaches
+func simulateWalk(ctx context.Context, opts options) (*explainWalk, error) {
+	decisions, err := filter.Simulate(ctx, opts.root, opts.filters)
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := make(map[string]filter.Decision, len(decisions))
+	for _, d := range decisions {
+		byPath[d.Path] = d
+	}
+
+	pruner, err := filter.NewPruner(opts.root, opts.filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return &explainWalk{decisions: byPath, pruner: pruner}, nil
+}
+
+// findRoot returns which of roots holds path, relative to the current
+// directory or absolute, and path in the form its walk reports it, or -1
+// when none does
+func findRoot(roots []options, path string) (int, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("error resolving path %q: %w", path, err)
+	}
+
+	for i, ro := range roots {
+		absRoot, err := filepath.Abs(ro.root)
+		if err != nil {
+			return 0, "", fmt.Errorf("error resolving directory %q: %w", ro.root, err)
+		}
+
+		rel, err := filepath.Rel(absRoot, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		return i, filepath.Join(ro.root, rel), nil
+	}
+
+	return -1, "", nil
+}
+
+// explainPath explains the decision for target, asking pruner about the
+// folders holding it when the walk never reached it
+func explainPath(root, target string, decisions map[string]filter.Decision, pruner *filter.Pruner, opts options) (explanation, error) {
+	d, found := decisions[target]
+	if !found {
+		// Excluded folders aren't entered, so their contents are never
+		// decided on
+		if target != root {
+			parent, err := pruner.Prune(filepath.Dir(target))
+			if err != nil {
+				return explanation{}, err
+			}
+
+			if !parent.Included {
+				return explanation{reason: fmt.Sprintf("its folder %q is left out: %s", parent.Path, parent.Reason)}, nil
+			}
+		}
+
+		return explanation{reason: "the path doesn't exist or isn't below the directory"}, nil
+	}
+
+	if !d.Included {
+		return explanation{reason: d.Reason}, nil
+	}
+
+	if d.Dir {
+		return explanation{included: true, reason: "folder is walked, see its files for what's included"}, nil
+	}
+
+	// The path passed the filters, so what's left are the checks made on
+	// the contents
+	encoding, err := fileEncoding(root, target, opts)
+	if err != nil {
+		return explanation{}, err
+	}
+
+	if encoding == "" {
+		rel, err := filepath.Rel(root, target)
+		if err != nil {
+			rel = target
+		}
+
+		if by := opts.forcedBinaryBy(filepath.ToSlash(rel)); by != "" {
+			return explanation{reason: "forced to be binary by " + by}, nil
+		}
+
+		return explanation{reason: "binary file, use --force-text to include it"}, nil
+	}
+
+	if !opts.includeGenerated {
+		heuristic, err := sniffGenerated(root, target, opts)
+		if err != nil {
+			return explanation{}, err
+		}
+
+		if heuristic != "" {
+			return explanation{reason: "likely generated, " + heuristic + "; use --include-generated to include it"}, nil
+		}
+	}
+
+	lines, err := countFileLines(root, target, opts)
+	if err != nil {
+		return explanation{}, err
+	}
+
+	if opts.overMaxLines(lines) {
+		return explanation{reason: fmt.Sprintf("excluded by %s, it has %d lines", opts.maxLinesExclusion(), lines)}, nil
+	}
+
+	return explanation{included: true}, nil
+}
+
+// printExplanations writes one line per explanation to w
+func printExplanations(w io.Writer, explanations []explanation) error {
+	for _, e := range explanations {
+		status := "included"
+		if !e.included {
+			status = "excluded"
+		}
+
+		// Reasons given by the filters already tell it's an exclusion
+		line := e.path + ": " + status
+		switch {
+		case strings.HasPrefix(e.reason, status):
+			line = e.path + ": " + e.reason
+		case e.reason != "":
+			line += ", " + e.reason
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newExplainCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain path...",
+		Short: "Explain whether files would be included in a context of the current directory, and which flag, preset or " + filter.ContextIgnoreFileName + " pattern left them out",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			explanations, err := explainPaths(cmd.Context(), args, *opts)
+			if err != nil {
+				return err
+			}
+
+			return printExplanations(cmd.OutOrStdout(), explanations)
+		},
+	}
+}
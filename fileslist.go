@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readFileList reads the newline-separated list of paths given to
+// --files-from, from stdin when name is "-"
+func readFileList(name string, stdin io.Reader) ([]string, error) {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("error opening file list %q: %w", name, err)
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	// Keep the order given, dropping blank lines and repeated paths
+	paths := []string{}
+	seen := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		path := filepath.Clean(line)
+		if _, found := seen[path]; found {
+			continue
+		}
+
+		seen[path] = struct{}{}
+		paths = append(paths, path)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file list %q: %w", name, err)
+	}
+
+	return paths, nil
+}
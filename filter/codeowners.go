@@ -0,0 +1,117 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// CodeOwnersPaths are where a CODEOWNERS file is looked up, relative to
+// the root of a repository, in the order GitHub looks them up
+var CodeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// codeOwnersRule is a single line of a CODEOWNERS file
+type codeOwnersRule struct {
+	re      *regexp.Regexp
+	dirOnly bool
+	owners  []string
+
+	// nested is set when the rule claims the files below the directories
+	// it matches too, which those ending with a wildcard, like docs/*,
+	// don't
+	nested bool
+}
+
+// CodeOwners holds the rules of a CODEOWNERS file, telling who owns each
+// path. A nil CodeOwners knows about no owners.
+type CodeOwners struct {
+	rules []codeOwnersRule
+}
+
+// ParseCodeOwners reads the rules of a CODEOWNERS file from r
+func ParseCodeOwners(r io.Reader) (*CodeOwners, error) {
+	c := &CodeOwners{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+
+		// Skip blank lines, comments and the section headers of GitLab
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "[") || strings.HasPrefix(fields[0], "^[") {
+			continue
+		}
+
+		pattern := fields[0]
+		rule := codeOwnersRule{owners: fields[1:]}
+		if strings.HasSuffix(pattern, "/") {
+			rule.dirOnly = true
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+
+		re, err := compileIgnorePattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid pattern %q: %w", line, fields[0], err)
+		}
+
+		rule.re = re
+		rule.nested = rule.dirOnly || !strings.ContainsAny(path.Base(pattern), "*?[")
+		c.rules = append(c.rules, rule)
+	}
+
+	return c, scanner.Err()
+}
+
+// Owners returns the owners of the file at rel, relative to the root of
+// the repository and separated by forward slashes. The last rule matching
+// the file, or a directory holding it, wins, and it may have no owners.
+func (c *CodeOwners) Owners(rel string) []string {
+	if c == nil {
+		return nil
+	}
+
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		if c.rules[i].matches(rel) {
+			return c.rules[i].owners
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether the rule applies to the file at rel, either by
+// naming it or a directory holding it. Like on GitHub, rules ending with
+// a wildcard only apply to the files they name, so docs/* owns
+// docs/index.md but not docs/guides/setup.md.
+func (r codeOwnersRule) matches(rel string) bool {
+	if !r.dirOnly && r.re.MatchString(rel) {
+		return true
+	}
+
+	if !r.nested {
+		return false
+	}
+
+	for dir := path.Dir(rel); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if r.re.MatchString(dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Owns reports whether owner, like @org/team or a user, is one of the
+// owners of the file at rel; owners are compared regardless of case
+func (c *CodeOwners) Owns(rel, owner string) bool {
+	for _, o := range c.Owners(rel) {
+		if strings.EqualFold(o, owner) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,100 @@
+package filter
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCodeOwners(t *testing.T) {
+	tests := []struct {
+		rules string
+		path  string
+		want  []string
+	}{
+		// Wildcards match at any depth unless anchored
+		{"* @all", "main.go", []string{"@all"}},
+		{"* @all", "a/b/c.go", []string{"@all"}},
+		{"*.js @web", "web/app.js", []string{"@web"}},
+		{"*.js @web", "web/app.ts", nil},
+
+		// A trailing wildcard only matches the files directly inside
+		{"docs/* @docs", "docs/index.md", []string{"@docs"}},
+		{"docs/* @docs", "docs/nested/deep.md", nil},
+		{"/docs/* @docs", "docs/index.md", []string{"@docs"}},
+		{"/docs/* @docs", "docs/guides/setup.md", nil},
+
+		// Double stars match across directories
+		{"docs/** @docs", "docs/nested/deep.md", []string{"@docs"}},
+		{"**/logs @ops", "build/logs/today.log", []string{"@ops"}},
+		{"**/logs @ops", "logs/today.log", []string{"@ops"}},
+
+		// Directories own everything below them
+		{"apps/ @apps", "apps/main.go", []string{"@apps"}},
+		{"apps/ @apps", "src/apps/deep/main.go", []string{"@apps"}},
+		{"apps/ @apps", "apps", nil},
+		{"/build/logs/ @ops", "build/logs/a/b.log", []string{"@ops"}},
+		{"/build/logs/ @ops", "src/build/logs/b.log", nil},
+		{"/apps/github @gh", "apps/github/deep/main.go", []string{"@gh"}},
+		{"test*/ @qa", "tests/unit/a_test.go", []string{"@qa"}},
+
+		// The last matching rule wins, even without owners
+		{"* @all\n/apps/ @apps", "apps/main.go", []string{"@apps"}},
+		{"/apps/ @apps\n/apps/github", "apps/github/main.go", []string{}},
+		{"/apps/ @apps\n/apps/github", "apps/other/main.go", []string{"@apps"}},
+		{"/docs/ @docs\ndocs/* @writers", "docs/nested/deep.md", []string{"@docs"}},
+		{"/docs/ @docs\ndocs/* @writers", "docs/index.md", []string{"@writers"}},
+
+		// Comments, blank lines and GitLab sections are skipped
+		{"# owners\n\n[Docs]\n*.md @docs # docs team", "README.md", []string{"@docs"}},
+		{"^[Optional]\n*.md @docs", "README.md", []string{"@docs"}},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCodeOwners(strings.NewReader(tt.rules))
+		if err != nil {
+			t.Errorf("ParseCodeOwners(%q) failed: %v", tt.rules, err)
+			continue
+		}
+
+		if got := c.Owners(tt.path); !slices.Equal(got, tt.want) {
+			t.Errorf("rules %q: Owners(%q) = %q, want %q", tt.rules, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCodeOwnersOwns(t *testing.T) {
+	c, err := ParseCodeOwners(strings.NewReader("/api/ @Org/Backend user@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path  string
+		owner string
+		want  bool
+	}{
+		{"api/server.go", "@org/backend", true},
+		{"api/server.go", "USER@example.com", true},
+		{"api/server.go", "@org/frontend", false},
+		{"web/index.html", "@org/backend", false},
+	}
+
+	for _, tt := range tests {
+		if got := c.Owns(tt.path, tt.owner); got != tt.want {
+			t.Errorf("Owns(%q, %q) = %v, want %v", tt.path, tt.owner, got, tt.want)
+		}
+	}
+
+	var none *CodeOwners
+	if none.Owners("main.go") != nil || none.Owns("main.go", "@org/backend") {
+		t.Errorf("a nil CodeOwners should know about no owners")
+	}
+}
+
+func TestParseCodeOwnersErrors(t *testing.T) {
+	_, err := ParseCodeOwners(strings.NewReader("* @all\nsrc/[abc @team"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ParseCodeOwners error = %v, want one pointing to line 2", err)
+	}
+}
@@ -0,0 +1,43 @@
+package filter
+
+import "errors"
+
+// Errors callers can tell failure modes apart with, using errors.Is,
+// rather than matching messages
+var (
+	// ErrDirNotFound is returned when the directory to walk doesn't
+	// exist
+	ErrDirNotFound = errors.New("does not exist")
+
+	// ErrUnreadableFile is returned when a file or directory can't be
+	// read and SkipErrors doesn't let the walk go past it
+	ErrUnreadableFile = errors.New("unreadable file")
+
+	// ErrBudgetExceeded is returned when what a walk gathered goes over
+	// a budget enforced on top of it, like a limit on estimated tokens
+	ErrBudgetExceeded = errors.New("budget exceeded")
+)
+
+// markedError adds a sentinel to an error without changing its message
+type markedError struct {
+	err  error
+	mark error
+}
+
+func (e *markedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *markedError) Unwrap() []error {
+	return []error{e.err, e.mark}
+}
+
+// Unreadable marks err, returned while reading a path, as
+// ErrUnreadableFile without changing its message. A nil err stays nil.
+func Unreadable(err error) error {
+	if err == nil || errors.Is(err, ErrUnreadableFile) {
+		return err
+	}
+
+	return &markedError{err: err, mark: ErrUnreadableFile}
+}
@@ -0,0 +1,462 @@
+// Package filter decides which files of a tree end up in a context. It
+// holds the exclusion logic used by context-generator itself, so tools
+// built on top of it see exactly the same decisions.
+package filter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options holds the settings deciding which files are included
+type Options struct {
+	// ExcludeFolders and ExcludeFiles hold names of folders and files
+	// left out wherever they are in the tree
+	ExcludeFolders []string
+	ExcludeFiles   []string
+
+	// Exclude holds gitignore-style patterns, relative to the root, that
+	// leave out the paths they match. Later patterns take precedence, so
+	// a pattern starting with "!" brings back paths excluded before it.
+	Exclude []string
+
+	// Preset names a curated bundle of exclusion patterns, if any
+	Preset string
+
+	// NoContextIgnore disables the exclusions in .contextignore files
+	NoContextIgnore bool
+
+	// MaxDepth only includes files up to this many directory levels
+	// below the root, where 1 is the root itself; 0 means no limit
+	MaxDepth int
+
+	// MaxFileSize leaves out files larger than this; 0 means no limit
+	MaxFileSize FileSize
+
+	// IncludeLarge includes the files larger than MaxFileSize rather
+	// than leaving them out, for callers only reading part of them
+	IncludeLarge bool
+
+	// Tests decides whether test files are included
+	Tests TestsMode
+
+	// Hidden decides whether hidden files and directories, the ones
+	// starting with a dot, are included
+	Hidden HiddenMode
+
+	// WithTestedFiles, when only tests are included, also includes the
+	// files tests are named after, like foo.go for foo_test.go
+	WithTestedFiles bool
+
+	// Shard and Sample only keep a deterministic slice of the files
+	Shard  Shard
+	Sample Sample
+
+	// Globs, when set, only include the files matching any of them.
+	// They're relative to the root, and "**" matches any number of
+	// directories.
+	Globs []string
+
+	// Tracked, when set, only includes the files in it, like the ones git
+	// tracks, leaving out build outputs and scratch files without having
+	// to list patterns for them
+	Tracked *PathSet
+
+	// Reachable, when set, only includes the files in it, like the ones
+	// reachable from entry points by following imports. ReachableFrom is
+	// the flag and value selecting them, like --follow-imports=./cmd,
+	// which the files left out are counted under.
+	Reachable     *PathSet
+	ReachableFrom string
+
+	// Owner, when set, only includes the files it owns according to the
+	// rules of CodeOwners, like @org/backend
+	Owner      string
+	CodeOwners *CodeOwners
+
+	// ReadmeFirst visits the README files of every directory before the
+	// rest of its entries, so they introduce what follows
+	ReadmeFirst bool
+
+	// SkipErrors keeps walking past paths that can't be read, like
+	// directories without permissions, reporting them instead of failing
+	SkipErrors bool
+
+	// FS, when set, is read in place of the directory at the root, which
+	// then only prefixes the paths reported, like an archive read without
+	// extracting it
+	FS fs.FS
+
+	// Trace, when set, is called with every decision a walk makes, like
+	// for logging why paths were left out
+	Trace func(d Decision)
+
+	// Files, when not nil, lists the files to include instead of walking
+	// the tree. Relative paths are resolved against the root, and only
+	// Shard and Sample apply to them.
+	Files []string
+
+	// Transformers rewrite the contents of the files included, in order,
+	// for callers reading them, like context-generator does before its
+	// own transformations. Walks don't read files, so they ignore them.
+	Transformers []Transformer
+}
+
+// Decision tells whether a path is included, and why not when it isn't
+type Decision struct {
+	Path     string
+	Dir      bool
+	Included bool
+
+	// Reason explains why the path was left out
+	Reason string
+
+	// Exclusion is the key the path is counted under in a Report, empty
+	// for paths that aren't counted, like those of other shards
+	Exclusion string
+
+	// Err is why the path couldn't be read, when SkipErrors let the walk
+	// continue past it
+	Err error
+
+	// noted is set for paths a Report points out
+	noted bool
+}
+
+// Note points out a path left out of a walk that isn't counted as an
+// exclusion, like a directory pruned for being too deep
+type Note struct {
+	Path   string
+	Reason string
+}
+
+// Report describes what the filters left out of a walk
+type Report struct {
+	// Excluded counts the paths each exclusion matched
+	Excluded Exclusions
+
+	// Notes lists paths worth pointing out in a preview
+	Notes []Note
+
+	// Skipped lists the paths SkipErrors left out because they couldn't
+	// be read, with the error as the reason
+	Skipped []Note
+}
+
+// Merge adds what other left out to the report; other may be nil
+func (r *Report) Merge(other *Report) {
+	if other == nil {
+		return
+	}
+
+	if r.Excluded == nil {
+		r.Excluded = make(Exclusions)
+	}
+
+	for key, n := range other.Excluded {
+		r.Excluded[key] += n
+	}
+
+	r.Notes = append(r.Notes, other.Notes...)
+	r.Skipped = append(r.Skipped, other.Skipped...)
+}
+
+// Exclusions counts how many paths each exclusion matched during a walk,
+// keyed by the flag and the value that matched
+type Exclusions map[string]int
+
+// exclusionKey builds the key an exclusion is counted under
+func exclusionKey(flag, value string) string {
+	return "--" + flag + "=" + value
+}
+
+// Count returns how many paths the given flag and value excluded
+func (e Exclusions) Count(flag, value string) int {
+	return e[exclusionKey(flag, value)]
+}
+
+// Simulate returns the decision for every path below root without
+// reading any file contents, so previews can be built on the same logic
+// used to generate contexts. Decisions based on contents, like leaving
+// binary files out, aren't made. It stops early with the error of ctx
+// once it's done.
+func Simulate(ctx context.Context, root string, opts Options) ([]Decision, error) {
+	var decisions []Decision
+
+	err := decide(ctx, filepath.Clean(root), opts, func(d Decision, info os.FileInfo) error {
+		decisions = append(decisions, d)
+		return nil
+	})
+
+	return decisions, err
+}
+
+// Walk walks root and calls fn for every file that passes the filters in
+// opts, reporting what the filters left out
+func Walk(ctx context.Context, root string, opts Options, fn func(path string, info os.FileInfo) error) (*Report, error) {
+	report := &Report{Excluded: make(Exclusions)}
+
+	err := decide(ctx, root, opts, func(d Decision, info os.FileInfo) error {
+		if opts.Trace != nil {
+			opts.Trace(d)
+		}
+
+		switch {
+		case d.Err != nil:
+			// The path is already part of the note
+			reason := d.Err
+			var pathErr *fs.PathError
+			if errors.As(reason, &pathErr) {
+				reason = pathErr.Err
+			}
+
+			report.Skipped = append(report.Skipped, Note{Path: d.Path, Reason: reason.Error()})
+		case d.Included && !d.Dir:
+			return fn(d.Path, info)
+		case d.Exclusion != "":
+			report.Excluded[d.Exclusion]++
+		case d.noted:
+			report.Notes = append(report.Notes, Note{Path: d.Path, Reason: d.Reason})
+		}
+
+		return nil
+	})
+
+	return report, err
+}
+
+// decide walks root, or the listed files, and calls fn with the decision
+// for every path reached. Excluded directories aren't entered.
+func decide(ctx context.Context, root string, opts Options, fn func(d Decision, info os.FileInfo) error) error {
+	// Files listed explicitly bypass the walk and its filters
+	if opts.Files != nil {
+		return decideListed(ctx, root, opts, fn)
+	}
+
+	dc, err := newDecider(root, opts)
+	if err != nil {
+		return err
+	}
+
+	return dc.tree.walk(opts, func(path string, entry fs.DirEntry, err error) error {
+		// Only files making it through the filters are stat'ed, since
+		// it costs a system call on most platforms
+		var info os.FileInfo
+
+		// failed handles an error reading path: it's returned to be
+		// handled by the caller, unless it can be skipped; the root
+		// itself has to be readable
+		failed := func(err error) error {
+			if path == root && errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("directory %q %w: %w", root, ErrDirNotFound, err)
+			}
+
+			if !opts.SkipErrors || path == root {
+				return Unreadable(err)
+			}
+
+			d := Decision{Path: path, Reason: "couldn't be read", Err: err}
+			if entry != nil {
+				d.Dir = entry.IsDir()
+			}
+
+			return fn(d, info)
+		}
+
+		if err != nil {
+			return failed(err)
+		}
+
+		// Stop walking if the run was interrupted
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		d := Decision{Path: path, Dir: entry.IsDir()}
+
+		// exclude leaves the file out
+		exclude := func(reason, key string) error {
+			d.Reason, d.Exclusion = reason, key
+			return fn(d, info)
+		}
+
+		// Directories excluded by the filters are left out along with
+		// everything below them, which isn't walked at all
+		if d.Dir {
+			if excluded, found := dc.excludeDir(path); found {
+				d = excluded
+				if err := fn(d, info); err != nil {
+					return err
+				}
+
+				return filepath.SkipDir
+			}
+
+			if err := dc.enter(path); err != nil {
+				return err
+			}
+
+			// The root itself isn't a decision anyone asked about
+			if path == root {
+				return nil
+			}
+
+			d.Included = true
+			return fn(d, info)
+		}
+
+		// Skip files that are in the excluded file names list
+		if _, found := dc.excludedFiles[entry.Name()]; found {
+			key := exclusionKey("exclude-file", entry.Name())
+			return exclude("excluded by "+key, key)
+		}
+
+		// Skip anything excluded by a preset or a .contextignore file
+		if rule, found := dc.ignores.match(path, false); path != root && found && !rule.negate {
+			key := rule.pattern + " (" + rule.source + ")"
+			return exclude("excluded by "+key, key)
+		}
+
+		rel := dc.rel(path)
+
+		// Only keep files tracked by git, when asked to
+		if !opts.Tracked.hasFile(rel) {
+			key := exclusionKey("tracked-only", "true")
+			return exclude("excluded by "+key+", it isn't tracked by git", key)
+		}
+
+		// Only keep files reachable from the entry points, when asked to
+		if !opts.Reachable.hasFile(rel) {
+			return exclude("excluded by "+opts.ReachableFrom+", it isn't reachable from the entry points", opts.ReachableFrom)
+		}
+
+		// Only keep files owned by the given owner, when asked to
+		if opts.Owner != "" && !opts.CodeOwners.Owns(rel, opts.Owner) {
+			key := exclusionKey("codeowners", opts.Owner)
+			return exclude("excluded by "+key+", it's owned by someone else", key)
+		}
+
+		// Only keep files matching the globs, if any
+		if !dc.globs.matches(rel) {
+			return exclude("not matched by any glob", "")
+		}
+
+		// Leave tests in or out as requested
+		if !opts.Tests.Keeps(rel) && !(opts.Tests == TestsOnly && opts.WithTestedFiles && hasTestFile(dc.tree, path)) {
+			key := exclusionKey("tests", string(opts.Tests))
+			return exclude("excluded by "+key, key)
+		}
+
+		// Leave hidden files in or out as requested
+		if !opts.Hidden.Keeps(rel) {
+			key := exclusionKey("hidden", string(opts.Hidden))
+			return exclude("excluded by "+key, key)
+		}
+
+		// The checks left need to know more about the file
+		if info, err = entry.Info(); err != nil {
+			return failed(err)
+		}
+
+		// Skip files too large to be worth their tokens
+		if opts.MaxFileSize > 0 && info.Size() > int64(opts.MaxFileSize) && !opts.IncludeLarge {
+			key := exclusionKey("max-file-size", opts.MaxFileSize.String())
+			return exclude("excluded by "+key, key)
+		}
+
+		// Skip files that belong to a different shard or aren't sampled
+		if reason := opts.partition(root, path); reason != "" {
+			return exclude(reason, "")
+		}
+
+		d.Included = true
+		return fn(d, info)
+	})
+}
+
+// decideListed calls fn with the decision for every file in opts.Files,
+// in the order given
+func decideListed(ctx context.Context, root string, opts Options, fn func(d Decision, info os.FileInfo) error) error {
+	t := newTree(root, opts)
+
+	for _, path := range opts.Files {
+		// Stop if the run was interrupted
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+
+		// Files that are missing are a mistake in the list, not something
+		// to skip
+		info, err := t.stat(path)
+		if err != nil && opts.SkipErrors && !os.IsNotExist(err) {
+			if err := fn(Decision{Path: path, Reason: "couldn't be read", Err: err}, nil); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("error checking listed file %q: %w", path, err)
+		}
+
+		if info.IsDir() {
+			return fmt.Errorf("listed path %q is a directory, only files can be listed", path)
+		}
+
+		d := Decision{Path: path, Included: true}
+		if d.Reason = opts.partition(root, path); d.Reason != "" {
+			d.Included = false
+		}
+
+		if err := fn(d, info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// partition returns why the file at path is left out by the shard or
+// the sample, or an empty string when it's kept
+func (o Options) partition(root, path string) string {
+	if !o.Shard.Includes(root, path) {
+		return "not in --shard " + o.Shard.String()
+	}
+
+	if !o.Sample.Includes(root, path) {
+		return fmt.Sprintf("not in --sample %v", o.Sample.Fraction)
+	}
+
+	return ""
+}
+
+// pathDepth returns how many levels below root path is, where the
+// direct children of root are at level 1
+func pathDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+
+	return strings.Count(filepath.ToSlash(rel), "/") + 1
+}
+
+func contains[T comparable](slice []T, value T) bool {
+	for _, item := range slice {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,113 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IsGlob reports whether s holds any glob metacharacters
+func IsGlob(s string) bool {
+	return strings.ContainsAny(s, "*?[{")
+}
+
+// glob is a compiled glob matched against slash-separated paths relative
+// to the root, where "**" matches any number of directories and "{a,b}"
+// either alternative
+type glob struct {
+	re *regexp.Regexp
+
+	// base is the directory before the first metacharacter, which every
+	// match is inside of
+	base string
+}
+
+// globSet holds the globs a walk is restricted to; an empty set doesn't
+// restrict anything
+type globSet []glob
+
+// compileGlobs compiles patterns relative to root
+func compileGlobs(root string, patterns []string) (globSet, error) {
+	globs := make(globSet, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		rel := pattern
+		if filepath.IsAbs(rel) {
+			var err error
+			if rel, err = filepath.Rel(root, rel); err != nil {
+				return nil, fmt.Errorf("glob %q isn't inside %q", pattern, root)
+			}
+		}
+
+		rel = path.Clean(filepath.ToSlash(rel))
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			return nil, fmt.Errorf("glob %q isn't inside %q", pattern, root)
+		}
+
+		var sb strings.Builder
+		sb.WriteString("^")
+		if err := globToRegexp(&sb, rel); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+		sb.WriteString("$")
+
+		re, err := regexp.Compile(sb.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+
+		// Keep the directories before the first one with a metacharacter
+		var base []string
+		parts := strings.Split(rel, "/")
+		for _, part := range parts[:len(parts)-1] {
+			if IsGlob(part) {
+				break
+			}
+
+			base = append(base, part)
+		}
+
+		globs = append(globs, glob{re: re, base: strings.Join(base, "/")})
+	}
+
+	return globs, nil
+}
+
+// matches reports whether the file at rel, a slash-separated path
+// relative to the root, matches any glob
+func (gs globSet) matches(rel string) bool {
+	if len(gs) == 0 {
+		return true
+	}
+
+	for _, g := range gs {
+		if g.re.MatchString(rel) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enters reports whether the directory at rel, a slash-separated path
+// relative to the root, may hold files matching any glob
+func (gs globSet) enters(rel string) bool {
+	if len(gs) == 0 {
+		return true
+	}
+
+	for _, g := range gs {
+		if g.base == "" || within(rel, g.base) || within(g.base, rel) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// within reports whether the slash-separated path p is dir or inside it
+func within(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
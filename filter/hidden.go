@@ -0,0 +1,73 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Modes for the --hidden flag
+const (
+	HiddenInclude = "include"
+	HiddenSkip    = "skip"
+	HiddenOnly    = "only"
+)
+
+// isHidden reports whether a file or directory name is hidden, starting
+// with a dot like .github or .env
+func isHidden(name string) bool {
+	return len(name) > 1 && strings.HasPrefix(name, ".") && name != ".."
+}
+
+// IsHiddenPath reports whether the file at rel, relative to the scan
+// root and separated by forward slashes, is hidden or inside a hidden
+// directory
+func IsHiddenPath(rel string) bool {
+	for _, part := range strings.Split(rel, "/") {
+		if isHidden(part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HiddenMode decides whether hidden files and directories are included,
+// skipped, or the only ones included, on top of the exclusions that
+// already name some of them, like .git. It implements pflag.Value so
+// invalid modes are rejected while parsing flags.
+type HiddenMode string
+
+func (h *HiddenMode) String() string {
+	if *h == "" {
+		return HiddenInclude
+	}
+
+	return string(*h)
+}
+
+func (h *HiddenMode) Set(value string) error {
+	switch value {
+	case HiddenInclude, HiddenSkip, HiddenOnly:
+		*h = HiddenMode(value)
+		return nil
+	}
+
+	return fmt.Errorf("must be one of: %s, %s, %s", HiddenInclude, HiddenSkip, HiddenOnly)
+}
+
+func (h *HiddenMode) Type() string {
+	return "mode"
+}
+
+// Keeps reports whether a file at rel, relative to the scan root, passes
+// the hidden files filter
+func (h HiddenMode) Keeps(rel string) bool {
+	switch h {
+	case HiddenSkip:
+		return !IsHiddenPath(rel)
+	case HiddenOnly:
+		return IsHiddenPath(rel)
+	}
+
+	return true
+}
@@ -0,0 +1,443 @@
+package filter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ContextIgnoreFileName is the name of the files, at the scan root or in
+// any directory below it, holding gitignore-style exclusions that only
+// apply to context generation
+const ContextIgnoreFileName = ".contextignore"
+
+// ignoreRule is a single line of a gitignore-style file
+type ignoreRule struct {
+	pattern string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+
+	// name and ext are set for patterns matched without re, since most
+	// are plain names, like dist, or extensions, like *.log
+	name string
+	ext  string
+
+	// source tells where the rule comes from, like the path of the
+	// .contextignore file holding it
+	source string
+}
+
+// parseIgnoreRules reads gitignore-style rules from r
+func parseIgnoreRules(r io.Reader) ([]ignoreRule, error) {
+	var rules []ignoreRule
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		// Skip blank lines and comments
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Trailing spaces are ignored unless escaped with a backslash
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimSuffix(line, " ")
+		}
+
+		rule := ignoreRule{pattern: line}
+
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+
+		re, err := compileIgnorePattern(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", rule.pattern, err)
+		}
+
+		rule.re = re
+		rule.name, rule.ext = literalPattern(line)
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+// compileIgnorePattern converts a gitignore-style pattern into a regular
+// expression matching slash-separated paths relative to the directory
+// holding the pattern. Patterns with a slash other than a trailing one
+// are anchored to that directory; all others match at any depth.
+func compileIgnorePattern(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	if strings.HasPrefix(pattern, "**/") {
+		pattern = pattern[3:]
+		sb.WriteString("(?:.*/)?")
+	} else if strings.HasPrefix(pattern, "/") {
+		pattern = pattern[1:]
+	} else if !strings.Contains(pattern, "/") {
+		sb.WriteString("(?:.*/)?")
+	}
+
+	if err := globToRegexp(&sb, pattern); err != nil {
+		return nil, err
+	}
+
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// literalPattern tells how pattern can be matched without a regular
+// expression: by comparing a whole name, at any depth, with name, or by
+// checking names end with ext, for patterns like *.log. Both are empty
+// when the pattern needs its regular expression.
+func literalPattern(pattern string) (name, ext string) {
+	if pattern == "" || strings.ContainsAny(pattern, "/\\?[]{}") {
+		return "", ""
+	}
+
+	if rest, found := strings.CutPrefix(pattern, "*"); found {
+		if rest == "" || strings.Contains(rest, "*") {
+			return "", ""
+		}
+
+		return "", rest
+	}
+
+	if strings.Contains(pattern, "*") {
+		return "", ""
+	}
+
+	return pattern, ""
+}
+
+// matches reports whether the rule matches rel, a slash-separated path
+// relative to the directory holding the rule
+func (r ignoreRule) matches(rel string) bool {
+	switch {
+	case r.name != "":
+		return baseName(rel) == r.name
+	case r.ext != "":
+		return strings.HasSuffix(baseName(rel), r.ext)
+	}
+
+	return r.re.MatchString(rel)
+}
+
+// baseName returns the last name of rel, a slash-separated path
+func baseName(rel string) string {
+	return rel[strings.LastIndexByte(rel, '/')+1:]
+}
+
+// globToRegexp writes the regular expression equivalent of a glob to sb,
+// where "*" and "?" never match a slash, "**" matches across them and
+// "{a,b}" matches either alternative
+func globToRegexp(sb *strings.Builder, glob string) error {
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				atStart := i == 0 || glob[i-1] == '/'
+				atEnd := i+2 == len(glob) || glob[i+2] == '/'
+
+				if atStart && atEnd {
+					if i+2 == len(glob) {
+						// A trailing "/**" matches everything inside
+						sb.WriteString(".*")
+					} else {
+						// "/**/" matches zero or more directories
+						sb.WriteString("(?:.*/)?")
+						i++
+					}
+
+					i++
+					continue
+				}
+			}
+
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return errors.New("unterminated character class")
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '{':
+			end := closingBrace(glob[i:])
+			if end < 0 {
+				return errors.New("unterminated alternatives")
+			}
+
+			sb.WriteString("(?:")
+			for j, alt := range splitAlternatives(glob[i+1 : i+end]) {
+				if j > 0 {
+					sb.WriteString("|")
+				}
+
+				if err := globToRegexp(sb, alt); err != nil {
+					return err
+				}
+			}
+			sb.WriteString(")")
+			i += end
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return nil
+}
+
+// closingBrace returns the index of the brace closing the one s starts
+// with, accounting for nested ones, or -1 if it isn't closed
+func closingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// splitAlternatives splits the contents of braces at the commas that
+// aren't inside nested braces
+func splitAlternatives(s string) []string {
+	var (
+		alts  []string
+		depth int
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alts = append(alts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(alts, s[start:])
+}
+
+// SplitPatterns splits the comma-separated lists of patterns given to
+// flags like --minify at the commas outside braces, so a pattern like
+// *.{go,js} stays whole
+func SplitPatterns(values []string) []string {
+	var patterns []string
+	for _, v := range values {
+		patterns = append(patterns, splitAlternatives(v)...)
+	}
+
+	return patterns
+}
+
+// ignoreSet holds the .contextignore files found during a walk, keyed by
+// the directory they were found in
+type ignoreSet struct {
+	tree  tree
+	rules map[string][]ignoreRule
+
+	// chains remembers, for every directory whose entries were matched,
+	// the directories above it holding rules
+	chains map[string][]string
+}
+
+func newIgnoreSet(t tree) *ignoreSet {
+	return &ignoreSet{tree: t, rules: make(map[string][]ignoreRule), chains: make(map[string][]string)}
+}
+
+// load reads the .contextignore file in dir, if there's one
+func (s *ignoreSet) load(dir string) error {
+	path := filepath.Join(dir, ContextIgnoreFileName)
+
+	f, err := s.tree.open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("error opening %q: %w", path, err)
+	}
+	defer f.Close()
+
+	rules, err := parseIgnoreRules(f)
+	if err != nil {
+		return fmt.Errorf("error reading %q: %w", path, err)
+	}
+
+	for i := range rules {
+		rules[i].source = path
+	}
+
+	s.add(dir, rules)
+	return nil
+}
+
+// add appends rules that apply to the contents of dir, taking precedence
+// over the ones added before them
+func (s *ignoreSet) add(dir string, rules []ignoreRule) {
+	if len(rules) > 0 {
+		s.rules[dir] = append(s.rules[dir], rules...)
+		clear(s.chains)
+	}
+}
+
+// chain returns the directories holding rules from the root down to dir,
+// computed once per directory since all of its entries need them
+func (s *ignoreSet) chain(dir string) []string {
+	if c, found := s.chains[dir]; found {
+		return c
+	}
+
+	var c []string
+	if parent := filepath.Dir(dir); dir != s.tree.root && parent != dir {
+		c = s.chain(parent)
+	}
+
+	if _, found := s.rules[dir]; found {
+		c = append(slices.Clip(c), dir)
+	}
+
+	s.chains[dir] = c
+	return c
+}
+
+// match returns the rule of the .contextignore files in the parent
+// directories of path deciding whether it's excluded, if any rule matches
+// it at all. Rules in deeper directories, and later rules within a file,
+// take precedence; a negated rule means path is included.
+func (s *ignoreSet) match(path string, isDir bool) (ignoreRule, bool) {
+	if len(s.rules) == 0 {
+		return ignoreRule{}, false
+	}
+
+	var (
+		matched ignoreRule
+		found   bool
+	)
+	for _, dir := range s.chain(filepath.Dir(path)) {
+		rules := s.rules[dir]
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		for _, rule := range rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+
+			if rule.matches(rel) {
+				matched, found = rule, true
+			}
+		}
+	}
+
+	return matched, found
+}
+
+// Patterns is a list of gitignore-style patterns matched against single
+// paths, where later patterns take precedence over earlier ones
+type Patterns []ignoreRule
+
+// ParsePatterns compiles gitignore-style patterns given on the command
+// line or in the configuration file
+func ParsePatterns(patterns []string) (Patterns, error) {
+	rules, err := parseIgnoreRules(strings.NewReader(strings.Join(slashPatterns(patterns), "\n")))
+	return Patterns(rules), err
+}
+
+// slashPatterns rewrites patterns written on Windows, where paths are
+// separated by backslashes, to use the forward slashes of the paths
+// they're matched against. Elsewhere a backslash escapes the character
+// after it, like in gitignore files.
+func slashPatterns(patterns []string) []string {
+	if filepath.Separator != '\\' {
+		return patterns
+	}
+
+	slashed := make([]string, len(patterns))
+	for i, p := range patterns {
+		slashed[i] = strings.ReplaceAll(p, `\`, "/")
+	}
+
+	return slashed
+}
+
+// Match reports whether rel, a slash-separated relative path, is matched
+// by the patterns and not negated by a later one
+func (p Patterns) Match(rel string) bool {
+	matched := false
+	for _, rule := range p {
+		if rule.matches(rel) {
+			matched = !rule.negate
+		}
+	}
+
+	return matched
+}
+
+// Strings returns the patterns as they were given
+func (p Patterns) Strings() []string {
+	if len(p) == 0 {
+		return nil
+	}
+
+	patterns := make([]string, 0, len(p))
+	for _, rule := range p {
+		patterns = append(patterns, rule.pattern)
+	}
+
+	return patterns
+}
@@ -0,0 +1,167 @@
+package filter
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestClosingBrace(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"{a,b}", 4},
+		{"{a,b}.go", 4},
+		{"{a,{b,c}}", 8},
+		{"{a,{b,c}d}e}", 9},
+		{`{a\},b}`, 6},
+		{`{a\{,b}`, 6},
+		{"{a,b", -1},
+		{"{a,{b,c}", -1},
+		{`{a\}`, -1},
+	}
+
+	for _, tt := range tests {
+		if got := closingBrace(tt.in); got != tt.want {
+			t.Errorf("closingBrace(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitAlternatives(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"a", []string{"a"}},
+		{"a,b", []string{"a", "b"}},
+		{"a,,b", []string{"a", "", "b"}},
+		{"a,{b,c}d", []string{"a", "{b,c}d"}},
+		{"{a,{b,c}},d", []string{"{a,{b,c}}", "d"}},
+		{`a\,b,c`, []string{`a\,b`, "c"}},
+		{`a\{,b`, []string{`a\{`, "b"}},
+	}
+
+	for _, tt := range tests {
+		if got := splitAlternatives(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("splitAlternatives(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitPatterns(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want []string
+	}{
+		{[]string{"*.go"}, []string{"*.go"}},
+		{[]string{"*.go,*.js"}, []string{"*.go", "*.js"}},
+		{[]string{"*.{go,js}"}, []string{"*.{go,js}"}},
+		{[]string{"README.md,src/*.{go,js}", "docs/**"}, []string{"README.md", "src/*.{go,js}", "docs/**"}},
+		{[]string{`a\,b.txt`}, []string{`a\,b.txt`}},
+	}
+
+	for _, tt := range tests {
+		if got := SplitPatterns(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("SplitPatterns(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob string
+		want string
+	}{
+		{"*.go", `[^/]*\.go`},
+		{"a?c", `a[^/]c`},
+		{"**", `.*`},
+		{"a/**", `a/.*`},
+		{"a/**/b", `a/(?:.*/)?b`},
+		{"a**b", `a[^/]*[^/]*b`},
+		{"[abc].go", `[abc]\.go`},
+		{"[!abc].go", `[^abc]\.go`},
+		{"*.{go,js}", `[^/]*\.(?:go|js)`},
+		{"{a,{b,c}d}", `(?:a|(?:b|c)d)`},
+		{`{a\,b,c}`, `(?:a,b|c)`},
+		{`\*.go`, `\*\.go`},
+		{`a\`, `a`},
+	}
+
+	for _, tt := range tests {
+		var sb strings.Builder
+		if err := globToRegexp(&sb, tt.glob); err != nil {
+			t.Errorf("globToRegexp(%q) failed: %v", tt.glob, err)
+			continue
+		}
+
+		if got := sb.String(); got != tt.want {
+			t.Errorf("globToRegexp(%q) = %q, want %q", tt.glob, got, tt.want)
+		}
+	}
+}
+
+func TestGlobToRegexpErrors(t *testing.T) {
+	tests := []struct {
+		glob string
+		want string
+	}{
+		{"[abc", "unterminated character class"},
+		{"*.[go", "unterminated character class"},
+		{"*.{go,js", "unterminated alternatives"},
+		{"{a,{b,c}", "unterminated alternatives"},
+		{"{a,[b}", "unterminated character class"},
+		{`{a\}`, "unterminated alternatives"},
+	}
+
+	for _, tt := range tests {
+		var sb strings.Builder
+		err := globToRegexp(&sb, tt.glob)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("globToRegexp(%q) error = %v, want %q", tt.glob, err, tt.want)
+		}
+	}
+}
+
+func TestPatternsMatch(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		want     bool
+	}{
+		{[]string{"*.{go,js}"}, "main.go", true},
+		{[]string{"*.{go,js}"}, "src/app.js", true},
+		{[]string{"*.{go,js}"}, "style.css", false},
+		{[]string{"src/*.{go,js}"}, "src/main.go", true},
+		{[]string{"src/*.{go,js}"}, "lib/src/main.go", false},
+		{[]string{"{a,{b,c}d}.txt"}, "cd.txt", true},
+		{[]string{"{a,{b,c}d}.txt"}, "c.txt", false},
+		{[]string{`{a\,b,c}.txt`}, "a,b.txt", true},
+		{[]string{`{a\,b,c}.txt`}, "a.txt", false},
+		{[]string{"docs/**"}, "docs/a/b.md", true},
+		{[]string{"**/testdata/**"}, "pkg/testdata/x.json", true},
+		{[]string{"*.go", "!main.go"}, "main.go", false},
+		{[]string{"*.go", "!main.go"}, "util.go", true},
+	}
+
+	for _, tt := range tests {
+		p, err := ParsePatterns(tt.patterns)
+		if err != nil {
+			t.Errorf("ParsePatterns(%q) failed: %v", tt.patterns, err)
+			continue
+		}
+
+		if got := p.Match(tt.path); got != tt.want {
+			t.Errorf("%q matching %q = %v, want %v", tt.patterns, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParsePatternsErrors(t *testing.T) {
+	for _, pattern := range []string{"[abc", "*.{go,js", "src/{a,{b}"} {
+		if _, err := ParsePatterns([]string{pattern}); err == nil || !strings.Contains(err.Error(), pattern) {
+			t.Errorf("ParsePatterns(%q) error = %v, want one naming the pattern", pattern, err)
+		}
+	}
+}
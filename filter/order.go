@@ -0,0 +1,55 @@
+package filter
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// isReadme reports whether name is the name of a README file, like
+// README, README.md or readme.txt
+func isReadme(name string) bool {
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	return strings.EqualFold(stem, "readme")
+}
+
+// order sorts the entries of a directory, sorted by name, in the order
+// a walk with o visits them
+func (o Options) order(entries []fs.DirEntry) {
+	if !o.ReadmeFirst {
+		return
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return isReadme(entries[i].Name()) && !entries[i].IsDir() && !(isReadme(entries[j].Name()) && !entries[j].IsDir())
+	})
+}
+
+// Less reports whether a walk with o visits path a before path b, where
+// both are below the same root. Paths are compared one element at a
+// time, like a walk visits them.
+func (o Options) Less(a, b string) bool {
+	ap := strings.Split(filepath.ToSlash(a), "/")
+	bp := strings.Split(filepath.ToSlash(b), "/")
+
+	for i := 0; i < len(ap) && i < len(bp); i++ {
+		if ap[i] == bp[i] {
+			continue
+		}
+
+		// Only files are moved first, and the last element of a path is
+		// the only one that can be a file
+		if o.ReadmeFirst {
+			aReadme := i == len(ap)-1 && isReadme(ap[i])
+			bReadme := i == len(bp)-1 && isReadme(bp[i])
+			if aReadme != bReadme {
+				return aReadme
+			}
+		}
+
+		return ap[i] < bp[i]
+	}
+
+	return len(ap) < len(bp)
+}
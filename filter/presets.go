@@ -0,0 +1,87 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// preset is a named bundle of gitignore-style exclusion patterns
+type preset struct {
+	name     string
+	patterns []string
+}
+
+var (
+	// minimalPatterns drop editor, OS and log noise
+	minimalPatterns = []string{
+		".idea/", ".vscode/", "*.swp", "*.swo", "*~", ".DS_Store", "Thumbs.db", "*.log",
+	}
+
+	// standardPatterns also drop dependencies, build outputs and lockfiles
+	standardPatterns = []string{
+		"vendor/", "dist/", "build/", "out/", "target/", "bin/", "coverage/",
+		".next/", ".nuxt/", ".cache/", "__pycache__/", "*.pyc", ".venv/", "venv/",
+		"*.min.js", "*.min.css", "*.map",
+		"package-lock.json", "yarn.lock", "pnpm-lock.yaml", "go.sum", "Cargo.lock",
+		"poetry.lock", "Pipfile.lock", "Gemfile.lock", "composer.lock",
+	}
+
+	// aggressivePatterns also drop tests, fixtures, generated code and
+	// changelogs, leaving mostly the code that matters
+	aggressivePatterns = append(testIgnorePatterns(), []string{
+		"fixtures/", "__fixtures__/", "__snapshots__/", "*.snap",
+		"*.pb.go", "*.pb.gw.go", "*_gen.go", "*.gen.go", "*_generated.*", "*.generated.*",
+		"zz_generated*", "*_pb2.py", "*_pb2_grpc.py",
+		"CHANGELOG*", "CHANGES*", "HISTORY*", "RELEASE_NOTES*",
+	}...)
+)
+
+// presets lists the presets from least to most aggressive; each one
+// includes the patterns of the ones before it
+var presets = []preset{
+	{name: "minimal", patterns: minimalPatterns},
+	{name: "standard", patterns: standardPatterns},
+	{name: "aggressive", patterns: aggressivePatterns},
+}
+
+// PresetNames returns the names of all presets
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for _, p := range presets {
+		names = append(names, p.name)
+	}
+
+	return names
+}
+
+// PresetPatterns returns the patterns of the named preset, along with
+// those of the presets before it
+func PresetPatterns(name string) ([]string, error) {
+	var patterns []string
+
+	for _, p := range presets {
+		patterns = append(patterns, p.patterns...)
+
+		if p.name == name {
+			return patterns, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown preset %q, must be one of: %s", name, strings.Join(PresetNames(), ", "))
+}
+
+// PresetExclusion reports whether the exclusion key of a decision comes
+// from a pattern of the named preset
+func PresetExclusion(key, name string) bool {
+	return strings.HasSuffix(key, " ("+exclusionKey("preset", name)+")")
+}
+
+// presetRules returns the exclusion rules of the named preset
+func presetRules(name string) ([]ignoreRule, error) {
+	patterns, err := PresetPatterns(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseIgnoreRules(strings.NewReader(strings.Join(patterns, "\n")))
+}
@@ -0,0 +1,227 @@
+package filter
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// decider holds what deciding on the paths of a walk of root needs,
+// compiled once for the whole walk
+type decider struct {
+	root     string
+	opts     Options
+	tree     tree
+	globs    globSet
+	ignores  *ignoreSet
+	excludes []ignoreRule
+
+	// Every entry is checked against the excluded names
+	excludedFolders map[string]struct{}
+	excludedFiles   map[string]struct{}
+}
+
+// newDecider compiles the filters in opts for a walk of root
+func newDecider(root string, opts Options) (*decider, error) {
+	globs, err := compileGlobs(root, opts.Globs)
+	if err != nil {
+		return nil, err
+	}
+
+	excludes, err := parseIgnoreRules(strings.NewReader(strings.Join(slashPatterns(opts.Exclude), "\n")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid --exclude pattern: %w", err)
+	}
+
+	for i := range excludes {
+		excludes[i].source = exclusionKey("exclude", excludes[i].pattern)
+	}
+
+	t := newTree(root, opts)
+	dc := &decider{
+		root:            root,
+		opts:            opts,
+		tree:            t,
+		globs:           globs,
+		ignores:         newIgnoreSet(t),
+		excludes:        excludes,
+		excludedFolders: newNameSet(opts.ExcludeFolders),
+		excludedFiles:   newNameSet(opts.ExcludeFiles),
+	}
+
+	// Presets go first so .contextignore files can re-include their files
+	if opts.Preset != "" {
+		rules, err := presetRules(opts.Preset)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range rules {
+			rules[i].source = exclusionKey("preset", opts.Preset)
+		}
+
+		dc.ignores.add(root, rules)
+	}
+
+	return dc, nil
+}
+
+// rel returns path relative to the root, separated by forward slashes
+func (dc *decider) rel(path string) string {
+	rel, err := filepath.Rel(dc.root, path)
+	if err != nil {
+		rel = path
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// excludeDir returns the decision leaving out the directory at path, and
+// everything below it, when the filters exclude it. The directories
+// above it have to be entered first.
+func (dc *decider) excludeDir(path string) (Decision, bool) {
+	opts := dc.opts
+	name := filepath.Base(path)
+
+	exclude := func(reason, key string) (Decision, bool) {
+		return Decision{Path: path, Dir: true, Reason: reason, Exclusion: key}, true
+	}
+
+	// Check if the directory should be excluded
+	if _, found := dc.excludedFolders[name]; found {
+		key := exclusionKey("exclude-folder", name)
+		return exclude("excluded by "+key, key)
+	}
+
+	// The root is only ever left out by name
+	if path == dc.root {
+		return Decision{}, false
+	}
+
+	// Skip anything excluded by a preset or a .contextignore file
+	if rule, found := dc.ignores.match(path, true); found && !rule.negate {
+		key := rule.pattern + " (" + rule.source + ")"
+		return exclude("excluded by "+key, key)
+	}
+
+	rel := dc.rel(path)
+
+	// Skip directories that can't hold files matching the globs
+	if !dc.globs.enters(rel) {
+		return exclude("can't hold files matching the globs", "")
+	}
+
+	// Skip directories without any tracked files
+	if !opts.Tracked.hasDir(rel) {
+		key := exclusionKey("tracked-only", "true")
+		return exclude("excluded by "+key+", it holds no files tracked by git", key)
+	}
+
+	// Skip directories without any reachable files
+	if !opts.Reachable.hasDir(rel) {
+		return exclude("excluded by "+opts.ReachableFrom+", it holds no files reachable from the entry points", opts.ReachableFrom)
+	}
+
+	// Skip hidden directories, like .github, when asked to
+	if opts.Hidden == HiddenSkip && isHidden(name) {
+		key := exclusionKey("hidden", HiddenSkip)
+		return exclude("excluded by "+key, key)
+	}
+
+	// Skip directories whose files would be too deep
+	if opts.MaxDepth > 0 && pathDepth(dc.root, path) >= opts.MaxDepth {
+		d, _ := exclude(fmt.Sprintf("deeper than --max-depth %d", opts.MaxDepth), "")
+		d.noted = true
+		return d, true
+	}
+
+	return Decision{}, false
+}
+
+// enter picks up the exclusions that apply to the contents of the
+// directory at path, which the filters didn't exclude
+func (dc *decider) enter(path string) error {
+	if !dc.opts.NoContextIgnore {
+		if err := dc.ignores.load(path); err != nil {
+			return err
+		}
+	}
+
+	// Patterns given to --exclude take precedence over the .contextignore
+	// file at the root, but not deeper ones
+	if path == dc.root {
+		dc.ignores.add(dc.root, dc.excludes)
+	}
+
+	return nil
+}
+
+// Pruner tells which directories below a root the filters leave out
+// along with everything below them, so tools walking trees their own way
+// can skip the same subtrees a walk does. Decisions are made once per
+// directory and inherited by the directories below excluded ones.
+type Pruner struct {
+	dc      *decider
+	decided map[string]Decision
+}
+
+// NewPruner returns a Pruner for root with the filters in opts
+func NewPruner(root string, opts Options) (*Pruner, error) {
+	dc, err := newDecider(filepath.Clean(root), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pruner{dc: dc, decided: make(map[string]Decision)}, nil
+}
+
+// Prune returns the decision for the directory at dir, below the root.
+// When it isn't included, dir and everything below it is left out, and
+// the decision is the one made for dir or the parent it inherits it from,
+// named by its Path. Reading the .contextignore files on the way can
+// fail.
+func (p *Pruner) Prune(dir string) (Decision, error) {
+	dir = filepath.Clean(dir)
+	if d, found := p.decided[dir]; found {
+		return d, nil
+	}
+
+	if rel, err := filepath.Rel(p.dc.root, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return Decision{}, fmt.Errorf("directory %q isn't below %q", dir, p.dc.root)
+	}
+
+	// Directories below excluded ones inherit their decision
+	if dir != p.dc.root {
+		parent, err := p.Prune(filepath.Dir(dir))
+		if err != nil {
+			return Decision{}, err
+		}
+
+		if !parent.Included {
+			p.decided[dir] = parent
+			return parent, nil
+		}
+	}
+
+	d, excluded := p.dc.excludeDir(dir)
+	if !excluded {
+		if err := p.dc.enter(dir); err != nil {
+			return Decision{}, err
+		}
+
+		d = Decision{Path: dir, Dir: true, Included: true}
+	}
+
+	p.decided[dir] = d
+	return d, nil
+}
+
+// newNameSet returns a set of names to look them up in constant time
+func newNameSet(names []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+
+	return set
+}
@@ -0,0 +1,51 @@
+package filter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+)
+
+// Sample keeps a random fraction of the included files. The selection
+// only depends on the seed and the paths, so runs with the same seed pick
+// the same files and experiments can be compared against each other.
+type Sample struct {
+	Fraction float64
+	Seed     uint64
+}
+
+// Check validates the sampling settings
+func (s Sample) Check() error {
+	if s.Fraction < 0 || s.Fraction > 1 {
+		return fmt.Errorf("flag --sample must be between 0 and 1, got %v", s.Fraction)
+	}
+
+	return nil
+}
+
+// Enabled reports whether only some files are kept
+func (s Sample) Enabled() bool {
+	return s.Fraction > 0 && s.Fraction < 1
+}
+
+// Includes reports whether the file at path, relative to root, is part
+// of the sample. Like shards, paths are hashed relative to the root and
+// with forward slashes so the sample doesn't depend on the checkout.
+func (s Sample) Includes(root, path string) bool {
+	if !s.Enabled() {
+		return true
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, s.Seed)
+	h.Write([]byte(filepath.ToSlash(rel)))
+
+	// Use the top 53 bits for a uniform value in [0, 1)
+	return float64(h.Sum64()>>11)/(1<<53) < s.Fraction
+}
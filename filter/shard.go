@@ -0,0 +1,73 @@
+package filter
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Shard selects one deterministic slice of the included files, so
+// several workers can split a huge context between themselves. It
+// implements pflag.Value so it can be parsed directly from "2/8".
+type Shard struct {
+	Index int
+	Count int
+}
+
+func (s *Shard) String() string {
+	if s.Count == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
+
+func (s *Shard) Set(value string) error {
+	index, count, found := strings.Cut(value, "/")
+	if !found {
+		return fmt.Errorf("shard %q must be in the form INDEX/COUNT, like 2/8", value)
+	}
+
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return fmt.Errorf("invalid shard index %q: %w", index, err)
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return fmt.Errorf("invalid shard count %q: %w", count, err)
+	}
+
+	if n < 1 || i < 1 || i > n {
+		return fmt.Errorf("shard %q is out of range: index must be between 1 and the shard count", value)
+	}
+
+	s.Index, s.Count = i, n
+	return nil
+}
+
+func (s *Shard) Type() string {
+	return "index/count"
+}
+
+// Includes reports whether the file at path, relative to root, belongs
+// to this shard. Paths are hashed relative to the root and with forward
+// slashes so every worker agrees on the partition regardless of where
+// or on which platform the tree is checked out.
+func (s *Shard) Includes(root, path string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(filepath.ToSlash(rel)))
+
+	return int(h.Sum64()%uint64(s.Count)) == s.Index-1
+}
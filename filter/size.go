@@ -0,0 +1,58 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits lists the suffixes a FileSize can be given in, largest first
+// so "MB" isn't mistaken for "B"
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GiB", 1 << 30}, {"GB", 1 << 30}, {"G", 1 << 30},
+	{"MiB", 1 << 20}, {"MB", 1 << 20}, {"M", 1 << 20},
+	{"KiB", 1 << 10}, {"KB", 1 << 10}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// FileSize is a size in bytes. It implements pflag.Value so it can be
+// parsed directly from values like "1MB" or "512KiB", where every unit
+// is a power of 1024.
+type FileSize int64
+
+func (s *FileSize) String() string {
+	n := int64(*s)
+
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(unit.suffix, "iB") && n != 0 && n%unit.bytes == 0 {
+			return strconv.FormatInt(n/unit.bytes, 10) + unit.suffix
+		}
+	}
+
+	return strconv.FormatInt(n, 10)
+}
+
+func (s *FileSize) Set(value string) error {
+	number, multiplier := strings.TrimSpace(value), int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(strings.ToUpper(number), strings.ToUpper(unit.suffix)) {
+			number, multiplier = strings.TrimSpace(number[:len(number)-len(unit.suffix)]), unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("size %q must be a positive number of bytes, optionally followed by a unit like KB, MB or GB", value)
+	}
+
+	*s = FileSize(n * float64(multiplier))
+	return nil
+}
+
+func (s *FileSize) Type() string {
+	return "size"
+}
@@ -0,0 +1,127 @@
+package filter
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Modes for the --tests flag
+const (
+	TestsInclude = "include"
+	TestsExclude = "exclude"
+	TestsOnly    = "only"
+)
+
+// testFilePatterns match the names of test files across languages
+var testFilePatterns = []string{
+	"*_test.go",
+	"*.test.js", "*.test.jsx", "*.test.ts", "*.test.tsx", "*.test.mjs", "*.test.cjs",
+	"*.spec.js", "*.spec.jsx", "*.spec.ts", "*.spec.tsx", "*.spec.mjs", "*.spec.cjs",
+	"test_*.py", "*_test.py",
+	"*_test.rb", "*_spec.rb",
+	"*Test.java", "*Tests.java", "*Test.kt", "*Tests.kt",
+	"*Tests.cs", "*Test.cs",
+	"*_test.rs", "*_test.exs", "*_test.dart",
+	"*Test.php", "*Tests.swift",
+}
+
+// testDirNames are directories whose contents are all considered tests
+var testDirNames = []string{"__tests__", "test", "tests", "spec", "specs", "testdata"}
+
+// IsTestPath reports whether the file at rel, relative to the scan root,
+// is a test or part of a test suite
+func IsTestPath(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	parts := strings.Split(rel, "/")
+
+	for _, dir := range parts[:len(parts)-1] {
+		if contains(testDirNames, dir) {
+			return true
+		}
+	}
+
+	name := parts[len(parts)-1]
+	for _, pattern := range testFilePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasTestFile reports whether the file at path has a test file named
+// after it in the same directory, like foo_test.go for foo.go or
+// test_foo.py for foo.py
+func hasTestFile(t tree, path string) bool {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	for _, pattern := range testFilePatterns {
+		prefix, suffix, _ := strings.Cut(pattern, "*")
+		if ext == "" || filepath.Ext(suffix) != ext {
+			continue
+		}
+
+		if info, err := t.stat(filepath.Join(dir, prefix+stem+suffix)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// testIgnorePatterns returns the test conventions as gitignore-style
+// patterns, for presets to build on
+func testIgnorePatterns() []string {
+	patterns := make([]string, 0, len(testFilePatterns)+len(testDirNames))
+	patterns = append(patterns, testFilePatterns...)
+
+	for _, dir := range testDirNames {
+		patterns = append(patterns, dir+"/")
+	}
+
+	return patterns
+}
+
+// TestsMode decides whether test files are included, left out, or the
+// only ones included. It implements pflag.Value so invalid modes are
+// rejected while parsing flags.
+type TestsMode string
+
+func (t *TestsMode) String() string {
+	if *t == "" {
+		return TestsInclude
+	}
+
+	return string(*t)
+}
+
+func (t *TestsMode) Set(value string) error {
+	switch value {
+	case TestsInclude, TestsExclude, TestsOnly:
+		*t = TestsMode(value)
+		return nil
+	}
+
+	return fmt.Errorf("must be one of: %s, %s, %s", TestsInclude, TestsExclude, TestsOnly)
+}
+
+func (t *TestsMode) Type() string {
+	return "mode"
+}
+
+// Keeps reports whether a file at rel, relative to the scan root, passes
+// the tests filter
+func (t TestsMode) Keeps(rel string) bool {
+	switch t {
+	case TestsExclude:
+		return !IsTestPath(rel)
+	case TestsOnly:
+		return IsTestPath(rel)
+	}
+
+	return true
+}
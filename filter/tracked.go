@@ -0,0 +1,51 @@
+package filter
+
+import "path"
+
+// PathSet holds the files a walk is restricted to, like those tracked by
+// git, along with the directories holding them so the walk doesn't enter
+// directories without any. A nil PathSet restricts nothing.
+type PathSet struct {
+	files map[string]struct{}
+	dirs  map[string]struct{}
+}
+
+// NewPathSet returns the set of the given files, relative to the root of
+// a walk and separated by forward slashes
+func NewPathSet(files []string) *PathSet {
+	s := &PathSet{files: make(map[string]struct{}, len(files)), dirs: make(map[string]struct{})}
+
+	for _, f := range files {
+		s.files[f] = struct{}{}
+
+		for dir := path.Dir(f); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if _, found := s.dirs[dir]; found {
+				break
+			}
+
+			s.dirs[dir] = struct{}{}
+		}
+	}
+
+	return s
+}
+
+// hasFile reports whether the file at rel is in the set
+func (s *PathSet) hasFile(rel string) bool {
+	if s == nil {
+		return true
+	}
+
+	_, found := s.files[rel]
+	return found
+}
+
+// hasDir reports whether the directory at rel holds files in the set
+func (s *PathSet) hasDir(rel string) bool {
+	if s == nil {
+		return true
+	}
+
+	_, found := s.dirs[rel]
+	return found
+}
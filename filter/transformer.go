@@ -0,0 +1,16 @@
+package filter
+
+// Transformer rewrites the contents of a file before they're added to a
+// context, like formatting or redacting it. It returns false, and content
+// unchanged, when it doesn't apply to the file at path.
+type Transformer interface {
+	Transform(path string, content []byte) ([]byte, bool)
+}
+
+// TransformerFunc adapts a function to a Transformer
+type TransformerFunc func(path string, content []byte) ([]byte, bool)
+
+// Transform calls f
+func (f TransformerFunc) Transform(path string, content []byte) ([]byte, bool) {
+	return f(path, content)
+}
@@ -0,0 +1,143 @@
+package filter
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// tree reads the files below root, either from disk or, when fsys is
+// set, from a file system standing in for it, like an archive. Paths are
+// always given as they're reported by a walk, joined to root.
+type tree struct {
+	root string
+	fsys fs.FS
+}
+
+// newTree returns the tree the walks with opts read
+func newTree(root string, opts Options) tree {
+	return tree{root: root, fsys: opts.FS}
+}
+
+// name converts path into a name in fsys
+func (t tree) name(path string) (string, error) {
+	rel, err := filepath.Rel(t.root, path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// open opens the file at path
+func (t tree) open(path string) (fs.File, error) {
+	if t.fsys == nil {
+		return os.Open(path)
+	}
+
+	name, err := t.name(path)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+
+	return t.fsys.Open(name)
+}
+
+// stat returns information about the file at path, following links
+func (t tree) stat(path string) (fs.FileInfo, error) {
+	if t.fsys == nil {
+		return os.Stat(path)
+	}
+
+	name, err := t.name(path)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: path, Err: fs.ErrNotExist}
+	}
+
+	return fs.Stat(t.fsys, name)
+}
+
+// lstat returns information about the file at path, without following
+// links
+func (t tree) lstat(path string) (fs.FileInfo, error) {
+	if t.fsys == nil {
+		return os.Lstat(path)
+	}
+
+	// File systems other than the disk have no way to tell links apart
+	return t.stat(path)
+}
+
+// readDir returns the entries of the directory at path, sorted by name
+func (t tree) readDir(path string) ([]fs.DirEntry, error) {
+	if t.fsys == nil {
+		return os.ReadDir(path)
+	}
+
+	name, err := t.name(path)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: path, Err: fs.ErrNotExist}
+	}
+
+	return fs.ReadDir(t.fsys, name)
+}
+
+// walk walks the tree like filepath.WalkDir walks a directory, visiting
+// the entries of every directory in the order given by opts. Entries
+// aren't stat'ed, and directories are only read once fn lets the walk
+// into them, so excluded folders cost a single call to fn.
+func (t tree) walk(opts Options, fn fs.WalkDirFunc) error {
+	info, err := t.lstat(t.root)
+	if err != nil {
+		err = fn(t.root, nil, err)
+	} else {
+		err = t.walkPath(t.root, fs.FileInfoToDirEntry(info), opts, fn)
+	}
+
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+
+	return err
+}
+
+// walkPath walks path, described by entry, and everything below it. Like
+// filepath.WalkDir, fn is called a second time for directories that
+// can't be read, with the error.
+func (t tree) walkPath(path string, entry fs.DirEntry, opts Options, fn fs.WalkDirFunc) error {
+	if err := fn(path, entry, nil); err != nil || !entry.IsDir() {
+		if err == filepath.SkipDir && entry.IsDir() {
+			return nil
+		}
+
+		return err
+	}
+
+	entries, err := t.readDir(path)
+	if err != nil {
+		if err := fn(path, entry, err); err != nil && err != filepath.SkipDir {
+			return err
+		}
+
+		return nil
+	}
+
+	opts.order(entries)
+
+	for _, entry := range entries {
+		// Files skip the rest of their directory returning SkipDir
+		if err := t.walkPath(filepath.Join(path, entry.Name()), entry, opts, fn); err == filepath.SkipDir {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Open opens the file at path, as reported by a walk of root with opts,
+// from wherever the walk reads it
+func (o Options) Open(root, path string) (fs.File, error) {
+	return newTree(root, o).open(path)
+}
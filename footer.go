@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// footerTitle starts the line closing a context with its totals, which
+// --no-summary leaves out
+const footerTitle = "Context total:"
+
+// contextTotals counts what was written to a context
+type contextTotals struct {
+	files int
+	lines int
+	bytes int64
+}
+
+// add counts the file f into the totals
+func (t *contextTotals) add(f contextFile) {
+	t.files++
+	t.lines += countLines(f.Content)
+	t.bytes += int64(len(f.Content))
+}
+
+// tokens estimates the tokens of the context from its bytes as a whole,
+// like dry runs and stats do, so they all agree on the same files
+func (t contextTotals) tokens() int64 {
+	return estimateTokens(t.bytes)
+}
+
+// String formats the totals as they're written in the footer
+func (t contextTotals) String() string {
+	return fmt.Sprintf("%s, %s, %s, ~%s", plural(t.files, "file"), plural(t.lines, "line"), humanBytes(t.bytes), plural(int(t.tokens()), "token"))
+}
+
+// plural formats n followed by noun, adding an "s" unless n is 1
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// writeFooter writes the line closing the context with its totals, so
+// it's clear at a glance whether it fits a model's context window
+func writeFooter(w io.Writer, format string, totals contextTotals) error {
+	if format == formatMarkdown {
+		_, err := fmt.Fprintf(w, "\n**%s** %s\n", footerTitle, totals)
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "%s %s\n", footerTitle, totals)
+	return err
+}
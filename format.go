@@ -0,0 +1,394 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const separator = "--------------------"
+
+// Supported output formats
+const (
+	formatText         = "text"
+	formatPlainCompact = "plain-compact"
+	formatMarkdown     = "markdown"
+	formatJSON         = "json"
+	formatJSONL        = "jsonl"
+	formatHTML         = "html"
+)
+
+// formats lists the supported output formats, in the order they're
+// shown to users
+var formats = []string{formatText, formatPlainCompact, formatMarkdown, formatJSON, formatJSONL, formatHTML}
+
+// documentFormats lists the formats producing a plain document, which
+// front matter, prompts and summaries can be added to
+var documentFormats = []string{formatText, formatPlainCompact, formatMarkdown}
+
+// isDocumentFormat reports whether format is one of documentFormats
+func isDocumentFormat(format string) bool {
+	return format == "" || slices.Contains(documentFormats, format)
+}
+
+// compactLayout lays out the plain-compact format: a single line naming
+// each file and unindented contents, since on large projects the
+// indentation alone takes a noticeable share of the tokens
+var compactLayout = textLayout{
+	Separator:  ptr(""),
+	FileHeader: ptr("== " + pathPlaceholder + " =="),
+	Indent:     ptr(0),
+}
+
+// ptr returns a pointer to v
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// contextHeader describes the context as a whole
+type contextHeader struct {
+	Label string `json:"label,omitempty"`
+	Root  string `json:"root,omitempty"`
+
+	// Expires is when the context should no longer be trusted to match
+	// the code it was generated from
+	Expires *time.Time `json:"expires,omitempty"`
+
+	// Git tells which version of the code the context was generated from
+	Git *gitMetadata `json:"git,omitempty"`
+}
+
+// expired reports whether the context is past its expiry at now
+func (h contextHeader) expired(now time.Time) bool {
+	return h.Expires != nil && now.After(*h.Expires)
+}
+
+// contextFile is a single file in a context, along with its contents
+type contextFile struct {
+	manifestEntry
+	Link string `json:"link,omitempty"`
+
+	// IdenticalTo is the path of the file with the same contents emitted
+	// earlier, with --dedupe; the contents are left out
+	IdenticalTo string `json:"identical_to,omitempty"`
+
+	// SimilarTo is the path of the file nearly the same as this one
+	// emitted earlier, with --collapse-similar, and Similarity their
+	// estimated share of contents in common; the contents are left out
+	SimilarTo  string  `json:"similar_to,omitempty"`
+	Similarity float64 `json:"similarity,omitempty"`
+
+	Metadata *fileMetadata `json:"metadata,omitempty"`
+	Git      *fileGitInfo  `json:"git,omitempty"`
+
+	// Change tells how the file changed between two revisions, in the
+	// contexts of the diff subcommand, and Diff whether the content is a
+	// unified diff rather than the whole file
+	Change string `json:"change,omitempty"`
+	Diff   bool   `json:"diff,omitempty"`
+
+	Content string `json:"content"`
+
+	// sampled is set when Content only holds the start and the end of
+	// the file, with --sample-large-files
+	sampled bool
+}
+
+// fileMetadata describes a file beyond its contents, for prompts that
+// reason about the recency and scale of the code
+type fileMetadata struct {
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Lines    int       `json:"lines"`
+	Language string    `json:"language"`
+}
+
+// headerLines returns the "key: value" lines describing the file, other
+// than its path, shown in the text format header
+func (f contextFile) headerLines() []string {
+	var lines []string
+
+	if f.Change != "" {
+		lines = append(lines, "change: "+f.Change)
+	}
+
+	if f.Link != "" {
+		lines = append(lines, "link: "+f.Link)
+	}
+
+	if f.IdenticalTo != "" {
+		lines = append(lines, "identical to: "+f.IdenticalTo)
+	}
+
+	if f.SimilarTo != "" {
+		lines = append(lines, fmt.Sprintf("similar to: %s (%.0f%% alike)", f.SimilarTo, f.Similarity*100))
+	}
+
+	if m := f.Metadata; m != nil {
+		lines = append(lines,
+			fmt.Sprintf("size: %s (%d bytes)", humanBytes(m.Size), m.Size),
+			"modified: "+m.Modified.Format(time.RFC3339),
+			fmt.Sprintf("lines: %d", m.Lines),
+			"language: "+m.Language,
+		)
+	}
+
+	if f.Git != nil {
+		lines = append(lines, "last commit: "+f.Git.String())
+	}
+
+	return lines
+}
+
+// collapsed reports whether the contents of the file were left out for
+// being the same as, or similar to, those of another file, or for being
+// deleted in a diff
+func (f contextFile) collapsed() bool {
+	return f.IdenticalTo != "" || f.SimilarTo != "" || f.Change == changeDeleted
+}
+
+// fenceLanguage returns the language code blocks holding the contents of
+// the file are marked with
+func (f contextFile) fenceLanguage() string {
+	if f.Diff {
+		return "diff"
+	}
+
+	return markdownLanguage(f.Path)
+}
+
+// jsonContext is the document written by the JSON output format
+type jsonContext struct {
+	contextHeader
+	Files []contextFile `json:"files"`
+}
+
+// contextWriter writes a context in one of the supported output formats
+type contextWriter interface {
+	// writeFile writes a single file and its contents
+	writeFile(f contextFile) error
+
+	// close finishes the context, like writing the closing separator; it
+	// must not be called when the output is going to be resumed later
+	close() error
+}
+
+// newContextWriter returns a writer for the given format, laying out
+// the text format with layout, or the default layout when it's nil
+func newContextWriter(format string, w io.Writer, header contextHeader, layout *textLayout) (contextWriter, error) {
+	switch format {
+	case formatText, "":
+		return &textWriter{w: w, layout: layout}, nil
+	case formatPlainCompact:
+		return &textWriter{w: w, layout: &compactLayout}, nil
+	case formatMarkdown:
+		return &markdownWriter{w: w}, nil
+	case formatJSON:
+		return &jsonWriter{w: w, header: header}, nil
+	case formatJSONL:
+		return &jsonlWriter{w: w}, nil
+	case formatHTML:
+		return &htmlWriter{w: w, header: header}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, must be one of: %s", format, strings.Join(formats, ", "))
+	}
+}
+
+// eachLine calls fn for every line in content, without the line ending,
+// following the same rules as bufio.ScanLines
+func eachLine(content string, fn func(line string)) {
+	for len(content) > 0 {
+		line, rest, _ := strings.Cut(content, "\n")
+		fn(strings.TrimSuffix(line, "\r"))
+		content = rest
+	}
+}
+
+// textWriter writes the default format, where each file is preceded by a
+// header enclosed in separators and its lines are indented
+type textWriter struct {
+	w      io.Writer
+	layout *textLayout
+}
+
+func (t *textWriter) writeFile(f contextFile) error {
+	// Write the header for the file
+	t.layout.writeFileHeader(t.w, f.Path, f.headerLines()...)
+
+	eachLine(f.Content, func(line string) {
+		t.layout.writeLine(t.w, line)
+	})
+
+	return nil
+}
+
+func (t *textWriter) close() error {
+	// Write the closing line of dashes
+	sep := t.layout.separator()
+	if sep == "" {
+		return nil
+	}
+
+	_, err := fmt.Fprintln(t.w, sep)
+	return err
+}
+
+// markdownWriter writes each file as a heading followed by a fenced code
+// block
+type markdownWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (m *markdownWriter) writeFile(f contextFile) error {
+	// Separate files with a blank line
+	if m.count > 0 {
+		fmt.Fprintln(m.w)
+	}
+	m.count++
+
+	// Use a fence longer than any backtick run inside the content
+	fence := markdownFence(f.Content)
+
+	fmt.Fprintf(m.w, "## %s\n\n", f.Path)
+	for _, line := range f.headerLines() {
+		fmt.Fprintf(m.w, "- %s\n", line)
+	}
+	if f.collapsed() {
+		return nil
+	}
+	if len(f.headerLines()) > 0 {
+		fmt.Fprintln(m.w)
+	}
+	fmt.Fprintf(m.w, "%s%s\n", fence, f.fenceLanguage())
+	eachLine(f.Content, func(line string) {
+		fmt.Fprintln(m.w, line)
+	})
+	_, err := fmt.Fprintln(m.w, fence)
+
+	return err
+}
+
+func (m *markdownWriter) close() error {
+	return nil
+}
+
+// markdownFence returns a code fence that can safely enclose content
+func markdownFence(content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+			continue
+		}
+
+		run = 0
+	}
+
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// markdownLanguage returns the info string used to highlight the code
+// block of the file at path
+func markdownLanguage(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+
+	switch name {
+	case "dockerfile":
+		return "dockerfile"
+	case "makefile", "gnumakefile":
+		return "makefile"
+	}
+
+	return strings.TrimPrefix(filepath.Ext(name), ".")
+}
+
+// jsonWriter writes a single JSON document holding the context header
+// and every file. Files are encoded as soon as they're written so the
+// whole context never needs to be held in memory.
+type jsonWriter struct {
+	w      io.Writer
+	header contextHeader
+	count  int
+}
+
+func (j *jsonWriter) begin() error {
+	header, err := json.MarshalIndent(j.header, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Open the document with the header fields, leaving the files array
+	// open so files can be appended as they come
+	fields := strings.TrimSuffix(strings.TrimPrefix(string(header), "{"), "}")
+	if fields = strings.TrimSpace(fields); fields != "" {
+		fields = "\n  " + fields + ","
+	}
+
+	_, err = fmt.Fprintf(j.w, "{%s\n  \"files\": [", fields)
+	return err
+}
+
+func (j *jsonWriter) writeFile(f contextFile) error {
+	if j.count == 0 {
+		if err := j.begin(); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("error encoding file %q: %w", f.Path, err)
+	}
+
+	sep := ","
+	if j.count == 0 {
+		sep = ""
+	}
+	j.count++
+
+	_, err = fmt.Fprintf(j.w, "%s\n    %s", sep, data)
+	return err
+}
+
+func (j *jsonWriter) close() error {
+	// An empty context still needs its header
+	if j.count == 0 {
+		if err := j.begin(); err != nil {
+			return err
+		}
+
+		_, err := fmt.Fprintln(j.w, "]\n}")
+		return err
+	}
+
+	_, err := fmt.Fprintln(j.w, "\n  ]\n}")
+	return err
+}
+
+// jsonlWriter writes every file as a JSON document on its own line, as
+// soon as it's read, so consumers can process files while the walk goes
+// on. There's no header and no closing structure, so the output can be
+// resumed.
+type jsonlWriter struct {
+	w io.Writer
+}
+
+func (j *jsonlWriter) writeFile(f contextFile) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("error encoding file %q: %w", f.Path, err)
+	}
+
+	_, err = fmt.Fprintf(j.w, "%s\n", data)
+	return err
+}
+
+func (j *jsonlWriter) close() error {
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// generatedExclusion is the key files left out for looking generated or
+// minified are counted under
+const generatedExclusion = "generated or minified files (--include-generated)"
+
+const (
+	// generatedSniffSize is how much of a file is checked for looking
+	// generated when its contents aren't read otherwise, like in dry runs
+	generatedSniffSize = 64 * 1024
+
+	// generatedHeaderLines is how many lines at the start of a file are
+	// checked for a generated code marker
+	generatedHeaderLines = 10
+
+	// generatedLineLength is the length past which a line is considered
+	// the output of a minifier rather than written by hand
+	generatedLineLength = 2000
+)
+
+var (
+	// generatedHeader matches the markers code generators leave at the
+	// top of their output, like Go's "Code generated ... DO NOT EDIT."
+	generatedHeader = regexp.MustCompile(`(?i)(code generated .*do not edit|@generated\b|auto-?generated by\b)`)
+
+	// sourceMapReference matches the comment linking compiled JavaScript
+	// and CSS to their sources
+	sourceMapReference = regexp.MustCompile(`[/*]# sourceMappingURL=`)
+
+	// hashedName matches file names carrying a content hash, like the
+	// bundles of web build tools: main.3f2a9c1b.js or app-5d41402a.css
+	hashedName = regexp.MustCompile(`[.-]([0-9a-f]{8,})\.[a-z0-9]+$`)
+)
+
+// detectGenerated returns which heuristic marks the file at path, with
+// the given contents, as likely generated or minified, or an empty string
+// when it looks written by hand
+func detectGenerated(path string, content string) string {
+	name := filepath.Base(path)
+
+	if strings.Contains(name, ".min.") {
+		return "minified file name"
+	}
+
+	if m := hashedName.FindStringSubmatch(name); m != nil && looksLikeHash(m[1]) {
+		return "content hash in file name"
+	}
+
+	for i, line := range strings.SplitN(content, "\n", generatedHeaderLines+1) {
+		if i == generatedHeaderLines {
+			break
+		}
+
+		if generatedHeader.MatchString(line) {
+			return fmt.Sprintf("generated code marker on line %d", i+1)
+		}
+	}
+
+	if sourceMapReference.MatchString(content) {
+		return "source map reference"
+	}
+
+	line := 1
+	for len(content) > 0 {
+		n := strings.IndexByte(content, '\n')
+		if n < 0 {
+			n = len(content)
+		}
+
+		if n > generatedLineLength {
+			return fmt.Sprintf("line %d is %d characters long", line, n)
+		}
+
+		content = content[min(n+1, len(content)):]
+		line++
+	}
+
+	return ""
+}
+
+// looksLikeHash reports whether s, made of hexadecimal digits, mixes
+// digits and letters like hashes do, rather than being a word or a date
+func looksLikeHash(s string) bool {
+	return strings.ContainsAny(s, "0123456789") && strings.ContainsAny(s, "abcdef")
+}
+
+// sniffGenerated checks the start of the file at path, below root, for
+// looking generated, without reading all of it
+func sniffGenerated(root, path string, opts options) (string, error) {
+	file, err := opts.filters.Open(root, path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head, err := io.ReadAll(io.LimitReader(file, generatedSniffSize))
+	if err != nil {
+		return "", fmt.Errorf("error reading file %q: %w", path, err)
+	}
+
+	return detectGenerated(path, string(head)), nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/patrickdappollonio/context-generator/filter"
+)
+
+// gitOutput runs git with args in dir and returns its trimmed output
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+		}
+
+		// Not wrapped, so the exit code of git isn't taken for one of ours
+		return "", fmt.Errorf("git %s: %v", strings.Join(args, " "), err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// gitMetadata identifies the version of the code a context was generated
+// from, which is the first thing to check when a context confuses a model
+type gitMetadata struct {
+	Branch string `json:"branch,omitempty" yaml:"branch,omitempty"`
+	Commit string `json:"commit" yaml:"commit"`
+	Tag    string `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Dirty  bool   `json:"dirty" yaml:"dirty"`
+}
+
+// readGitMetadata describes the checkout of the git repository holding
+// root or, when rev isn't empty, that commit. It returns nil when root
+// isn't in a git repository.
+func readGitMetadata(ctx context.Context, root, rev string) (*gitMetadata, error) {
+	if _, err := gitOutput(ctx, root, "rev-parse", "--show-toplevel"); err != nil {
+		return nil, nil
+	}
+
+	// A commit other than the checkout is never dirty nor on a branch
+	if rev != "" {
+		meta := &gitMetadata{Commit: rev}
+		if tag, err := gitOutput(ctx, root, "describe", "--tags", "--exact-match", rev); err == nil {
+			meta.Tag = tag
+		}
+
+		return meta, nil
+	}
+
+	commit, err := gitOutput(ctx, root, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("repository has no commits: %w", err)
+	}
+
+	status, err := gitOutput(ctx, root, "status", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &gitMetadata{Commit: commit, Dirty: status != ""}
+
+	// A detached HEAD has no branch, and most commits have no tag
+	if branch, err := gitOutput(ctx, root, "rev-parse", "--abbrev-ref", "HEAD"); err == nil && branch != "HEAD" {
+		meta.Branch = branch
+	}
+
+	if tag, err := gitOutput(ctx, root, "describe", "--tags", "--exact-match", "HEAD"); err == nil {
+		meta.Tag = tag
+	}
+
+	return meta, nil
+}
+
+// loadTracked restricts the walk of root to the files git tracks, when
+// --tracked-only is set. Revisions read with --rev only hold tracked
+// files already.
+func (o *options) loadTracked(ctx context.Context, root string) error {
+	if !o.trackedOnly || o.filters.FS != nil || o.filters.Tracked != nil {
+		return nil
+	}
+
+	out, err := gitBytes(ctx, root, "ls-files", "-z")
+	if err != nil {
+		return fmt.Errorf("flag --tracked-only requires a git repository: %w", err)
+	}
+
+	var files []string
+	for _, path := range strings.Split(string(out), "\x00") {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+
+	o.filters.Tracked = filter.NewPathSet(files)
+	return nil
+}
+
+// fileGitInfo tells who last changed a file and when, according to git
+type fileGitInfo struct {
+	Commit string    `json:"commit" yaml:"commit"`
+	Author string    `json:"author" yaml:"author"`
+	Date   time.Time `json:"date" yaml:"date"`
+}
+
+// String formats the info as shown in the text format header
+func (g fileGitInfo) String() string {
+	return fmt.Sprintf("%s (%s, %s)", g.Commit, g.Date.Format(time.DateOnly), g.Author)
+}
+
+// gitHistory holds the last commit changing each file tracked by git
+// below a directory. A nil gitHistory knows about no files.
+type gitHistory struct {
+	root  string
+	files map[string]*fileGitInfo
+}
+
+// readGitHistory finds the last commit changing each file below root in
+// the commit rev, or HEAD when it's empty, reading the log only as far
+// back as needed to find them all
+func readGitHistory(ctx context.Context, root, rev string) (*gitHistory, error) {
+	if _, err := gitOutput(ctx, root, "rev-parse", "--show-toplevel"); err != nil {
+		return nil, fmt.Errorf("directory %q is not in a git repository", root)
+	}
+
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	tracked, err := gitOutput(ctx, root, "-c", "core.quotepath=off", "ls-tree", "-r", "--name-only", rev)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := make(map[string]struct{})
+	for _, path := range strings.Split(tracked, "\n") {
+		if path != "" {
+			remaining[path] = struct{}{}
+		}
+	}
+
+	h := &gitHistory{root: root, files: make(map[string]*fileGitInfo, len(remaining))}
+	if len(remaining) == 0 {
+		return h, nil
+	}
+
+	// Stop git once every file was found, which for most repositories is
+	// long before reaching the first commit
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "-C", root, "-c", "core.quotepath=off", "log",
+		"--format=%x00%H%x09%an%x09%aI", "--name-only", "--no-renames", "--relative", rev, "--")
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error reading git log: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error reading git log: %w", err)
+	}
+
+	var (
+		current *fileGitInfo
+		readErr error
+	)
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && len(remaining) > 0 {
+		line := scanner.Text()
+
+		// Commits start with a NUL byte, followed by the files they changed
+		if commit, found := strings.CutPrefix(line, "\x00"); found {
+			fields := strings.SplitN(commit, "\t", 3)
+			if len(fields) != 3 {
+				readErr = fmt.Errorf("error reading git log: unexpected line %q", commit)
+				break
+			}
+
+			date, _ := time.Parse(time.RFC3339, fields[2])
+			current = &fileGitInfo{Commit: fields[0], Author: fields[1], Date: date}
+			continue
+		}
+
+		if _, found := remaining[line]; found && current != nil {
+			h.files[line] = current
+			delete(remaining, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil && readErr == nil {
+		readErr = fmt.Errorf("error reading git log: %w", err)
+	}
+
+	// Finishing early kills git, which isn't an error. Not wrapped, so
+	// the exit code of git isn't taken for one of ours.
+	cancel()
+	if err := cmd.Wait(); err != nil && len(remaining) > 0 && readErr == nil {
+		readErr = fmt.Errorf("error reading git log: %v", err)
+	}
+
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	return h, nil
+}
+
+// info returns the last commit changing the file at path, or nil when
+// git doesn't track it
+func (h *gitHistory) info(path string) *fileGitInfo {
+	if h == nil {
+		return nil
+	}
+
+	rel, err := filepath.Rel(h.root, path)
+	if err != nil {
+		return nil
+	}
+
+	return h.files[filepath.ToSlash(rel)]
+}
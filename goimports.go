@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// goModFileName is the file declaring a Go module
+const goModFileName = "go.mod"
+
+// goImportGraph finds the Go files reachable from entry points by
+// following the imports between packages of the same module
+type goImportGraph struct {
+	fsys fs.FS
+
+	// pkgPath is the import path of the package at the root of fsys
+	pkgPath string
+
+	files    []string
+	visited  map[string]struct{}
+	enqueued map[string]struct{}
+	queue    []string
+}
+
+// goModulePath reads the module path declared in the go.mod file data
+func goModulePath(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if rest, found := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module"); found && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			path := strings.TrimSpace(rest)
+			if unquoted, err := strconv.Unquote(path); err == nil {
+				return unquoted
+			}
+
+			return path
+		}
+	}
+
+	return ""
+}
+
+// rootPackagePath returns the import path of the package in the directory
+// root, finding the go.mod of its module in root or, on disk, any of its
+// parents
+func rootPackagePath(fsys fs.FS, root string, onDisk bool) (string, error) {
+	if data, err := fs.ReadFile(fsys, goModFileName); err == nil {
+		if mod := goModulePath(data); mod != "" {
+			return mod, nil
+		}
+	}
+
+	if onDisk {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return "", fmt.Errorf("error resolving directory %q: %w", root, err)
+		}
+
+		for dir := filepath.Dir(abs); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			data, err := os.ReadFile(filepath.Join(dir, goModFileName))
+			if err != nil {
+				continue
+			}
+
+			mod := goModulePath(data)
+			if mod == "" {
+				break
+			}
+
+			rel, err := filepath.Rel(dir, abs)
+			if err != nil {
+				break
+			}
+
+			return mod + "/" + filepath.ToSlash(rel), nil
+		}
+	}
+
+	return "", fmt.Errorf("flag --follow-imports requires a Go module, no %s found for %q", goModFileName, root)
+}
+
+// followGoImports returns the Go files reachable from the entries, files
+// or package directories relative to root or import paths of packages
+// below it, along with the go.mod of the module when it's below root.
+// Every file of a reached package is kept, tests included, while only
+// the imports of the non-test files are followed.
+func followGoImports(fsys fs.FS, root string, onDisk bool, entries []string) ([]string, error) {
+	pkgPath, err := rootPackagePath(fsys, root, onDisk)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &goImportGraph{fsys: fsys, pkgPath: pkgPath, visited: make(map[string]struct{}), enqueued: make(map[string]struct{})}
+
+	for _, entry := range entries {
+		if dir, found := g.packageDir(entry); found {
+			g.enqueue(dir)
+			continue
+		}
+
+		rel := path.Clean(filepath.ToSlash(entry))
+		info, err := fs.Stat(fsys, rel)
+		switch {
+		case err != nil || rel == ".." || strings.HasPrefix(rel, "../"):
+			return nil, fmt.Errorf("entry point %q for --follow-imports isn't a Go file or package below %q", entry, root)
+		case info.IsDir():
+			g.enqueue(rel)
+		case strings.HasSuffix(rel, ".go"):
+			// An entry file brings in the packages it imports, but not the
+			// rest of its own package
+			g.files = append(g.files, rel)
+			g.visited[rel] = struct{}{}
+			if err := g.followFile(rel); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("entry point %q for --follow-imports isn't a Go file or package", entry)
+		}
+	}
+
+	for len(g.queue) > 0 {
+		dir := g.queue[0]
+		g.queue = g.queue[1:]
+
+		if err := g.visitPackage(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := fs.Stat(fsys, goModFileName); err == nil {
+		g.files = append(g.files, goModFileName)
+	}
+
+	return g.files, nil
+}
+
+// packageDir returns the directory, relative to the root, of the package
+// with the given import path, reporting whether it's below the root
+func (g *goImportGraph) packageDir(importPath string) (string, bool) {
+	if importPath == g.pkgPath {
+		return ".", true
+	}
+
+	if rel, found := strings.CutPrefix(importPath, g.pkgPath+"/"); found {
+		return rel, true
+	}
+
+	return "", false
+}
+
+// enqueue schedules the package in dir to be visited, once
+func (g *goImportGraph) enqueue(dir string) {
+	if _, found := g.enqueued[dir]; found {
+		return
+	}
+
+	g.enqueued[dir] = struct{}{}
+	g.queue = append(g.queue, dir)
+}
+
+// visitPackage adds the Go files of the package in dir and follows their
+// imports
+func (g *goImportGraph) visitPackage(dir string) error {
+	entries, err := fs.ReadDir(g.fsys, dir)
+	if err != nil {
+		// Imports of missing packages, like generated ones, lead nowhere
+		return nil
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") {
+			continue
+		}
+
+		file := path.Join(dir, e.Name())
+		if _, found := g.visited[file]; found {
+			continue
+		}
+
+		g.visited[file] = struct{}{}
+		g.files = append(g.files, file)
+
+		if strings.HasSuffix(e.Name(), "_test.go") {
+			continue
+		}
+
+		if err := g.followFile(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// followFile schedules the packages of the module imported by file
+func (g *goImportGraph) followFile(file string) error {
+	src, err := fs.ReadFile(g.fsys, file)
+	if err != nil {
+		return fmt.Errorf("error reading file %q: %w", file, err)
+	}
+
+	// Files that don't parse still yield the imports read before the
+	// error, if any
+	parsed, _ := parser.ParseFile(token.NewFileSet(), file, src, parser.ImportsOnly)
+	if parsed == nil {
+		return nil
+	}
+
+	for _, imp := range parsed.Imports {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		if dir, found := g.packageDir(importPath); found {
+			g.enqueue(dir)
+		}
+	}
+
+	return nil
+}
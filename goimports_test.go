@@ -0,0 +1,97 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGoModulePath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"module example.com/app\n\ngo 1.22\n", "example.com/app"},
+		{"// comment\nmodule\texample.com/app\n", "example.com/app"},
+		{"module \"example.com/quoted\"\n", "example.com/quoted"},
+		{"  module example.com/indented  \n", "example.com/indented"},
+		{"modules example.com/app\n", ""},
+		{"module\n", ""},
+		{"go 1.22\n", ""},
+	}
+
+	for _, tt := range tests {
+		if got := goModulePath([]byte(tt.in)); got != tt.want {
+			t.Errorf("goModulePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFollowGoImports(t *testing.T) {
+	fsys := fstest.MapFS{
+		"go.mod":                   {Data: []byte("module example.com/app\n")},
+		"cmd/app/main.go":          {Data: []byte("package main\n\nimport (\n\t\"fmt\"\n\t\"example.com/app/internal/server\"\n)\n")},
+		"cmd/app/main_test.go":     {Data: []byte("package main\n\nimport _ \"example.com/app/internal/testutil\"\n")},
+		"cmd/tool/main.go":         {Data: []byte("package main\n\nimport _ \"example.com/app/internal/unused\"\n")},
+		"internal/server/http.go":  {Data: []byte("package server\n\nimport _ \"example.com/app/internal/store\"\n")},
+		"internal/server/notes.md": {Data: []byte("# notes\n")},
+		"internal/store/store.go":  {Data: []byte("package store\n\nimport _ \"example.com/app/internal/server\"\n")},
+		"internal/store/broken.go": {Data: []byte("package store\n\nimport _ \"example.com/app/internal/gen\"\n\nfunc {")},
+		"internal/testutil/t.go":   {Data: []byte("package testutil\n")},
+		"internal/unused/u.go":     {Data: []byte("package unused\n")},
+	}
+
+	tests := []struct {
+		entries []string
+		want    []string
+	}{
+		{
+			// Test files are kept but their imports aren't followed, and
+			// import cycles and missing packages end the walk
+			entries: []string{"./cmd/app"},
+			want:    []string{"cmd/app/main.go", "cmd/app/main_test.go", "internal/server/http.go", "internal/store/broken.go", "internal/store/store.go", "go.mod"},
+		},
+		{
+			entries: []string{"example.com/app/internal/store"},
+			want:    []string{"internal/store/broken.go", "internal/store/store.go", "internal/server/http.go", "go.mod"},
+		},
+		{
+			// A file brings in what it imports, but not its own package
+			entries: []string{"cmd/app/main_test.go"},
+			want:    []string{"cmd/app/main_test.go", "internal/testutil/t.go", "go.mod"},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := followGoImports(fsys, ".", false, tt.entries)
+		if err != nil {
+			t.Errorf("followGoImports(%q) failed: %v", tt.entries, err)
+			continue
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("followGoImports(%q) = %q, want %q", tt.entries, got, tt.want)
+		}
+	}
+}
+
+func TestFollowGoImportsErrors(t *testing.T) {
+	tests := []struct {
+		fsys    fstest.MapFS
+		entries []string
+		want    string
+	}{
+		{fstest.MapFS{"main.go": {Data: []byte("package main\n")}}, []string{"."}, "requires a Go module"},
+		{fstest.MapFS{"go.mod": {Data: []byte("module example.com/app\n")}}, []string{"missing"}, "isn't a Go file or package below"},
+		{fstest.MapFS{"go.mod": {Data: []byte("module example.com/app\n")}}, []string{"../outside"}, "isn't a Go file or package below"},
+		{fstest.MapFS{"go.mod": {Data: []byte("module example.com/app\n")}}, []string{"go.mod"}, "isn't a Go file or package"},
+	}
+
+	for _, tt := range tests {
+		_, err := followGoImports(tt.fsys, ".", false, tt.entries)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("followGoImports(%q) error = %v, want one containing %q", tt.entries, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// hashPrefix names the algorithm of a context hash, so hashes made some
+// other way in the future can be told apart
+const hashPrefix = "sha256:"
+
+// contextHash hashes the paths and contents of the files of a context as
+// they're written. It leaves out the header, timestamps and format, so
+// the same files always hash the same. A nil contextHash hashes nothing.
+type contextHash struct {
+	h hash.Hash
+}
+
+func newContextHash() *contextHash {
+	return &contextHash{h: sha256.New()}
+}
+
+// add hashes the file f, including what it was collapsed into
+func (c *contextHash) add(f contextFile) {
+	if c == nil {
+		return
+	}
+
+	for _, field := range []string{f.Path, f.IdenticalTo, f.SimilarTo, f.Content} {
+		io.WriteString(c.h, field)
+		c.h.Write([]byte{0})
+	}
+}
+
+// sum returns the hash of the files added so far
+func (c *contextHash) sum() string {
+	return hashPrefix + hex.EncodeToString(c.h.Sum(nil))
+}
+
+// finish prints the hash to w or, when previous is set, compares it with
+// the hash saved in that file, failing when the context changed
+func (c *contextHash) finish(w io.Writer, previous string) error {
+	if c == nil {
+		return nil
+	}
+
+	if previous == "" {
+		_, err := fmt.Fprintln(w, c.sum())
+		return err
+	}
+
+	data, err := os.ReadFile(previous)
+	if err != nil {
+		return fmt.Errorf("error reading hash file %q: %w", previous, err)
+	}
+
+	saved := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(saved, hashPrefix) {
+		return fmt.Errorf("hash file %q doesn't hold a hash printed by --print-hash", previous)
+	}
+
+	if saved != c.sum() {
+		return fmt.Errorf("context changed since %q was saved: it now hashes to %s", previous, c.sum())
+	}
+
+	fmt.Fprintf(w, "context matches the hash in %q\n", previous)
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// hooksConfig is the hooks section of the configuration file: shell
+// commands run in the scanned directory around a run
+type hooksConfig struct {
+	// Pre runs before the directory is scanned, like go generate ./...
+	// or npm run build:types, failing the run if any of them fails
+	Pre []string `yaml:"pre"`
+
+	// Post runs once the context is written, like uploading it, with
+	// where it was written and its totals in environment variables
+	Post []string `yaml:"post"`
+}
+
+// hookResult carries what the post hooks need from a run back to the
+// command running them, once the outputs are closed
+type hookResult struct {
+	post   []string
+	root   string
+	totals contextTotals
+
+	// url is where the context was uploaded to with --upload
+	url string
+}
+
+// runHooks runs each command with the shell in root, with env added to
+// the environment. Their output goes to stderr, since stdout may carry
+// the context.
+func runHooks(ctx context.Context, kind, root string, commands, env []string) error {
+	for _, command := range commands {
+		cmd := shellCommand(ctx, command)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(), env...)
+
+		// Hooks are written for the directory they're configured in
+		if info, err := os.Stat(root); err == nil && info.IsDir() {
+			cmd.Dir = root
+		}
+
+		// Not wrapped, so the exit code of a hook isn't taken for one of
+		// ours, like going over a budget
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s hook %q failed: %v", kind, command, err)
+		}
+	}
+
+	return nil
+}
+
+// runPostHooks runs the post hooks of the run that filled r, telling them
+// where the context was written and how big it is
+func (r *hookResult) runPostHooks(ctx context.Context, opts options) error {
+	if r == nil || len(r.post) == 0 {
+		return nil
+	}
+
+	var output string
+	if len(opts.outputs) > 0 {
+		output = opts.outputs[0]
+	}
+
+	env := []string{
+		"CONTEXT_GENERATOR_ROOT=" + r.root,
+		"CONTEXT_GENERATOR_OUTPUT=" + output,
+		"CONTEXT_GENERATOR_FORMAT=" + opts.format,
+		"CONTEXT_GENERATOR_URL=" + r.url,
+		"CONTEXT_GENERATOR_FILES=" + strconv.Itoa(r.totals.files),
+		"CONTEXT_GENERATOR_LINES=" + strconv.Itoa(r.totals.lines),
+		"CONTEXT_GENERATOR_BYTES=" + strconv.FormatInt(r.totals.bytes, 10),
+		"CONTEXT_GENERATOR_TOKENS=" + strconv.FormatInt(r.totals.tokens(), 10),
+	}
+
+	return runHooks(ctx, "post", r.root, r.post, env)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/patrickdappollonio/context-generator/internal/transform"
+)
+
+// htmlStyle is the stylesheet embedded in the HTML output, so the page
+// can be opened and shared without any other files
+const htmlStyle = `
+body { margin: 0; font-family: system-ui, sans-serif; display: grid; grid-template-columns: 18rem 1fr; }
+nav { grid-column: 1; grid-row: 1; position: sticky; top: 0; height: 100vh; overflow: auto; padding: 1rem; border-right: 1px solid #ddd; background: #fafafa; font-size: 0.9rem; }
+main { grid-column: 2; grid-row: 1; min-width: 0; padding: 1rem 2rem; }
+nav ul { list-style: none; margin: 0; padding-left: 1rem; }
+nav > ul { padding-left: 0; }
+nav summary { cursor: pointer; }
+nav a, h2 a { color: inherit; text-decoration: none; }
+nav a:hover, h2 a:hover { text-decoration: underline; }
+section { margin-bottom: 2rem; }
+h2 { font-size: 1rem; font-family: ui-monospace, monospace; }
+dl { display: grid; grid-template-columns: max-content 1fr; gap: 0 1rem; font-size: 0.85rem; color: #555; }
+dd { margin: 0; }
+pre { padding: 1rem; overflow: auto; background: #f6f8fa; border-radius: 4px; font-size: 0.85rem; }
+.k { color: #a626a4; font-weight: bold; }
+.s { color: #50a14f; }
+.c { color: #a0a1a7; font-style: italic; }
+`
+
+// htmlSpanClasses maps highlighted span kinds to their CSS class
+var htmlSpanClasses = map[transform.SpanKind]string{
+	transform.SpanKeyword: "k",
+	transform.SpanString:  "s",
+	transform.SpanComment: "c",
+}
+
+// htmlWriter writes a self-contained HTML page with a section per file
+// and a sidebar with the file tree. Sections are written as files come,
+// and the sidebar, which needs every path, is written at the end; the
+// stylesheet places it on the left anyway.
+type htmlWriter struct {
+	w      io.Writer
+	header contextHeader
+	paths  []string
+	ids    map[string]string
+}
+
+func (h *htmlWriter) begin() error {
+	title := "Context"
+	if h.header.Label != "" {
+		title = h.header.Label
+	} else if h.header.Root != "" {
+		title = h.header.Root
+	}
+
+	fmt.Fprintf(h.w, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	if h.header.Expires != nil {
+		fmt.Fprintf(h.w, "<meta name=\"expires\" content=\"%s\">\n", h.header.Expires.Format(time.RFC3339))
+	}
+	if g := h.header.Git; g != nil {
+		fmt.Fprintf(h.w, "<meta name=\"git-commit\" content=\"%s\">\n<meta name=\"git-dirty\" content=\"%t\">\n", html.EscapeString(g.Commit), g.Dirty)
+		if g.Branch != "" {
+			fmt.Fprintf(h.w, "<meta name=\"git-branch\" content=\"%s\">\n", html.EscapeString(g.Branch))
+		}
+		if g.Tag != "" {
+			fmt.Fprintf(h.w, "<meta name=\"git-tag\" content=\"%s\">\n", html.EscapeString(g.Tag))
+		}
+	}
+
+	_, err := fmt.Fprintf(h.w, "<style>%s</style>\n</head>\n<body>\n<main>\n", htmlStyle)
+	return err
+}
+
+func (h *htmlWriter) writeFile(f contextFile) error {
+	if h.ids == nil {
+		h.ids = make(map[string]string)
+		if err := h.begin(); err != nil {
+			return err
+		}
+	}
+
+	// Anchors are numbered since paths can hold characters that aren't
+	// valid in identifiers
+	id := fmt.Sprintf("f-%d", len(h.paths)+1)
+	h.paths = append(h.paths, f.Path)
+	h.ids[f.Path] = id
+
+	fmt.Fprintf(h.w, "<section id=\"%s\">\n<h2><a href=\"#%s\">%s</a></h2>\n", id, id, html.EscapeString(f.Path))
+
+	// Show the extra header lines, like the link or metadata, as a list
+	if lines := f.headerLines(); len(lines) > 0 {
+		fmt.Fprintln(h.w, "<dl>")
+		for _, line := range lines {
+			key, value, _ := strings.Cut(line, ": ")
+			fmt.Fprintf(h.w, "<dt>%s</dt><dd>%s</dd>\n", html.EscapeString(key), htmlHeaderValue(value))
+		}
+		fmt.Fprintln(h.w, "</dl>")
+	}
+
+	if !f.collapsed() {
+		fmt.Fprint(h.w, "<pre><code>")
+		writeHighlighted(h.w, f.Path, f.Content)
+		fmt.Fprint(h.w, "</code></pre>\n")
+	}
+	_, err := fmt.Fprint(h.w, "</section>\n")
+
+	return err
+}
+
+func (h *htmlWriter) close() error {
+	// An empty context still needs a page
+	if h.ids == nil {
+		if err := h.begin(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(h.w, "</main>\n<nav>")
+	if len(h.paths) > 0 {
+		top, _ := buildTree(h.header.Root, h.paths)
+		fmt.Fprintln(h.w, "<ul>")
+		h.writeTree(top)
+		fmt.Fprintln(h.w, "</ul>")
+	}
+	_, err := fmt.Fprintln(h.w, "</nav>\n</body>\n</html>")
+
+	return err
+}
+
+// writeTree writes the children of node as nested lists, with
+// directories that can be collapsed and files linking to their section
+func (h *htmlWriter) writeTree(node *treeNode) {
+	for _, c := range node.children {
+		if c.isDir() {
+			fmt.Fprintf(h.w, "<li><details open><summary>%s/</summary>\n<ul>\n", html.EscapeString(c.name))
+			h.writeTree(c)
+			fmt.Fprintln(h.w, "</ul>\n</details></li>")
+			continue
+		}
+
+		fmt.Fprintf(h.w, "<li><a href=\"#%s\">%s</a></li>\n", h.ids[c.path], html.EscapeString(c.name))
+	}
+}
+
+// htmlHeaderValue escapes a header value, turning links into anchors
+func htmlHeaderValue(value string) string {
+	escaped := html.EscapeString(value)
+	if strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "http://") {
+		return fmt.Sprintf("<a href=\"%s\">%s</a>", escaped, escaped)
+	}
+
+	return escaped
+}
+
+// writeHighlighted writes the escaped contents of the file at path,
+// wrapping keywords, strings and comments in spans when the language is
+// supported
+func writeHighlighted(w io.Writer, path, content string) {
+	spans, ok := transform.Highlight(path, []byte(content))
+	if !ok {
+		io.WriteString(w, html.EscapeString(content))
+		return
+	}
+
+	for _, span := range spans {
+		text := html.EscapeString(string(span.Text))
+
+		class, found := htmlSpanClasses[span.Kind]
+		if !found {
+			io.WriteString(w, text)
+			continue
+		}
+
+		fmt.Fprintf(w, "<span class=\"%s\">%s</span>", class, text)
+	}
+}
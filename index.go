@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/patrickdappollonio/context-generator/internal/store"
+)
+
+// indexKey is the key, inside the store, holding the context generated
+// by the last run
+const indexKey = "last-context.json"
+
+// cacheDir returns the directory where context-generator keeps its state
+// between runs
+func cacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding the cache directory: %w", err)
+	}
+
+	return filepath.Join(dir, "context-generator"), nil
+}
+
+// openStore opens the store selected with --store, which keeps state
+// between runs
+func openStore(backend string) (store.Store, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return store.Open(backend, dir)
+}
+
+// readIndex returns the index saved by the last run
+func readIndex(backend string) (io.ReadCloser, error) {
+	st, err := openStore(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := st.Get(indexKey)
+	if err != nil {
+		st.Close()
+
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, fmt.Errorf("there's no context from a previous run to search, generate one first")
+		}
+
+		return nil, err
+	}
+
+	return &storeReader{ReadCloser: r, store: st}, nil
+}
+
+// storeReader reads a value and closes its store once done
+type storeReader struct {
+	io.ReadCloser
+	store store.Store
+}
+
+func (r *storeReader) Close() error {
+	return errors.Join(r.ReadCloser.Close(), r.store.Close())
+}
+
+// contextIndex keeps a JSON copy of the context being generated so it
+// can be searched later. It only replaces the index of the previous run
+// once it's committed. A nil index is valid and records nothing.
+type contextIndex struct {
+	store store.Store
+	spool *spoolFile
+	w     contextWriter
+}
+
+// openIndex starts recording a new index for a context with header in
+// the store of the given backend
+func openIndex(backend string, header contextHeader) (*contextIndex, error) {
+	st, err := openStore(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hold the index in a temporary file until it's committed, so a
+	// failed run never replaces the index of the previous one
+	spool, err := newSpoolFile()
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+
+	return &contextIndex{
+		store: st,
+		spool: spool,
+		w:     &jsonWriter{w: spool, header: header},
+	}, nil
+}
+
+// writeFile records a file in the index
+func (i *contextIndex) writeFile(f contextFile) error {
+	if i == nil {
+		return nil
+	}
+
+	return i.w.writeFile(f)
+}
+
+// commit finishes the index and makes it the one of the last run
+func (i *contextIndex) commit() error {
+	if i == nil {
+		return nil
+	}
+	defer i.discard()
+
+	if err := i.w.close(); err != nil {
+		return fmt.Errorf("error saving index: %w", err)
+	}
+
+	if _, err := i.spool.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error saving index: %w", err)
+	}
+
+	if err := i.store.Put(indexKey, i.spool); err != nil {
+		return fmt.Errorf("error saving index: %w", err)
+	}
+
+	return nil
+}
+
+// discard throws the index away, keeping the one of the previous run
+func (i *contextIndex) discard() {
+	if i == nil {
+		return
+	}
+
+	i.spool.remove()
+	i.store.Close()
+}
@@ -0,0 +1,161 @@
+// Package archive reads zip and tar archives into memory as a file
+// system, so their contents can be walked without extracting them.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// extensions lists the file extensions of the supported archives
+var extensions = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// IsArchive reports whether name has the extension of a supported
+// archive
+func IsArchive(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range extensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Open reads the archive at path, a zip file or a tar file, compressed
+// with gzip or not, into memory. The format is detected from the
+// contents rather than the name. Only regular files and directories are
+// kept; links and other special files are dropped.
+func Open(path string) (fs.FS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening archive %q: %w", path, err)
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(4)
+
+	var fsys *memFS
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		info, err := f.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("error checking archive %q: %w", path, err)
+		}
+
+		fsys, err = readZip(f, info.Size())
+		if err != nil {
+			return nil, fmt.Errorf("error reading zip archive %q: %w", path, err)
+		}
+	case bytes.HasPrefix(magic, []byte("\x1f\x8b")):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("error reading compressed archive %q: %w", path, err)
+		}
+		defer gz.Close()
+
+		if fsys, err = readTar(gz); err != nil {
+			return nil, fmt.Errorf("error reading tar archive %q: %w", path, err)
+		}
+	default:
+		if fsys, err = readTar(br); err != nil {
+			return nil, fmt.Errorf("error reading tar archive %q: %w", path, err)
+		}
+	}
+
+	return fsys, nil
+}
+
+// ReadTar reads the uncompressed tar archive streamed from r into
+// memory, like the output of git archive
+func ReadTar(r io.Reader) (fs.FS, error) {
+	fsys, err := readTar(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading tar archive: %w", err)
+	}
+
+	return fsys, nil
+}
+
+// readZip reads the zip archive in r, which is size bytes long
+func readZip(r io.ReaderAt, size int64) (*memFS, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := newMemFS()
+	for _, zf := range zr.File {
+		info := zf.FileInfo()
+
+		if info.IsDir() {
+			fsys.addDir(zf.Name, info.ModTime())
+			continue
+		}
+
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, fmt.Errorf("error opening %q: %w", zf.Name, err)
+		}
+
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading %q: %w", zf.Name, err)
+		}
+
+		fsys.addFile(zf.Name, data, info.Mode(), info.ModTime())
+	}
+
+	return fsys, nil
+}
+
+// readTar reads the tar archive in r
+func readTar(r io.Reader) (*memFS, error) {
+	fsys := newMemFS()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fsys, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		info := hdr.FileInfo()
+
+		if info.IsDir() {
+			fsys.addDir(hdr.Name, info.ModTime())
+			continue
+		}
+
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %q: %w", hdr.Name, err)
+		}
+
+		fsys.addFile(hdr.Name, data, info.Mode(), info.ModTime())
+	}
+}
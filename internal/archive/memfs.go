@@ -0,0 +1,192 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// memFS is a read-only file system held in memory. Directories are
+// created for every file added, so it can be walked like a disk.
+type memFS struct {
+	entries map[string]*memEntry
+}
+
+// memEntry is a file or directory of a memFS
+type memEntry struct {
+	name     string
+	data     []byte
+	mode     fs.FileMode
+	modTime  time.Time
+	children map[string]*memEntry
+}
+
+func newMemFS() *memFS {
+	root := &memEntry{name: ".", mode: fs.ModeDir | 0o755, children: make(map[string]*memEntry)}
+	return &memFS{entries: map[string]*memEntry{".": root}}
+}
+
+// clean turns the name of an archive entry into a name in the file
+// system, or an empty string for names pointing outside of it
+func clean(name string) string {
+	name = path.Clean("/" + strings.TrimPrefix(name, "./"))[1:]
+	if name == "" {
+		return "."
+	}
+
+	if !fs.ValidPath(name) {
+		return ""
+	}
+
+	return name
+}
+
+// addDir adds the directory at name, along with its parents
+func (m *memFS) addDir(name string, modTime time.Time) *memEntry {
+	name = clean(name)
+	if name == "" {
+		return nil
+	}
+
+	if e, found := m.entries[name]; found {
+		if e.children == nil {
+			return nil
+		}
+
+		if !modTime.IsZero() {
+			e.modTime = modTime
+		}
+
+		return e
+	}
+
+	parent := m.addDir(path.Dir(name), time.Time{})
+	if parent == nil {
+		return nil
+	}
+
+	e := &memEntry{name: name, mode: fs.ModeDir | 0o755, modTime: modTime, children: make(map[string]*memEntry)}
+	parent.children[path.Base(name)] = e
+	m.entries[name] = e
+
+	return e
+}
+
+// addFile adds the file at name, along with its parent directories.
+// Later files replace earlier ones with the same name, like extracting
+// the archive would.
+func (m *memFS) addFile(name string, data []byte, mode fs.FileMode, modTime time.Time) {
+	name = clean(name)
+	if name == "" || name == "." {
+		return
+	}
+
+	parent := m.addDir(path.Dir(name), time.Time{})
+	if parent == nil {
+		return
+	}
+
+	if e, found := m.entries[name]; found && e.children != nil {
+		return
+	}
+
+	e := &memEntry{name: name, data: data, mode: mode.Perm(), modTime: modTime}
+	parent.children[path.Base(name)] = e
+	m.entries[name] = e
+}
+
+// Open implements fs.FS
+func (m *memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	e, found := m.entries[name]
+	if !found {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if e.children != nil {
+		return &memDir{entry: e}, nil
+	}
+
+	return &memFile{entry: e, Reader: bytes.NewReader(e.data)}, nil
+}
+
+// Name implements fs.FileInfo
+func (e *memEntry) Name() string { return path.Base(e.name) }
+
+// Size implements fs.FileInfo
+func (e *memEntry) Size() int64 { return int64(len(e.data)) }
+
+// Mode implements fs.FileInfo
+func (e *memEntry) Mode() fs.FileMode { return e.mode }
+
+// ModTime implements fs.FileInfo
+func (e *memEntry) ModTime() time.Time { return e.modTime }
+
+// IsDir implements fs.FileInfo
+func (e *memEntry) IsDir() bool { return e.children != nil }
+
+// Sys implements fs.FileInfo
+func (e *memEntry) Sys() any { return nil }
+
+// memFile is an open file of a memFS, which can be read again by seeking
+// back to its start
+type memFile struct {
+	entry *memEntry
+	*bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.entry, nil }
+
+func (f *memFile) Close() error { return nil }
+
+// memDir is an open directory of a memFS
+type memDir struct {
+	entry   *memEntry
+	listed  []fs.DirEntry
+	started bool
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.entry, nil }
+
+func (d *memDir) Close() error { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile, listing the entries sorted by name
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.started {
+		d.started = true
+		for _, child := range d.entry.children {
+			d.listed = append(d.listed, fs.FileInfoToDirEntry(child))
+		}
+
+		sort.Slice(d.listed, func(i, j int) bool {
+			return d.listed[i].Name() < d.listed[j].Name()
+		})
+	}
+
+	if n <= 0 {
+		listed := d.listed
+		d.listed = nil
+		return listed, nil
+	}
+
+	if len(d.listed) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(d.listed))
+	listed := d.listed[:n]
+	d.listed = d.listed[n:]
+
+	return listed, nil
+}
@@ -0,0 +1,218 @@
+// Package similar finds files whose contents are nearly the same, like
+// generated stubs or boilerplate, using MinHash signatures indexed by
+// locality-sensitive hashing so each file is only compared against the
+// few files likely to resemble it.
+package similar
+
+import (
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// bands and rows split a signature for locality-sensitive hashing:
+	// files sharing every row of at least one band are compared. With 16
+	// bands of 4 rows, files 90% alike are almost always compared while
+	// files 50% alike rarely are.
+	bands = 16
+	rows  = 4
+
+	// signatureSize is the number of hash functions in a signature
+	signatureSize = bands * rows
+
+	// shingleSize is how many consecutive words make up a shingle
+	shingleSize = 3
+
+	// minShingles is how many shingles a file needs to be compared at
+	// all; smaller files are too short for similarity to mean anything
+	// and cost little to emit
+	minShingles = 20
+
+	// minStemLength is how long a file name must be, without extensions,
+	// to be blanked out of its contents
+	minStemLength = 3
+)
+
+// signature is the MinHash signature of a file: the smallest hash of its
+// shingles under each hash function
+type signature [signatureSize]uint64
+
+// similarity estimates the Jaccard similarity of the files with
+// signatures s and o
+func (s *signature) similarity(o *signature) float64 {
+	same := 0
+	for i := range s {
+		if s[i] == o[i] {
+			same++
+		}
+	}
+
+	return float64(same) / signatureSize
+}
+
+// representative is a file other files are collapsed into
+type representative struct {
+	path string
+	sig  *signature
+}
+
+// Index remembers the files added to it, finding for each new one an
+// earlier file it's similar to. Only files with the same extension are
+// compared. The zero value isn't usable, create one with NewIndex.
+type Index struct {
+	threshold float64
+	buckets   map[bandKey][]*representative
+}
+
+// bandKey identifies a band of a signature, along with the extension of
+// the file it belongs to
+type bandKey struct {
+	ext  string
+	band int
+	hash uint64
+}
+
+// NewIndex returns an index considering files similar when the estimated
+// share of their shingles in common is at least threshold, between 0
+// and 1
+func NewIndex(threshold float64) *Index {
+	return &Index{threshold: threshold, buckets: make(map[bandKey][]*representative)}
+}
+
+// Add adds the file at path with the given contents to the index. It
+// returns the path of an earlier file similar enough to it, along with
+// their estimated similarity, or an empty string when there's none, in
+// which case the file becomes a representative later files can be
+// similar to.
+func (x *Index) Add(path string, content []byte) (string, float64) {
+	sig, ok := minHash(words(path, content))
+	if !ok {
+		return "", 0
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	keys := make([]bandKey, bands)
+	for b := range bands {
+		h := fnv.New64a()
+		for _, v := range sig[b*rows : (b+1)*rows] {
+			var buf [8]byte
+			for i := range buf {
+				buf[i] = byte(v >> (8 * i))
+			}
+			h.Write(buf[:])
+		}
+
+		keys[b] = bandKey{ext: ext, band: b, hash: h.Sum64()}
+	}
+
+	// Pick the most similar of the candidates sharing a band
+	var (
+		best      *representative
+		bestScore float64
+	)
+	for _, key := range keys {
+		for _, r := range x.buckets[key] {
+			if score := sig.similarity(r.sig); score >= x.threshold && score > bestScore {
+				best, bestScore = r, score
+			}
+		}
+	}
+
+	if best != nil {
+		return best.path, bestScore
+	}
+
+	r := &representative{path: path, sig: sig}
+	for _, key := range keys {
+		x.buckets[key] = append(x.buckets[key], r)
+	}
+
+	return "", 0
+}
+
+// words splits content into words. Generated files in a family tend to
+// be named after what they generate, like user.pb.go declaring User, so
+// the name of the file is blanked out of its words to let the family
+// resemble each other.
+func words(path string, content []byte) []string {
+	words := strings.Fields(string(content))
+
+	stem, _, _ := strings.Cut(strings.ToLower(filepath.Base(path)), ".")
+	if len(stem) < minStemLength {
+		return words
+	}
+
+	for i, w := range words {
+		lower := strings.ToLower(w)
+		if !strings.Contains(lower, stem) {
+			continue
+		}
+
+		// Blank out every occurrence, keeping the rest of the word
+		var b strings.Builder
+		for {
+			j := strings.Index(lower, stem)
+			if j < 0 {
+				break
+			}
+
+			b.WriteString(w[:j])
+			b.WriteByte(0)
+			w, lower = w[j+len(stem):], lower[j+len(stem):]
+		}
+		b.WriteString(w)
+
+		words[i] = b.String()
+	}
+
+	return words
+}
+
+// minHash computes the signature of a file from its shingles of words,
+// returning false when it has too few of them
+func minHash(words []string) (*signature, bool) {
+	if len(words)-shingleSize+1 < minShingles {
+		return nil, false
+	}
+
+	sig := &signature{}
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+
+	seen := make(map[uint64]struct{}, len(words))
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		for _, w := range words[i : i+shingleSize] {
+			h.Write([]byte(w))
+			h.Write([]byte{0})
+		}
+
+		base := h.Sum64()
+		if _, found := seen[base]; found {
+			continue
+		}
+		seen[base] = struct{}{}
+
+		// Derive each hash function from the shingle hash by mixing in
+		// its index, which is enough for MinHash to work
+		for j := range sig {
+			if v := mix(base ^ (uint64(j+1) * 0x9e3779b97f4a7c15)); v < sig[j] {
+				sig[j] = v
+			}
+		}
+	}
+
+	return sig, true
+}
+
+// mix scrambles the bits of x, the finalizer of SplitMix64
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileStore saves every value in its own file inside a directory
+type fileStore struct {
+	dir string
+}
+
+func openFile(dir string) (*fileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating state directory: %w", err)
+	}
+
+	return &fileStore{dir: dir}, nil
+}
+
+func (s *fileStore) Get(key string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(s.dir, key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("error opening %q: %w", key, err)
+	}
+
+	return f, nil
+}
+
+func (s *fileStore) Put(key string, r io.Reader) error {
+	path := filepath.Join(s.dir, key)
+
+	// Write to a temporary file first so a failed write never leaves a
+	// half-written value behind
+	tmp, err := os.CreateTemp(s.dir, key+".*")
+	if err != nil {
+		return fmt.Errorf("error creating %q: %w", key, err)
+	}
+
+	_, err = io.Copy(tmp, r)
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error saving %q: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *fileStore) Delete(key string) error {
+	if err := os.Remove(filepath.Join(s.dir, key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting %q: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *fileStore) Keys(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error listing state directory: %w", err)
+	}
+
+	var keys []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) {
+			keys = append(keys, e.Name())
+		}
+	}
+
+	return keys, nil
+}
+
+func (s *fileStore) Close() error {
+	return nil
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteFileName is the name of the database, inside the state
+// directory, used by the SQLite backend
+const sqliteFileName = "state.db"
+
+// sqliteStore saves values as rows of a SQLite database, which survives
+// crashes mid-write and can be shared by concurrent processes
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func openSQLite(dir string) (*sqliteStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating state directory: %w", err)
+	}
+
+	path := filepath.Join(dir, sqliteFileName)
+
+	// Wait for other processes writing to the database instead of failing
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("error opening database %q: %w", path, err)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS state (key TEXT PRIMARY KEY, value BLOB NOT NULL)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error preparing database %q: %w", path, err)
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Get(key string) (io.ReadCloser, error) {
+	var value []byte
+	if err := s.db.QueryRow(`SELECT value FROM state WHERE key = ?`, key).Scan(&value); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("error reading %q: %w", key, err)
+	}
+
+	return io.NopCloser(bytes.NewReader(value)), nil
+}
+
+func (s *sqliteStore) Put(key string, r io.Reader) error {
+	value, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error saving %q: %w", key, err)
+	}
+
+	if _, err := s.db.Exec(`INSERT INTO state (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value); err != nil {
+		return fmt.Errorf("error saving %q: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *sqliteStore) Delete(key string) error {
+	if _, err := s.db.Exec(`DELETE FROM state WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("error deleting %q: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *sqliteStore) Keys(prefix string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT key FROM state WHERE substr(key, 1, ?) = ? ORDER BY key`, len(prefix), prefix)
+	if err != nil {
+		return nil, fmt.Errorf("error listing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("error listing keys: %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,54 @@
+// Package store keeps state between runs, like the index of the last
+// generated context, behind an interface so it can live in plain files
+// or in a database shared by several processes
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Supported backends
+const (
+	BackendFile   = "file"
+	BackendSQLite = "sqlite"
+)
+
+// Backends lists the supported backends, the default one first
+var Backends = []string{BackendFile, BackendSQLite}
+
+// ErrNotFound is returned when nothing is saved under a key
+var ErrNotFound = errors.New("not found")
+
+// Store saves values under keys. Saving a value replaces the previous one
+// atomically, so readers never see a half-written value.
+type Store interface {
+	// Get returns the value saved under key, or ErrNotFound
+	Get(key string) (io.ReadCloser, error)
+
+	// Put saves everything read from r under key
+	Put(key string, r io.Reader) error
+
+	// Delete removes the value saved under key, if any
+	Delete(key string) error
+
+	// Keys lists the keys starting with prefix
+	Keys(prefix string) ([]string, error)
+
+	// Close releases the resources held by the store
+	Close() error
+}
+
+// Open opens the store of the given backend keeping its state in dir
+func Open(backend, dir string) (Store, error) {
+	switch backend {
+	case BackendFile, "":
+		return openFile(dir)
+	case BackendSQLite:
+		return openSQLite(dir)
+	default:
+		return nil, fmt.Errorf("unknown store %q, must be one of: %s", backend, strings.Join(Backends, ", "))
+	}
+}
@@ -0,0 +1,54 @@
+package transform
+
+import (
+	"bytes"
+)
+
+// StripComments removes line and block comments from src, which is the
+// content of the file at path. Lines left empty by the removal are
+// dropped entirely. It returns false, and src unchanged, when the
+// language of the file isn't supported.
+func StripComments(path string, src []byte) ([]byte, bool) {
+	syn, found := lookupSyntax(path)
+	if !found {
+		return src, false
+	}
+
+	// Replace every comment with the newlines it contained so the
+	// stripped code still lines up with the original, line by line
+	var stripped bytes.Buffer
+	stripped.Grow(len(src))
+
+	for _, tok := range tokenize(syn, src) {
+		if tok.kind != tokenComment {
+			stripped.Write(tok.text)
+			continue
+		}
+
+		stripped.Write(bytes.Repeat([]byte("\n"), bytes.Count(tok.text, []byte("\n"))))
+	}
+
+	original := bytes.Split(src, []byte("\n"))
+	lines := bytes.Split(stripped.Bytes(), []byte("\n"))
+
+	out := make([]byte, 0, stripped.Len())
+	for i, line := range lines {
+		// Lines that were changed shouldn't keep the whitespace that
+		// used to separate the code from the comment
+		if !bytes.Equal(line, original[i]) {
+			line = bytes.TrimRight(line, " \t\r")
+
+			// Lines that only held a comment go away completely
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+		}
+
+		out = append(out, line...)
+		if i < len(lines)-1 {
+			out = append(out, '\n')
+		}
+	}
+
+	return out, true
+}
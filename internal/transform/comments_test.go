@@ -0,0 +1,66 @@
+package transform
+
+import "testing"
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		path string
+		src  string
+		want string
+	}{
+		{
+			path: "main.go",
+			src:  "package main\n\n// doc\nfunc main() {} // trailing\n",
+			want: "package main\n\nfunc main() {}\n",
+		},
+		{
+			path: "main.go",
+			src:  "a := 1 /* x\ny */ + 2\n",
+			want: "a := 1\n + 2\n",
+		},
+		{
+			path: "main.go",
+			src:  "s := \"// keep\"\n",
+			want: "s := \"// keep\"\n",
+		},
+		{
+			path: "main.go",
+			src:  "/* unterminated\nfunc main() {}\n",
+			want: "",
+		},
+		{
+			path: "lib.rs",
+			src:  "/* a /* b */ c */\nfn main() {}\n",
+			want: "fn main() {}\n",
+		},
+		{
+			path: "app.py",
+			src:  "#!/usr/bin/env python\n# c\nx = \"# no\"  # c\n",
+			want: "#!/usr/bin/env python\nx = \"# no\"\n",
+		},
+		{
+			path: "config.yaml",
+			src:  "a: 1 # c\nb: \"# no\"\nc: it's\n",
+			want: "a: 1\nb: \"# no\"\nc: it's\n",
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := StripComments(tt.path, []byte(tt.src))
+		if !ok {
+			t.Errorf("StripComments(%q, %q) didn't support the file", tt.path, tt.src)
+			continue
+		}
+
+		if string(got) != tt.want {
+			t.Errorf("StripComments(%q, %q) = %q, want %q", tt.path, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestStripCommentsUnsupported(t *testing.T) {
+	src := []byte("# not a comment\n")
+	if got, ok := StripComments("notes.txt", src); ok || string(got) != string(src) {
+		t.Errorf("StripComments(%q) = %q, %v, want the file unchanged", "notes.txt", got, ok)
+	}
+}
@@ -0,0 +1,87 @@
+package transform
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// Compact collapses runs of blank lines into a single one and trims
+// trailing whitespace from src, which is the content of the file at path.
+// Multi-line strings in supported languages are left untouched, and so is
+// trailing whitespace in Markdown, where two spaces mark a line break.
+func Compact(path string, src []byte) []byte {
+	protected := stringNewlines(path, src)
+	trim := !isMarkdown(path)
+
+	lines := bytes.Split(src, []byte("\n"))
+	out := make([]byte, 0, len(src))
+
+	offset, blank := 0, false
+	for i, line := range lines {
+		// Find out whether the line starts, or ends, within a string
+		startsInString := i > 0 && protected[offset-1]
+		endsInString := protected[offset+len(line)]
+		offset += len(line) + 1
+
+		if !startsInString {
+			isBlank := len(bytes.TrimSpace(line)) == 0
+
+			// Drop every blank line following another one
+			if isBlank && blank {
+				continue
+			}
+
+			blank = isBlank
+		}
+
+		if trim && !endsInString {
+			line = bytes.TrimRight(line, " \t\r")
+		}
+
+		out = append(out, line...)
+		if i < len(lines)-1 {
+			out = append(out, '\n')
+		}
+	}
+
+	return out
+}
+
+// newlineSet holds the offsets of newlines that are part of a string
+type newlineSet map[int]bool
+
+// stringNewlines finds the newlines in src that belong to multi-line
+// strings, which must be preserved as-is
+func stringNewlines(path string, src []byte) newlineSet {
+	set := make(newlineSet)
+
+	syn, found := lookupSyntax(path)
+	if !found {
+		return set
+	}
+
+	offset := 0
+	for _, tok := range tokenize(syn, src) {
+		if tok.kind == tokenString {
+			for i, c := range tok.text {
+				if c == '\n' {
+					set[offset+i] = true
+				}
+			}
+		}
+
+		offset += len(tok.text)
+	}
+
+	return set
+}
+
+func isMarkdown(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".mdx":
+		return true
+	}
+
+	return false
+}
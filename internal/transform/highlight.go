@@ -0,0 +1,138 @@
+package transform
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// SpanKind classifies a span of source code for syntax highlighting
+type SpanKind int
+
+const (
+	SpanCode SpanKind = iota
+	SpanKeyword
+	SpanString
+	SpanComment
+)
+
+// Span is a piece of source code of a single kind
+type Span struct {
+	Kind SpanKind
+	Text []byte
+}
+
+var (
+	goKeywords = words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota")
+
+	jsKeywords = words("async await break case catch class const continue debugger default delete do else export extends false finally for from function if import in instanceof interface let new null of return static super switch this throw true try type typeof undefined var void while with yield enum implements private protected public readonly")
+
+	cKeywords = words("auto break case char class const continue default do double else enum extern false final float for goto if inline int long namespace new null nullptr private protected public return short signed sizeof static struct switch template this throw true try typedef union unsigned using virtual void volatile while")
+
+	javaKeywords = words("abstract boolean break byte case catch char class const continue default do double else enum extends final finally float for if implements import instanceof int interface long native new null package private protected public return short static super switch synchronized this throw throws transient true false try var void volatile while")
+
+	rustKeywords = words("as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while")
+
+	pythonKeywords = words("and as assert async await break class continue def del elif else except False finally for from global if import in is lambda None nonlocal not or pass raise return True try while with yield")
+
+	shellKeywords = words("case do done elif else esac export fi for function if in local readonly return select then until while")
+)
+
+// keywordsByExtension maps lowercase file extensions to the keywords of
+// their language
+var keywordsByExtension = map[string]map[string]bool{
+	".go":    goKeywords,
+	".js":    jsKeywords,
+	".jsx":   jsKeywords,
+	".mjs":   jsKeywords,
+	".cjs":   jsKeywords,
+	".ts":    jsKeywords,
+	".tsx":   jsKeywords,
+	".mts":   jsKeywords,
+	".cts":   jsKeywords,
+	".c":     cKeywords,
+	".h":     cKeywords,
+	".cc":    cKeywords,
+	".cpp":   cKeywords,
+	".cxx":   cKeywords,
+	".hpp":   cKeywords,
+	".hh":    cKeywords,
+	".cs":    javaKeywords,
+	".java":  javaKeywords,
+	".kt":    javaKeywords,
+	".kts":   javaKeywords,
+	".scala": javaKeywords,
+	".rs":    rustKeywords,
+	".py":    pythonKeywords,
+	".pyi":   pythonKeywords,
+	".sh":    shellKeywords,
+	".bash":  shellKeywords,
+	".zsh":   shellKeywords,
+}
+
+func words(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		set[w] = true
+	}
+
+	return set
+}
+
+// Highlight splits src, the content of the file at path, into spans of
+// code, keywords, strings and comments. It returns false when the
+// language of the file isn't supported.
+func Highlight(path string, src []byte) ([]Span, bool) {
+	syn, found := lookupSyntax(path)
+	if !found {
+		return nil, false
+	}
+
+	keywords := keywordsByExtension[strings.ToLower(filepath.Ext(path))]
+
+	var spans []Span
+	for _, tok := range tokenize(syn, src) {
+		switch tok.kind {
+		case tokenString:
+			spans = append(spans, Span{Kind: SpanString, Text: tok.text})
+		case tokenComment:
+			spans = append(spans, Span{Kind: SpanComment, Text: tok.text})
+		default:
+			spans = appendCodeSpans(spans, tok.text, keywords)
+		}
+	}
+
+	return spans, true
+}
+
+// appendCodeSpans splits code into keywords and everything else
+func appendCodeSpans(spans []Span, code []byte, keywords map[string]bool) []Span {
+	start := 0
+	for i := 0; i < len(code); {
+		if !isWordByte(code[i]) {
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(code) && isWordByte(code[j]) {
+			j++
+		}
+
+		if keywords[string(code[i:j])] {
+			if start < i {
+				spans = append(spans, Span{Kind: SpanCode, Text: code[start:i]})
+			}
+
+			spans = append(spans, Span{Kind: SpanKeyword, Text: code[i:j]})
+			start = j
+		}
+
+		i = j
+	}
+
+	if start < len(code) {
+		spans = append(spans, Span{Kind: SpanCode, Text: code[start:]})
+	}
+
+	return spans
+}
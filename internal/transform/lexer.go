@@ -0,0 +1,319 @@
+package transform
+
+import (
+	"bytes"
+	"strings"
+)
+
+// tokenKind identifies what a span of source code is
+type tokenKind int
+
+const (
+	tokenCode tokenKind = iota
+	tokenString
+	tokenComment
+)
+
+// token is a span of source code of a single kind
+type token struct {
+	kind tokenKind
+	text []byte
+}
+
+// maxCharLiteral is the longest span, quotes included, that a single
+// quoted character literal like 'é' or '\x7f' can take
+const maxCharLiteral = 12
+
+// regexPrecedents are the characters after which a slash starts a
+// regular expression literal rather than a division
+const regexPrecedents = "(,=:[!&|?{};+-*%<>~^"
+
+// lexer splits source code into code, strings and comments. It's not a
+// full tokenizer: it only knows enough of each language to never mistake
+// a comment delimiter inside a string for a real comment and vice versa.
+type lexer struct {
+	syn    *syntax
+	src    []byte
+	pos    int
+	tokens []token
+	start  int
+}
+
+// tokenize splits src into tokens according to syn. Adjacent code
+// characters are merged into a single token.
+func tokenize(syn *syntax, src []byte) []token {
+	l := &lexer{syn: syn, src: src}
+
+	if syn.preserveShebang && bytes.HasPrefix(src, []byte("#!")) {
+		l.pos = l.lineEnd(0)
+	}
+
+	for l.pos < len(l.src) {
+		l.next()
+	}
+
+	l.flush()
+	return l.tokens
+}
+
+// next consumes the token starting at the current position
+func (l *lexer) next() {
+	// Block comments take precedence since "/*" also starts with "/"
+	for _, bc := range l.syn.blockComments {
+		if l.hasPrefix(bc[0]) {
+			l.emit(tokenComment, l.blockEnd(bc[0], bc[1]))
+			return
+		}
+	}
+
+	for _, lc := range l.syn.lineComments {
+		if l.hasPrefix(lc) && (!l.syn.wordComments || l.atWordStart()) {
+			l.emit(tokenComment, l.lineEnd(l.pos))
+			return
+		}
+	}
+
+	c := l.src[l.pos]
+
+	if l.syn.tripleQuotes && (l.hasPrefix(`"""`) || l.hasPrefix(`'''`)) {
+		l.emit(tokenString, l.tripleEnd(l.src[l.pos:l.pos+3]))
+		return
+	}
+
+	if strings.IndexByte(l.syn.quotes, c) >= 0 && (!l.syn.wordQuotes || l.atTokenStart()) {
+		if c == '\'' && l.syn.charLiterals {
+			if end, ok := l.charEnd(); ok {
+				l.emit(tokenString, end)
+				return
+			}
+		} else {
+			l.emit(tokenString, l.stringEnd(c))
+			return
+		}
+	}
+
+	if strings.IndexByte(l.syn.rawQuotes, c) >= 0 && (!l.syn.wordQuotes || l.atTokenStart()) {
+		l.emit(tokenString, l.rawEnd(c))
+		return
+	}
+
+	if c == '/' && l.syn.regexLiterals && l.regexAllowed() {
+		if end, ok := l.regexEnd(); ok {
+			l.emit(tokenString, end)
+			return
+		}
+	}
+
+	// Anything else is plain code
+	l.pos++
+}
+
+// emit records the pending code, if any, followed by a token of kind
+// spanning from the current position to end
+func (l *lexer) emit(kind tokenKind, end int) {
+	l.flush()
+	l.tokens = append(l.tokens, token{kind: kind, text: l.src[l.pos:end]})
+	l.pos, l.start = end, end
+}
+
+// flush records the code consumed since the last token
+func (l *lexer) flush() {
+	if l.start < l.pos {
+		l.tokens = append(l.tokens, token{kind: tokenCode, text: l.src[l.start:l.pos]})
+	}
+
+	l.start = l.pos
+}
+
+func (l *lexer) hasPrefix(s string) bool {
+	return bytes.HasPrefix(l.src[l.pos:], []byte(s))
+}
+
+// lineEnd returns the position of the newline ending the line at pos,
+// or the end of the source
+func (l *lexer) lineEnd(pos int) int {
+	if i := bytes.IndexByte(l.src[pos:], '\n'); i >= 0 {
+		return pos + i
+	}
+
+	return len(l.src)
+}
+
+// blockEnd returns the position right after the block comment starting
+// at the current position, honoring nesting if the language allows it
+func (l *lexer) blockEnd(open, close string) int {
+	depth := 0
+	for i := l.pos; i < len(l.src); {
+		switch {
+		case bytes.HasPrefix(l.src[i:], []byte(open)) && (depth == 0 || l.syn.nestedBlocks):
+			depth++
+			i += len(open)
+		case bytes.HasPrefix(l.src[i:], []byte(close)):
+			depth--
+			i += len(close)
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+
+	return len(l.src)
+}
+
+// stringEnd returns the position right after the string opened by quote
+// at the current position. Strings end at the first unescaped closing
+// quote; only template literals and strings in shell-like languages
+// may span multiple lines.
+func (l *lexer) stringEnd(quote byte) int {
+	multiline := quote == '`' || l.syn.wordComments
+
+	for i := l.pos + 1; i < len(l.src); i++ {
+		switch l.src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			if !multiline {
+				return i
+			}
+		}
+	}
+
+	return len(l.src)
+}
+
+// rawEnd returns the position right after the raw string opened by quote
+// at the current position. Doubled quotes, as used in YAML, don't end it.
+func (l *lexer) rawEnd(quote byte) int {
+	for i := l.pos + 1; i < len(l.src); i++ {
+		if l.src[i] != quote {
+			continue
+		}
+
+		if l.syn.wordQuotes && i+1 < len(l.src) && l.src[i+1] == quote {
+			i++
+			continue
+		}
+
+		return i + 1
+	}
+
+	return len(l.src)
+}
+
+// tripleEnd returns the position right after the triple-quoted string
+// opened by delim at the current position
+func (l *lexer) tripleEnd(delim []byte) int {
+	for i := l.pos + len(delim); i < len(l.src); i++ {
+		if l.src[i] == '\\' {
+			i++
+			continue
+		}
+
+		if bytes.HasPrefix(l.src[i:], delim) {
+			return i + len(delim)
+		}
+	}
+
+	return len(l.src)
+}
+
+// charEnd returns the position right after the character literal at the
+// current position, if the single quote actually opens one
+func (l *lexer) charEnd() (int, bool) {
+	for i := l.pos + 1; i < len(l.src) && i-l.pos < maxCharLiteral; i++ {
+		switch l.src[i] {
+		case '\\':
+			i++
+		case '\'':
+			return i + 1, i > l.pos+1
+		case '\n':
+			return 0, false
+		}
+	}
+
+	return 0, false
+}
+
+// regexEnd returns the position right after the regular expression
+// literal at the current position, if there's one on this line
+func (l *lexer) regexEnd() (int, bool) {
+	inClass := false
+	for i := l.pos + 1; i < len(l.src); i++ {
+		switch l.src[i] {
+		case '\\':
+			i++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if !inClass {
+				return i + 1, true
+			}
+		case '\n':
+			return 0, false
+		}
+	}
+
+	return 0, false
+}
+
+// regexAllowed reports whether a slash at the current position can start
+// a regular expression literal, judging by the code that precedes it
+func (l *lexer) regexAllowed() bool {
+	prev := bytes.TrimRight(l.src[:l.pos], " \t\r\n")
+	if len(prev) == 0 {
+		return true
+	}
+
+	if strings.IndexByte(regexPrecedents, prev[len(prev)-1]) >= 0 {
+		return true
+	}
+
+	for _, kw := range []string{"return", "typeof", "case", "in", "of"} {
+		if bytes.HasSuffix(prev, []byte(kw)) {
+			before := prev[:len(prev)-len(kw)]
+			if len(before) == 0 || !isWordByte(before[len(before)-1]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// atWordStart reports whether the current position starts a new word
+func (l *lexer) atWordStart() bool {
+	return l.pos == 0 || isSpace(l.src[l.pos-1])
+}
+
+// atTokenStart reports whether the current position starts a new YAML
+// token, where a quote would open a quoted scalar rather than being part
+// of a plain one
+func (l *lexer) atTokenStart() bool {
+	for i := l.pos - 1; i >= 0; i-- {
+		c := l.src[i]
+		switch {
+		case c == '\n':
+			return true
+		case isSpace(c):
+			continue
+		default:
+			return strings.IndexByte(":-[{,?", c) >= 0
+		}
+	}
+
+	return true
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
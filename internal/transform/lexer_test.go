@@ -0,0 +1,142 @@
+package transform
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// describeTokens renders tokens as "kind:text" strings to compare them
+func describeTokens(tokens []token) []string {
+	names := map[tokenKind]string{tokenCode: "code", tokenString: "string", tokenComment: "comment"}
+
+	out := make([]string, len(tokens))
+	for i, tok := range tokens {
+		out[i] = fmt.Sprintf("%s:%s", names[tok.kind], tok.text)
+	}
+
+	return out
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		src  string
+		want []string
+	}{
+		{
+			name: "comment delimiter in a string",
+			path: "main.go",
+			src:  `a := "// no" // c`,
+			want: []string{`code:a := `, `string:"// no"`, `code: `, `comment:// c`},
+		},
+		{
+			name: "escaped quote",
+			path: "main.go",
+			src:  `a := "x\"/*" // c`,
+			want: []string{`code:a := `, `string:"x\"/*"`, `code: `, `comment:// c`},
+		},
+		{
+			name: "raw string",
+			path: "main.go",
+			src:  "a := `/* no */`",
+			want: []string{`code:a := `, "string:`/* no */`"},
+		},
+		{
+			name: "character literal holding a quote",
+			path: "main.go",
+			src:  `c := '"' // c`,
+			want: []string{`code:c := `, `string:'"'`, `code: `, `comment:// c`},
+		},
+		{
+			name: "unterminated block comment",
+			path: "main.go",
+			src:  "a /* open\nb",
+			want: []string{`code:a `, "comment:/* open\nb"},
+		},
+		{
+			name: "block comments don't nest in C",
+			path: "main.c",
+			src:  "/* a /* b */ c */",
+			want: []string{`comment:/* a /* b */`, `code: c */`},
+		},
+		{
+			name: "block comments nest in Rust",
+			path: "main.rs",
+			src:  "/* a /* b */ c */ x",
+			want: []string{`comment:/* a /* b */ c */`, `code: x`},
+		},
+		{
+			name: "regular expression literal",
+			path: "app.js",
+			src:  `x = /\/\/ no/; // c`,
+			want: []string{`code:x = `, `string:/\/\/ no/`, `code:; `, `comment:// c`},
+		},
+		{
+			name: "regular expression after a keyword",
+			path: "app.js",
+			src:  `return /[/]/.test(x)`,
+			want: []string{`code:return `, `string:/[/]/`, `code:.test(x)`},
+		},
+		{
+			name: "division",
+			path: "app.js",
+			src:  `a / b // c`,
+			want: []string{`code:a / b `, `comment:// c`},
+		},
+		{
+			name: "template literal spanning lines",
+			path: "app.ts",
+			src:  "`a\n// b`",
+			want: []string{"string:`a\n// b`"},
+		},
+		{
+			name: "unterminated string ends with its line",
+			path: "app.js",
+			src:  "\"abc\n// c",
+			want: []string{`string:"abc`, "code:\n", `comment:// c`},
+		},
+		{
+			name: "shebang",
+			path: "app.js",
+			src:  "#!/usr/bin/env node\n// c",
+			want: []string{"code:#!/usr/bin/env node\n", `comment:// c`},
+		},
+		{
+			name: "triple-quoted string",
+			path: "app.py",
+			src:  `"""# no""" # c`,
+			want: []string{`string:"""# no"""`, `code: `, `comment:# c`},
+		},
+		{
+			name: "hash inside a word",
+			path: "run.sh",
+			src:  `echo a#b # c`,
+			want: []string{`code:echo a#b `, `comment:# c`},
+		},
+		{
+			name: "apostrophe in a plain scalar",
+			path: "config.yaml",
+			src:  `a: it's # c`,
+			want: []string{`code:a: it's `, `comment:# c`},
+		},
+		{
+			name: "doubled quotes in a quoted scalar",
+			path: "config.yml",
+			src:  `a: 'it''s # no' # c`,
+			want: []string{`code:a: `, `string:'it''s # no'`, `code: `, `comment:# c`},
+		},
+	}
+
+	for _, tt := range tests {
+		syn, found := lookupSyntax(tt.path)
+		if !found {
+			t.Fatalf("%s: no syntax for %q", tt.name, tt.path)
+		}
+
+		if got := describeTokens(tokenize(syn, []byte(tt.src))); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: tokenize(%q) = %q, want %q", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
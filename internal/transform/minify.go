@@ -0,0 +1,127 @@
+package transform
+
+import (
+	"bytes"
+)
+
+// minifiable lists the syntaxes Minify supports: languages where runs of
+// whitespace outside strings and indentation, as long as it's consistent,
+// carry no meaning beyond structure
+var minifiable = map[*syntax]bool{
+	goSyntax:     true,
+	jsSyntax:     true,
+	pythonSyntax: true,
+}
+
+// Minify removes the whitespace in src, which is the content of the file
+// at path, that a reader doesn't need: blank lines, trailing whitespace
+// and runs of spaces within a line. Indentation is rewritten as one tab
+// per level. Unlike StripComments, comments and strings are kept as-is.
+// It returns false, and src unchanged, when the language of the file
+// isn't supported.
+func Minify(path string, src []byte) ([]byte, bool) {
+	syn, found := lookupSyntax(path)
+	if !found || !minifiable[syn] {
+		return src, false
+	}
+
+	m := &minifier{unit: indentUnit(src), lineStart: true}
+	m.out.Grow(len(src))
+
+	for _, tok := range tokenize(syn, src) {
+		if tok.kind != tokenCode {
+			m.content(tok.text)
+			continue
+		}
+
+		for _, c := range tok.text {
+			m.code(c)
+		}
+	}
+
+	return m.out.Bytes(), true
+}
+
+// minifier writes code while dropping the whitespace it doesn't need.
+// Whitespace is held back until it's known whether content follows it
+// on the same line.
+type minifier struct {
+	out bytes.Buffer
+
+	// unit is how many spaces make up one level of indentation
+	unit int
+
+	// lineStart is set until the current line has any content
+	lineStart bool
+
+	// indent is the width of the indentation seen so far on the line
+	indent int
+
+	// space is set when whitespace was seen since the last content
+	space bool
+}
+
+// code writes a single byte of code
+func (m *minifier) code(c byte) {
+	switch c {
+	case '\n':
+		// Blank lines are dropped along with any trailing whitespace
+		if !m.lineStart {
+			m.out.WriteByte('\n')
+		}
+
+		m.lineStart, m.indent, m.space = true, 0, false
+	case ' ', '\r':
+		if m.lineStart && c == ' ' {
+			m.indent++
+		} else if !m.lineStart {
+			m.space = true
+		}
+	case '\t':
+		if m.lineStart {
+			m.indent += m.unit
+		} else {
+			m.space = true
+		}
+	default:
+		m.content([]byte{c})
+	}
+}
+
+// content writes text that must be kept, preceded by the indentation or
+// the single space standing for the whitespace held back
+func (m *minifier) content(text []byte) {
+	if m.lineStart {
+		m.out.Write(bytes.Repeat([]byte("\t"), (m.indent+m.unit-1)/m.unit))
+		m.lineStart = false
+	} else if m.space {
+		m.out.WriteByte(' ')
+	}
+	m.space = false
+
+	m.out.Write(text)
+
+	// Multi-line strings and comments end mid-line
+	if text[len(text)-1] == '\n' {
+		m.lineStart, m.indent = true, 0
+	}
+}
+
+// indentUnit guesses how many spaces make up one level of indentation in
+// src, from the shallowest line indented with spaces
+func indentUnit(src []byte) int {
+	unit := 0
+
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		n := len(line) - len(bytes.TrimLeft(line, " "))
+		if n == 0 || n == len(line) || len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		if unit == 0 || n < unit {
+			unit = n
+		}
+	}
+
+	return max(unit, 1)
+}
@@ -0,0 +1,88 @@
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TruncateRows keeps the header and the first rows of data in src, which
+// is the content of the file at path, ending it with a marker telling
+// how many more rows there were. CSV and TSV files keep their header
+// line on top of the rows, and records spanning several lines, with
+// newlines in quoted fields, count as a single row. JSON Lines files
+// have no header. It returns false, and src unchanged, for other files
+// and for those with no more than rows rows.
+func TruncateRows(path string, src []byte, rows int) ([]byte, bool) {
+	var header, quoted bool
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".tsv":
+		header, quoted = true, true
+	case ".jsonl", ".ndjson":
+	default:
+		return src, false
+	}
+
+	// Find where each record ends, counting the header as one
+	keep := rows
+	if header {
+		keep++
+	}
+
+	var (
+		records  int
+		cut      = -1
+		inQuotes bool
+		start    int
+	)
+	for i, c := range src {
+		switch {
+		case quoted && c == '"':
+			inQuotes = !inQuotes
+		case c == '\n' && !inQuotes:
+			if len(bytes.TrimSpace(src[start:i])) > 0 {
+				records++
+			}
+			start = i + 1
+
+			if records == keep && cut < 0 {
+				cut = i + 1
+			}
+		}
+	}
+
+	// The last record may not end with a newline
+	if len(bytes.TrimSpace(src[start:])) > 0 {
+		records++
+	}
+
+	if cut < 0 || records <= keep {
+		return src, false
+	}
+
+	out := append(make([]byte, 0, cut+32), src[:cut]...)
+	out = fmt.Appendf(out, "… (%s more %s)\n", groupThousands(records-keep), plural(records-keep, "row"))
+	return out, true
+}
+
+// groupThousands formats n with commas between groups of three digits,
+// like 12,340
+func groupThousands(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+
+	return s
+}
+
+// plural returns noun for a count of one, and its plural otherwise
+func plural(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+
+	return noun + "s"
+}
@@ -0,0 +1,122 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateRows(t *testing.T) {
+	tests := []struct {
+		path  string
+		src   string
+		rows  int
+		want  string
+		wantT bool
+	}{
+		{
+			path:  "data.csv",
+			src:   "a,b\n1,2\n3,4\n5,6\n7,8\n",
+			rows:  2,
+			want:  "a,b\n1,2\n3,4\n… (2 more rows)\n",
+			wantT: true,
+		},
+		{
+			path:  "DATA.TSV",
+			src:   "a\tb\n1\t2\n3\t4\n",
+			rows:  1,
+			want:  "a\tb\n1\t2\n… (1 more row)\n",
+			wantT: true,
+		},
+		{
+			// Quoted fields may hold newlines without ending the record
+			path:  "data.csv",
+			src:   "a,b\n\"x\ny\",1\n2,3\n4,5\n",
+			rows:  1,
+			want:  "a,b\n\"x\ny\",1\n… (2 more rows)\n",
+			wantT: true,
+		},
+		{
+			path:  "data.csv",
+			src:   "a\n1\n2\n3",
+			rows:  1,
+			want:  "a\n1\n… (2 more rows)\n",
+			wantT: true,
+		},
+		{
+			// Blank lines aren't rows
+			path:  "data.csv",
+			src:   "a\n\n1\n\n2\n\n",
+			rows:  1,
+			want:  "a\n\n1\n… (1 more row)\n",
+			wantT: true,
+		},
+		{
+			// JSON Lines files have no header
+			path:  "events.jsonl",
+			src:   "{\"a\":1}\n{\"a\":2}\n{\"a\":3}\n",
+			rows:  2,
+			want:  "{\"a\":1}\n{\"a\":2}\n… (1 more row)\n",
+			wantT: true,
+		},
+		{
+			path:  "events.ndjson",
+			src:   "{}\n\n{}\n",
+			rows:  1,
+			want:  "{}\n… (1 more row)\n",
+			wantT: true,
+		},
+		{
+			path: "data.csv",
+			src:  "a,b\n1,2\n3,4\n",
+			rows: 2,
+			want: "a,b\n1,2\n3,4\n",
+		},
+		{
+			path: "data.csv",
+			src:  "a,b\n1,2\n3,4",
+			rows: 2,
+			want: "a,b\n1,2\n3,4",
+		},
+		{
+			path: "data.json",
+			src:  "[1,\n2,\n3]\n",
+			rows: 1,
+			want: "[1,\n2,\n3]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		got, truncated := TruncateRows(tt.path, []byte(tt.src), tt.rows)
+		if string(got) != tt.want || truncated != tt.wantT {
+			t.Errorf("TruncateRows(%q, %q, %d) = %q, %v, want %q, %v", tt.path, tt.src, tt.rows, got, truncated, tt.want, tt.wantT)
+		}
+	}
+}
+
+func TestTruncateRowsCounts(t *testing.T) {
+	src := "id\n" + strings.Repeat("1\n", 12345)
+
+	got, truncated := TruncateRows("ids.csv", []byte(src), 5)
+	if want := "id\n1\n1\n1\n1\n1\n… (12,340 more rows)\n"; string(got) != want || !truncated {
+		t.Errorf("TruncateRows = %q, %v, want %q, true", got, truncated, want)
+	}
+}
+
+func TestGroupThousands(t *testing.T) {
+	tests := []struct {
+		in   int
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{12340, "12,340"},
+		{1234567, "1,234,567"},
+	}
+
+	for _, tt := range tests {
+		if got := groupThousands(tt.in); got != tt.want {
+			t.Errorf("groupThousands(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package transform
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	gotoken "go/token"
+	"path/filepath"
+	"strings"
+)
+
+// Signatures reduces src, the content of the file at path, to its
+// package clause, imports, declarations and doc comments, dropping the
+// bodies of every function. It returns false, and src unchanged, when
+// the language isn't supported or the file can't be parsed.
+func Signatures(path string, src []byte) ([]byte, bool) {
+	if strings.ToLower(filepath.Ext(path)) != ".go" {
+		return src, false
+	}
+
+	fset := gotoken.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return src, false
+	}
+
+	// Remove function bodies, remembering where they were so the
+	// comments inside them can be dropped as well
+	var bodies []*ast.BlockStmt
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			if fn.Body != nil {
+				bodies = append(bodies, fn.Body)
+				fn.Body = nil
+			}
+		case *ast.FuncLit:
+			bodies = append(bodies, fn.Body)
+			fn.Body = &ast.BlockStmt{Lbrace: fn.Body.Lbrace, Rbrace: fn.Body.Lbrace}
+		}
+
+		return true
+	})
+
+	comments := file.Comments[:0]
+	for _, cg := range file.Comments {
+		if !insideAny(cg, bodies) {
+			comments = append(comments, cg)
+		}
+	}
+	file.Comments = comments
+
+	var buf bytes.Buffer
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err := cfg.Fprint(&buf, fset, file); err != nil {
+		return src, false
+	}
+
+	return buf.Bytes(), true
+}
+
+// insideAny reports whether the node is enclosed by any of the blocks
+func insideAny(n ast.Node, blocks []*ast.BlockStmt) bool {
+	for _, b := range blocks {
+		if n.Pos() > b.Lbrace && n.End() <= b.Rbrace {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,213 @@
+package transform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Limits keeping the summary of a document small, whatever its size
+const (
+	// structureItems is how many items of each array are looked at to
+	// find the keys their objects have
+	structureItems = 20
+
+	// structurePaths is how many paths a summary lists at most
+	structurePaths = 400
+
+	// structureSample is how long sample values are at most, in bytes
+	structureSample = 40
+)
+
+// plainKey matches the keys written after a dot in paths, the others
+// being quoted in brackets
+var plainKey = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$-]*$`)
+
+// structureEntry is a path found in a document, with its type and, for
+// scalars, the first value seen at it
+type structureEntry struct {
+	path   string
+	kind   string
+	sample string
+}
+
+// structure collects the paths of a document in the order they're found
+type structure struct {
+	entries []structureEntry
+	seen    map[string]bool
+}
+
+// SummarizeStructure replaces src, which is the content of the JSON or
+// YAML file at path, with the structure of the document: every path in
+// it with its type and a sample value, like
+//
+//	.dependencies: object, 42 keys
+//	.dependencies.react: string = "^18.2.0"
+//	.items[].id: number = 1
+//
+// The items of arrays are summarized together. YAML files holding
+// several documents have each one summarized in turn. It returns false,
+// and src unchanged, for other files, for those that don't parse and
+// when the summary isn't any shorter.
+func SummarizeStructure(path string, src []byte) ([]byte, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+	default:
+		return src, false
+	}
+
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return src, false
+		}
+
+		docs = append(docs, &doc)
+	}
+
+	if len(docs) == 0 {
+		return src, false
+	}
+
+	var out bytes.Buffer
+	out.WriteString("Structure of this document: every path in it with its type and a sample value\n")
+
+	for i, doc := range docs {
+		if len(docs) > 1 {
+			fmt.Fprintf(&out, "--- document %d of %d\n", i+1, len(docs))
+		}
+
+		s := &structure{seen: make(map[string]bool)}
+		s.walk(doc, "")
+
+		for n, e := range s.entries {
+			if n == structurePaths {
+				fmt.Fprintf(&out, "… (%d more paths)\n", len(s.entries)-n)
+				break
+			}
+
+			path := e.path
+			if path == "" {
+				path = "."
+			}
+
+			if e.sample != "" {
+				fmt.Fprintf(&out, "%s: %s = %s\n", path, e.kind, e.sample)
+			} else {
+				fmt.Fprintf(&out, "%s: %s\n", path, e.kind)
+			}
+		}
+	}
+
+	if out.Len() >= len(src) {
+		return src, false
+	}
+
+	return out.Bytes(), true
+}
+
+// walk adds the paths of node, found at path, to the structure
+func (s *structure) walk(node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			s.walk(child, path)
+		}
+	case yaml.AliasNode:
+		if node.Alias != nil {
+			s.walk(node.Alias, path)
+		}
+	case yaml.MappingNode:
+		s.add(path, fmt.Sprintf("object, %d %s", len(node.Content)/2, plural(len(node.Content)/2, "key")), "")
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+
+			// Merge keys bring in the keys of another mapping
+			if key == "<<" && node.Content[i].Tag == "!!merge" {
+				s.walk(node.Content[i+1], path)
+				continue
+			}
+
+			if plainKey.MatchString(key) {
+				s.walk(node.Content[i+1], path+"."+key)
+			} else {
+				s.walk(node.Content[i+1], path+"["+strconv.Quote(key)+"]")
+			}
+		}
+	case yaml.SequenceNode:
+		s.add(path, fmt.Sprintf("array, %d %s", len(node.Content), plural(len(node.Content), "item")), "")
+
+		for i, child := range node.Content {
+			if i == structureItems {
+				break
+			}
+
+			s.walk(child, path+"[]")
+		}
+	case yaml.ScalarNode:
+		s.add(path, scalarKind(node), sampleValue(node))
+	}
+}
+
+// add records path, unless it was already found, like in a previous
+// item of the same array
+func (s *structure) add(path, kind, sample string) {
+	if s.seen[path] {
+		return
+	}
+
+	s.seen[path] = true
+	s.entries = append(s.entries, structureEntry{path: path, kind: kind, sample: sample})
+}
+
+// scalarKind returns the JSON type of a scalar node
+func scalarKind(node *yaml.Node) string {
+	switch node.ShortTag() {
+	case "!!int", "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	default:
+		return "string"
+	}
+}
+
+// sampleValue returns the value of a scalar node as it would be written
+// in JSON, cut to structureSample bytes
+func sampleValue(node *yaml.Node) string {
+	switch node.ShortTag() {
+	case "!!null":
+		return ""
+	case "!!int", "!!float", "!!bool":
+		return node.Value
+	}
+
+	value := node.Value
+	if i := strings.IndexByte(value, '\n'); i >= 0 {
+		value = value[:i] + "…"
+	} else if len(value) > structureSample {
+		cut := structureSample
+		for cut > 0 && !utf8.RuneStart(value[cut]) {
+			cut--
+		}
+
+		value = value[:cut] + "…"
+	}
+
+	return strconv.Quote(value)
+}
@@ -0,0 +1,212 @@
+package transform
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// structureLines returns the paths found in the document src as they're
+// written in a summary
+func structureLines(t *testing.T, src string) []string {
+	t.Helper()
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		t.Fatalf("error parsing %q: %v", src, err)
+	}
+
+	s := &structure{seen: make(map[string]bool)}
+	s.walk(&doc, "")
+
+	lines := make([]string, 0, len(s.entries))
+	for _, e := range s.entries {
+		line := e.path + ": " + e.kind
+		if e.sample != "" {
+			line += " = " + e.sample
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func TestStructureWalk(t *testing.T) {
+	long := strings.Repeat("x", structureSample+10)
+
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{
+			src: `{"name": "app", "version": 2, "private": true, "license": null}`,
+			want: []string{
+				": object, 4 keys",
+				`.name: string = "app"`,
+				".version: number = 2",
+				".private: boolean = true",
+				".license: null",
+			},
+		},
+		{
+			// Items of arrays are summarized together, keeping the first
+			// sample seen at each path
+			src: `{"items": [{"id": 1}, {"id": 2, "tags": ["a"]}], "empty": []}`,
+			want: []string{
+				": object, 2 keys",
+				".items: array, 2 items",
+				".items[]: object, 1 key",
+				".items[].id: number = 1",
+				".items[].tags: array, 1 item",
+				`.items[].tags[]: string = "a"`,
+				".empty: array, 0 items",
+			},
+		},
+		{
+			// Keys that aren't identifiers are quoted
+			src: `{"@types/node": "^20", "a.b": 1, "$ref": "x", "kebab-case": 1.5}`,
+			want: []string{
+				": object, 4 keys",
+				`["@types/node"]: string = "^20"`,
+				`["a.b"]: number = 1`,
+				`.$ref: string = "x"`,
+				".kebab-case: number = 1.5",
+			},
+		},
+		{
+			// Long and multi-line values are cut
+			src: fmt.Sprintf("short: %s\nmulti: |\n  first\n  second\n", long),
+			want: []string{
+				": object, 2 keys",
+				fmt.Sprintf(".short: string = %q", long[:structureSample]+"…"),
+				`.multi: string = "first…"`,
+			},
+		},
+		{
+			// Aliases and merge keys bring in what they refer to
+			src: "base: &base\n  image: alpine\nweb:\n  <<: *base\n  port: 80\ncopy: *base\n",
+			want: []string{
+				": object, 3 keys",
+				".base: object, 1 key",
+				`.base.image: string = "alpine"`,
+				".web: object, 2 keys",
+				`.web.image: string = "alpine"`,
+				".web.port: number = 80",
+				".copy: object, 1 key",
+				`.copy.image: string = "alpine"`,
+			},
+		},
+		{
+			src:  "[1, 2]",
+			want: []string{": array, 2 items", "[]: number = 1"},
+		},
+		{
+			src:  `"text"`,
+			want: []string{`: string = "text"`},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := structureLines(t, tt.src); !slices.Equal(got, tt.want) {
+			t.Errorf("structure of %q = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestStructureWalkItems(t *testing.T) {
+	// Only the first items of arrays are looked at for their keys
+	items := make([]string, structureItems+1)
+	for i := range items {
+		items[i] = "{}"
+	}
+	items[structureItems] = `{"late": 1}`
+
+	got := structureLines(t, "["+strings.Join(items, ",")+"]")
+	if want := []string{": array, 21 items", "[]: object, 0 keys"}; !slices.Equal(got, want) {
+		t.Errorf("structure = %q, want %q", got, want)
+	}
+}
+
+func TestSummarizeStructure(t *testing.T) {
+	padding := strings.Repeat(" ", 500)
+
+	tests := []struct {
+		path    string
+		src     string
+		want    string
+		wantSum bool
+	}{
+		{
+			path:    "package.json",
+			src:     `{"name": "app"}` + padding,
+			want:    "Structure of this document: every path in it with its type and a sample value\n.: object, 1 key\n.name: string = \"app\"\n",
+			wantSum: true,
+		},
+		{
+			path:    "docs.YML",
+			src:     "a: 1\n---\n- x\n" + padding,
+			want:    "Structure of this document: every path in it with its type and a sample value\n--- document 1 of 2\n.: object, 1 key\n.a: number = 1\n--- document 2 of 2\n.: array, 1 item\n[]: string = \"x\"\n",
+			wantSum: true,
+		},
+		{
+			// Summaries longer than the document aren't worth it
+			path: "small.json",
+			src:  `{"name": "app"}`,
+			want: `{"name": "app"}`,
+		},
+		{
+			path: "broken.json",
+			src:  `{"name": ` + padding,
+			want: `{"name": ` + padding,
+		},
+		{
+			path: "empty.yaml",
+			src:  "",
+			want: "",
+		},
+		{
+			path: "data.toml",
+			src:  "name = \"app\"" + padding,
+			want: "name = \"app\"" + padding,
+		},
+	}
+
+	for _, tt := range tests {
+		got, summarized := SummarizeStructure(tt.path, []byte(tt.src))
+		if string(got) != tt.want || summarized != tt.wantSum {
+			t.Errorf("SummarizeStructure(%q) = %q, %v, want %q, %v", tt.path, got, summarized, tt.want, tt.wantSum)
+		}
+	}
+}
+
+func TestSummarizeStructurePaths(t *testing.T) {
+	// Summaries list a limited number of paths
+	var src strings.Builder
+	src.WriteString("{")
+	for i := range structurePaths + 5 {
+		if i > 0 {
+			src.WriteString(",")
+		}
+		fmt.Fprintf(&src, "%q: %q", fmt.Sprintf("key%d", i), strings.Repeat("v", 100))
+	}
+	src.WriteString("}")
+
+	got, summarized := SummarizeStructure("big.json", []byte(src.String()))
+	if !summarized {
+		t.Fatalf("SummarizeStructure didn't summarize a large document")
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(got), "\n"), "\n")
+	if len(lines) != structurePaths+2 {
+		t.Errorf("summary has %d lines, want %d", len(lines), structurePaths+2)
+	}
+
+	// The document itself is a path too
+	if last, want := lines[len(lines)-1], "… (6 more paths)"; last != want {
+		t.Errorf("summary ends with %q, want %q", last, want)
+	}
+}
@@ -0,0 +1,154 @@
+// Package transform implements content transformations applied to files
+// before they're emitted into a context, such as removing comments.
+package transform
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// syntax describes the lexical elements of a language that matter when
+// telling comments apart from code
+type syntax struct {
+	// lineComments start a comment that runs until the end of the line
+	lineComments []string
+
+	// blockComments are pairs of opening and closing comment delimiters
+	blockComments [][2]string
+
+	// nestedBlocks is set for languages where block comments nest
+	nestedBlocks bool
+
+	// quotes are the characters that delimit strings with escapes
+	quotes string
+
+	// rawQuotes delimit strings without escapes, like Go's backticks
+	rawQuotes string
+
+	// tripleQuotes enables Python-style """ and ''' strings
+	tripleQuotes bool
+
+	// charLiterals makes single quotes delimit short character literals
+	// only, so Rust lifetimes and apostrophes don't open a string
+	charLiterals bool
+
+	// regexLiterals enables JavaScript-style /regex/ literals
+	regexLiterals bool
+
+	// wordComments requires line comments to start at the beginning of a
+	// word, like in shell scripts and YAML where "a#b" isn't a comment
+	wordComments bool
+
+	// wordQuotes only opens strings at the beginning of a token, like in
+	// YAML where "it's" in a plain scalar isn't the start of a string
+	wordQuotes bool
+
+	// preserveShebang keeps a leading "#!" line
+	preserveShebang bool
+}
+
+var (
+	goSyntax = &syntax{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		rawQuotes:     "`",
+		charLiterals:  true,
+	}
+
+	jsSyntax = &syntax{
+		lineComments:    []string{"//"},
+		blockComments:   [][2]string{{"/*", "*/"}},
+		quotes:          "\"'`",
+		regexLiterals:   true,
+		preserveShebang: true,
+	}
+
+	cSyntax = &syntax{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		charLiterals:  true,
+	}
+
+	nestedCSyntax = &syntax{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		nestedBlocks:  true,
+		quotes:        `"'`,
+		charLiterals:  true,
+	}
+
+	cssSyntax = &syntax{
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+	}
+
+	pythonSyntax = &syntax{
+		lineComments:    []string{"#"},
+		quotes:          `"'`,
+		tripleQuotes:    true,
+		preserveShebang: true,
+	}
+
+	shellSyntax = &syntax{
+		lineComments:    []string{"#"},
+		quotes:          `"`,
+		rawQuotes:       "'",
+		wordComments:    true,
+		preserveShebang: true,
+	}
+
+	yamlSyntax = &syntax{
+		lineComments: []string{"#"},
+		quotes:       `"`,
+		rawQuotes:    "'",
+		wordComments: true,
+		wordQuotes:   true,
+	}
+)
+
+// syntaxByExtension maps lowercase file extensions to their syntax
+var syntaxByExtension = map[string]*syntax{
+	".go":    goSyntax,
+	".js":    jsSyntax,
+	".jsx":   jsSyntax,
+	".mjs":   jsSyntax,
+	".cjs":   jsSyntax,
+	".ts":    jsSyntax,
+	".tsx":   jsSyntax,
+	".mts":   jsSyntax,
+	".cts":   jsSyntax,
+	".c":     cSyntax,
+	".h":     cSyntax,
+	".cc":    cSyntax,
+	".cpp":   cSyntax,
+	".cxx":   cSyntax,
+	".hpp":   cSyntax,
+	".hh":    cSyntax,
+	".java":  cSyntax,
+	".cs":    cSyntax,
+	".dart":  cSyntax,
+	".proto": cSyntax,
+	".rs":    nestedCSyntax,
+	".swift": nestedCSyntax,
+	".kt":    nestedCSyntax,
+	".kts":   nestedCSyntax,
+	".scala": nestedCSyntax,
+	".scss":  cSyntax,
+	".less":  cSyntax,
+	".css":   cssSyntax,
+	".py":    pythonSyntax,
+	".pyi":   pythonSyntax,
+	".sh":    shellSyntax,
+	".bash":  shellSyntax,
+	".zsh":   shellSyntax,
+	".yaml":  yamlSyntax,
+	".yml":   yamlSyntax,
+}
+
+// lookupSyntax returns the syntax for the file at path, if known
+func lookupSyntax(path string) (*syntax, bool) {
+	s, found := syntaxByExtension[strings.ToLower(filepath.Ext(path))]
+	return s, found
+}
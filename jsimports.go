@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// jsImportPatterns find the modules a JavaScript or TypeScript file
+// imports: static imports and re-exports, side-effect imports, dynamic
+// imports and require calls
+var jsImportPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:import|export)\b[^;'"` + "`" + `]*?\bfrom\s*['"]([^'"\n]+)['"]`),
+	regexp.MustCompile(`\bimport\s*['"]([^'"\n]+)['"]`),
+	regexp.MustCompile(`\b(?:import|require)\s*\(\s*['"]([^'"\n]+)['"]\s*\)`),
+}
+
+// jsSourceExtensions are the extensions tried, in order, when resolving
+// an import without one, and the files whose imports are followed
+var jsSourceExtensions = []string{".ts", ".tsx", ".d.ts", ".js", ".jsx", ".mjs", ".cjs", ".mts", ".cts"}
+
+// jsConfigFileNames are the files holding the path aliases of a project,
+// the first one found at the root being used
+var jsConfigFileNames = []string{"tsconfig.json", "jsconfig.json"}
+
+// jsConfig is the part of a tsconfig.json or jsconfig.json used to
+// resolve imports
+type jsConfig struct {
+	CompilerOptions struct {
+		BaseURL string              `json:"baseUrl"`
+		Paths   map[string][]string `json:"paths"`
+	} `json:"compilerOptions"`
+}
+
+// jsImportGraph finds the files reachable from entry points by following
+// the imports of JavaScript and TypeScript modules
+type jsImportGraph struct {
+	fsys fs.FS
+
+	// baseURL is the directory non-relative imports and path aliases are
+	// resolved against, when the configuration sets one
+	baseURL string
+	paths   map[string][]string
+
+	files   []string
+	visited map[string]struct{}
+}
+
+// stripJSONComments removes the comments and trailing commas allowed in
+// tsconfig.json files, leaving strings alone
+func stripJSONComments(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '"':
+			// Copy the string up to its closing quote
+			j := i + 1
+			for ; j < len(data) && data[j] != '"'; j++ {
+				if data[j] == '\\' {
+					j++
+				}
+			}
+
+			end := min(j+1, len(data))
+			out = append(out, data[i:end]...)
+			i = end - 1
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := strings.Index(string(data[i+2:]), "*/")
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+		case c == ']' || c == '}':
+			// Drop a comma left before the closing bracket
+			trimmed := strings.TrimRight(string(out), " \t\r\n")
+			if strings.HasSuffix(trimmed, ",") {
+				out = append(out[:len(trimmed)-1], out[len(trimmed):]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+// readJSConfig reads the path aliases of the project at the root of
+// fsys, returning the name of the configuration file it found, if any
+func readJSConfig(fsys fs.FS) (string, jsConfig, error) {
+	for _, name := range jsConfigFileNames {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			continue
+		}
+
+		var cfg jsConfig
+		if err := json.Unmarshal(stripJSONComments(data), &cfg); err != nil {
+			return "", jsConfig{}, fmt.Errorf("error reading %s: %w", name, err)
+		}
+
+		return name, cfg, nil
+	}
+
+	return "", jsConfig{}, nil
+}
+
+// followJSImports returns the files reachable from the entries, modules
+// relative to root, by following relative imports and the path aliases
+// of the tsconfig.json or jsconfig.json at the root, along with that
+// configuration file. Imports of packages are left out.
+func followJSImports(fsys fs.FS, root string, entries []string) ([]string, error) {
+	configName, cfg, err := readJSConfig(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &jsImportGraph{fsys: fsys, paths: cfg.CompilerOptions.Paths, visited: make(map[string]struct{})}
+	if cfg.CompilerOptions.BaseURL != "" {
+		g.baseURL = path.Clean(filepath.ToSlash(cfg.CompilerOptions.BaseURL))
+	}
+
+	var queue []string
+	for _, entry := range entries {
+		rel := path.Clean(filepath.ToSlash(entry))
+		file, found := g.resolveFile(rel)
+		if rel == ".." || strings.HasPrefix(rel, "../") || !found {
+			return nil, fmt.Errorf("entry point %q for --entry isn't a module below %q", entry, root)
+		}
+
+		queue = append(queue, file)
+	}
+
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+
+		if _, found := g.visited[file]; found {
+			continue
+		}
+
+		g.visited[file] = struct{}{}
+		g.files = append(g.files, file)
+
+		imports, err := g.imports(file)
+		if err != nil {
+			return nil, err
+		}
+
+		queue = append(queue, imports...)
+	}
+
+	if configName != "" {
+		g.files = append(g.files, configName)
+	}
+
+	return g.files, nil
+}
+
+// imports returns the files of the project the module file imports.
+// Files that aren't modules, like stylesheets, are reachable but import
+// nothing.
+func (g *jsImportGraph) imports(file string) ([]string, error) {
+	if !isJSSource(file) {
+		return nil, nil
+	}
+
+	src, err := fs.ReadFile(g.fsys, file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %q: %w", file, err)
+	}
+
+	var files []string
+	for _, re := range jsImportPatterns {
+		for _, m := range re.FindAllSubmatch(src, -1) {
+			if target, found := g.resolve(path.Dir(file), string(m[1])); found {
+				files = append(files, target)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// resolve finds the file the import spec made from the directory dir
+// refers to, reporting whether it's part of the project
+func (g *jsImportGraph) resolve(dir, spec string) (string, bool) {
+	if strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") {
+		return g.resolveFile(path.Join(dir, spec))
+	}
+
+	base := g.baseURL
+	if base == "" {
+		base = "."
+	}
+
+	// Path aliases, like "@/*" mapped to "src/*"
+	for pattern, targets := range g.paths {
+		prefix, suffix, wildcard := strings.Cut(pattern, "*")
+
+		var match string
+		switch {
+		case !wildcard && spec == pattern:
+		case wildcard && strings.HasPrefix(spec, prefix) && strings.HasSuffix(spec, suffix) && len(spec) >= len(prefix)+len(suffix):
+			match = spec[len(prefix) : len(spec)-len(suffix)]
+		default:
+			continue
+		}
+
+		for _, target := range targets {
+			if file, found := g.resolveFile(path.Join(base, strings.Replace(target, "*", match, 1))); found {
+				return file, true
+			}
+		}
+	}
+
+	// Non-relative imports of modules below the base URL
+	if g.baseURL != "" {
+		return g.resolveFile(path.Join(g.baseURL, spec))
+	}
+
+	return "", false
+}
+
+// resolveFile finds the file at rel the way bundlers do: as is, with one
+// of the source extensions added, with a .js extension standing for a
+// TypeScript file, or as the index module of a directory
+func (g *jsImportGraph) resolveFile(rel string) (string, bool) {
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+
+	if info, err := fs.Stat(g.fsys, rel); err == nil && !info.IsDir() {
+		return rel, true
+	}
+
+	candidates := make([]string, 0, 2*len(jsSourceExtensions)+2)
+	for _, ext := range jsSourceExtensions {
+		candidates = append(candidates, rel+ext)
+	}
+
+	if stem, found := strings.CutSuffix(rel, ".js"); found {
+		candidates = append(candidates, stem+".ts", stem+".tsx")
+	}
+
+	for _, ext := range jsSourceExtensions {
+		candidates = append(candidates, path.Join(rel, "index"+ext))
+	}
+
+	for _, c := range candidates {
+		if info, err := fs.Stat(g.fsys, c); err == nil && !info.IsDir() {
+			return c, true
+		}
+	}
+
+	return "", false
+}
+
+// isJSSource reports whether the file at name is a JavaScript or
+// TypeScript module
+func isJSSource(name string) bool {
+	for _, ext := range jsSourceExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+
+	return false
+}
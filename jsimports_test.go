@@ -0,0 +1,144 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStripJSONComments(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"a": 1}`, `{"a": 1}`},
+		{"{\"a\": 1 // one\n}", "{\"a\": 1 \n}"},
+		{`{"a": /* one */ 1}`, `{"a":  1}`},
+		{`{"a": "// kept", "b": "/* kept */"}`, `{"a": "// kept", "b": "/* kept */"}`},
+		{`{"a": "say \"hi\" // kept"}`, `{"a": "say \"hi\" // kept"}`},
+		{`{"a": [1, 2,], "b": 3,}`, `{"a": [1, 2], "b": 3}`},
+		{"{\"a\": 1,\n}", "{\"a\": 1\n}"},
+		{"{\"a\": 1, // last\n}", "{\"a\": 1 \n}"},
+		{`{"a": ",}"}`, `{"a": ",}"}`},
+		{`{"a": 1} /* unterminated`, `{"a": 1} `},
+	}
+
+	for _, tt := range tests {
+		if got := string(stripJSONComments([]byte(tt.in))); got != tt.want {
+			t.Errorf("stripJSONComments(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFollowJSImports(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tsconfig.json": {Data: []byte(`{
+			// Aliases used across the app
+			"compilerOptions": {
+				"baseUrl": "src",
+				"paths": {"@/*": ["*"], "config": ["config/index.ts"]},
+			},
+		}`)},
+		"src/main.ts":         {Data: []byte("import { App } from './app';\nimport React from 'react';\nimport './styles.css';\n")},
+		"src/app.tsx":         {Data: []byte("import { api } from '@/api';\nexport * from \"./types.js\";\nconst lazy = import('./lazy');\n")},
+		"src/types.ts":        {Data: []byte("export type ID = string;\n")},
+		"src/styles.css":      {Data: []byte("body {}\n")},
+		"src/lazy.jsx":        {Data: []byte("const cfg = require('config');\n")},
+		"src/api/index.ts":    {Data: []byte("import { main } from '../main';\nimport { db } from 'lib/db';\n")},
+		"src/lib/db.mjs":      {Data: []byte("export const db = {};\n")},
+		"src/config/index.ts": {Data: []byte("export default {};\n")},
+		"src/unused.ts":       {Data: []byte("export {};\n")},
+		"legacy/old.js":       {Data: []byte("require('../../outside');\n")},
+	}
+
+	tests := []struct {
+		entries []string
+		want    []string
+	}{
+		{
+			entries: []string{"src/main.ts"},
+			want: []string{
+				"src/main.ts", "src/app.tsx", "src/styles.css", "src/api/index.ts",
+				"src/types.ts", "src/lazy.jsx", "src/lib/db.mjs", "src/config/index.ts", "tsconfig.json",
+			},
+		},
+		{
+			// Entries resolve like imports do
+			entries: []string{"./src/api"},
+			want:    []string{"src/api/index.ts", "src/main.ts", "src/lib/db.mjs", "src/app.tsx", "src/styles.css", "src/types.ts", "src/lazy.jsx", "src/config/index.ts", "tsconfig.json"},
+		},
+		{
+			// Imports leaving the root lead nowhere
+			entries: []string{"legacy/old"},
+			want:    []string{"legacy/old.js", "tsconfig.json"},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := followJSImports(fsys, ".", tt.entries)
+		if err != nil {
+			t.Errorf("followJSImports(%q) failed: %v", tt.entries, err)
+			continue
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("followJSImports(%q) = %q, want %q", tt.entries, got, tt.want)
+		}
+	}
+}
+
+func TestJSResolveFile(t *testing.T) {
+	g := &jsImportGraph{fsys: fstest.MapFS{
+		"a.ts":           {Data: nil},
+		"b.js":           {Data: nil},
+		"b.ts":           {Data: nil},
+		"c.tsx":          {Data: nil},
+		"dir/index.jsx":  {Data: nil},
+		"both.d.ts":      {Data: nil},
+		"styles/app.css": {Data: nil},
+	}}
+
+	tests := []struct {
+		rel  string
+		want string
+	}{
+		{"a", "a.ts"},
+		{"a.ts", "a.ts"},
+		{"b", "b.ts"},
+		{"b.js", "b.js"},
+		{"c.js", "c.tsx"},
+		{"dir", "dir/index.jsx"},
+		{"both", "both.d.ts"},
+		{"styles/app.css", "styles/app.css"},
+		{"styles", ""},
+		{"missing", ""},
+		{"../a", ""},
+	}
+
+	for _, tt := range tests {
+		got, found := g.resolveFile(tt.rel)
+		if got != tt.want || found != (tt.want != "") {
+			t.Errorf("resolveFile(%q) = %q, %v, want %q", tt.rel, got, found, tt.want)
+		}
+	}
+}
+
+func TestFollowJSImportsErrors(t *testing.T) {
+	tests := []struct {
+		fsys    fstest.MapFS
+		entries []string
+		want    string
+	}{
+		{fstest.MapFS{"main.ts": {}}, []string{"missing.ts"}, "isn't a module below"},
+		{fstest.MapFS{"main.ts": {}}, []string{"../main.ts"}, "isn't a module below"},
+		{fstest.MapFS{"main.ts": {}, "tsconfig.json": {Data: []byte("{nope")}}, []string{"main.ts"}, "error reading tsconfig.json"},
+	}
+
+	for _, tt := range tests {
+		_, err := followJSImports(tt.fsys, ".", tt.entries)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("followJSImports(%q) error = %v, want one containing %q", tt.entries, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// languagesByExtension maps lowercase file extensions to the name of the
+// language they're usually written in
+var languagesByExtension = map[string]string{
+	".go":         "Go",
+	".mod":        "Go Module",
+	".sum":        "Go Module",
+	".ts":         "TypeScript",
+	".tsx":        "TypeScript",
+	".mts":        "TypeScript",
+	".cts":        "TypeScript",
+	".js":         "JavaScript",
+	".jsx":        "JavaScript",
+	".mjs":        "JavaScript",
+	".cjs":        "JavaScript",
+	".py":         "Python",
+	".pyi":        "Python",
+	".rb":         "Ruby",
+	".rs":         "Rust",
+	".java":       "Java",
+	".kt":         "Kotlin",
+	".kts":        "Kotlin",
+	".scala":      "Scala",
+	".swift":      "Swift",
+	".c":          "C",
+	".h":          "C",
+	".cc":         "C++",
+	".cpp":        "C++",
+	".cxx":        "C++",
+	".hpp":        "C++",
+	".hh":         "C++",
+	".cs":         "C#",
+	".php":        "PHP",
+	".lua":        "Lua",
+	".sh":         "Shell",
+	".bash":       "Shell",
+	".zsh":        "Shell",
+	".fish":       "Shell",
+	".ps1":        "PowerShell",
+	".sql":        "SQL",
+	".html":       "HTML",
+	".htm":        "HTML",
+	".css":        "CSS",
+	".scss":       "SCSS",
+	".sass":       "Sass",
+	".less":       "Less",
+	".vue":        "Vue",
+	".svelte":     "Svelte",
+	".json":       "JSON",
+	".jsonl":      "JSON Lines",
+	".yaml":       "YAML",
+	".yml":        "YAML",
+	".toml":       "TOML",
+	".ini":        "INI",
+	".xml":        "XML",
+	".svg":        "SVG",
+	".md":         "Markdown",
+	".markdown":   "Markdown",
+	".rst":        "reStructuredText",
+	".txt":        "Text",
+	".csv":        "CSV",
+	".tsv":        "TSV",
+	".proto":      "Protocol Buffers",
+	".graphql":    "GraphQL",
+	".gql":        "GraphQL",
+	".tf":         "Terraform",
+	".hcl":        "HCL",
+	".dart":       "Dart",
+	".ex":         "Elixir",
+	".exs":        "Elixir",
+	".erl":        "Erlang",
+	".hs":         "Haskell",
+	".clj":        "Clojure",
+	".r":          "R",
+	".pl":         "Perl",
+	".zig":        "Zig",
+	".nim":        "Nim",
+	".tmpl":       "Go Template",
+	".gotmpl":     "Go Template",
+	".dockerfile": "Dockerfile",
+}
+
+// languagesByName maps well-known file names without a meaningful
+// extension to their language
+var languagesByName = map[string]string{
+	"dockerfile":  "Dockerfile",
+	"makefile":    "Makefile",
+	"gnumakefile": "Makefile",
+	"jenkinsfile": "Groovy",
+	"gemfile":     "Ruby",
+	"rakefile":    "Ruby",
+	"go.mod":      "Go Module",
+	"go.sum":      "Go Module",
+}
+
+// detectLanguage returns the language a file is written in based on its
+// name, or "Other" when it can't be determined
+func detectLanguage(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+
+	if lang, found := languagesByName[name]; found {
+		return lang
+	}
+
+	if lang, found := languagesByExtension[filepath.Ext(name)]; found {
+		return lang
+	}
+
+	return "Other"
+}
+
+// estimateTokens approximates the number of tokens an LLM would use for
+// the given amount of text, using the common four bytes per token rule
+func estimateTokens(bytes int64) int64 {
+	return (bytes + 3) / 4
+}
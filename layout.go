@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// pathPlaceholder is replaced with the path of the file in the header
+// format of the text layout
+const pathPlaceholder = "{path}"
+
+// textLayout tunes how the text format lays out files, set with
+// --separator, --file-header-format and --indent or in the text section
+// of the configuration file. Unset fields, like a nil textLayout, keep
+// the default layout, the only one merge and search can read back.
+type textLayout struct {
+	Separator  *string `yaml:"separator"`
+	FileHeader *string `yaml:"file-header"`
+	Indent     *int    `yaml:"indent"`
+}
+
+// separator returns the line enclosing file headers and closing the
+// context, which may be empty to leave it out
+func (l *textLayout) separator() string {
+	if l == nil || l.Separator == nil {
+		return separator
+	}
+
+	return *l.Separator
+}
+
+// header returns the line naming the file at path
+func (l *textLayout) header(path string) string {
+	if l == nil || l.FileHeader == nil {
+		return "file: " + path
+	}
+
+	return strings.ReplaceAll(*l.FileHeader, pathPlaceholder, path)
+}
+
+// indent returns what each line of contents is prefixed with
+func (l *textLayout) indent() string {
+	if l == nil || l.Indent == nil {
+		return "    "
+	}
+
+	return strings.Repeat(" ", *l.Indent)
+}
+
+// merge fills the fields unset in l with those of other, so flags take
+// precedence over the configuration file
+func (l *textLayout) merge(other textLayout) {
+	if l.Separator == nil {
+		l.Separator = other.Separator
+	}
+
+	if l.FileHeader == nil {
+		l.FileHeader = other.FileHeader
+	}
+
+	if l.Indent == nil {
+		l.Indent = other.Indent
+	}
+}
+
+// validate checks the layout can be used
+func (l *textLayout) validate() error {
+	if l.FileHeader != nil && !strings.Contains(*l.FileHeader, pathPlaceholder) {
+		return fmt.Errorf("file header format %q must contain %s", *l.FileHeader, pathPlaceholder)
+	}
+
+	if l.Separator != nil && strings.Contains(*l.Separator, "\n") {
+		return fmt.Errorf("separator %q must be a single line", *l.Separator)
+	}
+
+	if l.Indent != nil && *l.Indent < 0 {
+		return fmt.Errorf("indent must be 0 or more spaces, got %d", *l.Indent)
+	}
+
+	return nil
+}
+
+// writeFileHeader writes the separator-enclosed header that starts the
+// contents of a file, followed by any extra header lines
+func (l *textLayout) writeFileHeader(w io.Writer, path string, extra ...string) {
+	sep := l.separator()
+
+	// Write the first line of dashes
+	if sep != "" {
+		fmt.Fprintln(w, sep)
+	}
+	// Write the relative file path
+	fmt.Fprintln(w, l.header(path))
+	// Write any extra information about the file
+	for _, line := range extra {
+		fmt.Fprintln(w, line)
+	}
+	// Write the second line of dashes
+	if sep != "" {
+		fmt.Fprintln(w, sep)
+	}
+}
+
+// writeLine writes a single line of file contents
+func (l *textLayout) writeLine(w io.Writer, line string) {
+	// Write each line indented, 4 spaces by default
+	fmt.Fprintf(w, "%s%s\n", l.indent(), line)
+}
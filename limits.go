@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// truncationMarker ends the lines cut by --max-line-length
+const truncationMarker = "…"
+
+// overMaxLines reports whether a file with the given number of lines is
+// left out by --max-lines
+func (o options) overMaxLines(lines int) bool {
+	return o.maxLines > 0 && lines > o.maxLines
+}
+
+// maxLinesExclusion returns the key files left out by --max-lines are
+// counted under
+func (o options) maxLinesExclusion() string {
+	return fmt.Sprintf("--max-lines=%d", o.maxLines)
+}
+
+// truncateLongLines cuts the lines of content longer than --max-line-length
+// bytes, like those of minified bundles or data files, ending them with
+// a marker telling how much was left out. It returns the contents along
+// with how many lines were cut.
+func (o options) truncateLongLines(content []byte) ([]byte, int) {
+	if o.maxLineLength <= 0 || len(content) <= o.maxLineLength {
+		return content, 0
+	}
+
+	var (
+		out   []byte
+		cut   int
+		start int
+	)
+	for start < len(content) {
+		end := bytes.IndexByte(content[start:], '\n')
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += start
+		}
+
+		line := bytes.TrimSuffix(content[start:end], []byte("\r"))
+		if len(line) > o.maxLineLength {
+			// Only copy the contents once a line has to be cut
+			if out == nil {
+				out = append(make([]byte, 0, len(content)), content[:start]...)
+			}
+
+			// Cut at the start of a character so it stays valid UTF-8
+			keep := o.maxLineLength
+			for keep > 0 && !utf8.RuneStart(line[keep]) {
+				keep--
+			}
+
+			out = append(out, line[:keep]...)
+			out = fmt.Appendf(out, "%s [%d bytes truncated]", truncationMarker, len(line)-keep)
+			out = append(out, content[start+len(line):min(end+1, len(content))]...)
+			cut++
+		} else if out != nil {
+			out = append(out, content[start:min(end+1, len(content))]...)
+		}
+
+		start = end + 1
+	}
+
+	if out == nil {
+		return content, 0
+	}
+
+	return out, cut
+}
+
+// largeFileSample is the value of the --sample-large-files flag: how
+// many lines to keep from the start and the end of files over the size
+// or line limits, instead of leaving them out. It implements pflag.Value
+// so invalid samples are rejected while parsing flags.
+type largeFileSample struct {
+	head int
+	tail int
+}
+
+func (s *largeFileSample) String() string {
+	if !s.enabled() {
+		return ""
+	}
+
+	return fmt.Sprintf("head:%d,tail:%d", s.head, s.tail)
+}
+
+func (s *largeFileSample) Set(value string) error {
+	var sample largeFileSample
+	for _, part := range strings.Split(value, ",") {
+		name, count, found := strings.Cut(strings.TrimSpace(part), ":")
+		n, err := strconv.Atoi(count)
+		if !found || err != nil || n < 0 {
+			return fmt.Errorf("invalid sample %q, must be like head:200,tail:50", part)
+		}
+
+		switch name {
+		case "head":
+			sample.head = n
+		case "tail":
+			sample.tail = n
+		default:
+			return fmt.Errorf("invalid sample %q, must be like head:200,tail:50", part)
+		}
+	}
+
+	if !sample.enabled() {
+		return fmt.Errorf("must keep at least one line, like head:200,tail:50")
+	}
+
+	*s = sample
+	return nil
+}
+
+func (s *largeFileSample) Type() string {
+	return "sample"
+}
+
+// enabled reports whether large files are sampled rather than left out
+func (s largeFileSample) enabled() bool {
+	return s.head > 0 || s.tail > 0
+}
+
+// read reads r a line at a time, keeping only the first and last lines
+// of the sample, so files of any size can be sampled without holding
+// them whole. The lines left out are replaced with a marker telling how
+// many there were.
+func (s largeFileSample) read(r io.Reader) (string, error) {
+	var (
+		head  strings.Builder
+		tail  = make([]string, 0, s.tail)
+		next  int
+		lines int
+	)
+
+	br := bufio.NewReaderSize(r, 32*1024)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			lines++
+
+			switch {
+			case lines <= s.head:
+				head.WriteString(line)
+			case s.tail == 0:
+			case len(tail) < s.tail:
+				tail = append(tail, line)
+			default:
+				// Keep the last lines in a ring, overwriting the oldest
+				tail[next] = line
+				next = (next + 1) % s.tail
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if left := lines - s.head - len(tail); left > 0 {
+		fmt.Fprintf(&head, "%s [%d lines left out by --sample-large-files]\n", truncationMarker, left)
+	}
+
+	for i := range tail {
+		head.WriteString(tail[(next+i)%len(tail)])
+	}
+
+	return head.String(), nil
+}
+
+// sampleLarge returns the sample of the file f, with the given number of
+// lines, when it's over the size or line limits and --sample-large-files
+// is set, reporting whether it was sampled
+func (o options) sampleLarge(f contextFile, lines int) (string, bool) {
+	if f.sampled || !o.largeSample.enabled() || (!o.overMaxLines(lines) && !o.overMaxFileSize(f.Size)) {
+		return f.Content, f.sampled
+	}
+
+	content, _ := o.largeSample.read(strings.NewReader(f.Content))
+	return content, true
+}
+
+// overMaxFileSize reports whether a file of the given size is over
+// --max-file-size
+func (o options) overMaxFileSize(size int64) bool {
+	return o.filters.MaxFileSize > 0 && size > int64(o.filters.MaxFileSize)
+}
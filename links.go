@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Code hosts whose permalink formats are known
+const (
+	hostGitHub    = "github"
+	hostGitLab    = "gitlab"
+	hostBitbucket = "bitbucket"
+)
+
+// fileLinker builds permalinks to files on the code host of the
+// repository being scanned
+type fileLinker struct {
+	host     string
+	baseURL  string
+	ref      string
+	toplevel string
+}
+
+// newFileLinker inspects the git repository holding root to find out
+// where its files can be browsed online, linking to the commit rev when
+// the files are read from it, or to the checkout otherwise
+func newFileLinker(ctx context.Context, root, rev string) (*fileLinker, error) {
+	toplevel, err := gitOutput(ctx, root, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("directory %q isn't in a git repository: %w", root, err)
+	}
+
+	remote, err := gitOutput(ctx, root, "remote", "get-url", "origin")
+	if err != nil {
+		return nil, fmt.Errorf("repository has no origin remote: %w", err)
+	}
+
+	host, baseURL, err := parseRemoteURL(remote)
+	if err != nil {
+		return nil, err
+	}
+
+	if rev != "" {
+		return &fileLinker{host: host, baseURL: baseURL, ref: rev, toplevel: toplevel}, nil
+	}
+
+	// Link to the exact commit so links keep working as the branch moves
+	ref, err := gitOutput(ctx, root, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("repository has no commits: %w", err)
+	}
+
+	return &fileLinker{host: host, baseURL: baseURL, ref: ref, toplevel: toplevel}, nil
+}
+
+// parseRemoteURL turns a git remote, in either its URL or its scp-like
+// form, into the web address of the repository and the kind of host
+func parseRemoteURL(remote string) (string, string, error) {
+	address := remote
+
+	// Convert scp-like addresses, like git@github.com:org/repo.git
+	if !strings.Contains(address, "://") {
+		userHost, path, found := strings.Cut(address, ":")
+		if !found {
+			return "", "", fmt.Errorf("unsupported remote URL %q", remote)
+		}
+
+		_, host, hasUser := strings.Cut(userHost, "@")
+		if !hasUser {
+			host = userHost
+		}
+
+		address = "https://" + host + "/" + path
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", "", fmt.Errorf("unsupported remote URL %q: %w", remote, err)
+	}
+
+	hostname := strings.ToLower(u.Hostname())
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+
+	var kind string
+	switch {
+	case strings.Contains(hostname, "github"):
+		kind = hostGitHub
+	case strings.Contains(hostname, "gitlab"):
+		kind = hostGitLab
+	case strings.Contains(hostname, "bitbucket"):
+		kind = hostBitbucket
+	default:
+		return "", "", fmt.Errorf("remote %q isn't on a known code host like GitHub or GitLab", remote)
+	}
+
+	return kind, "https://" + hostname + "/" + path, nil
+}
+
+// link returns the permalink to the file at path, anchored to its lines
+func (l *fileLinker) link(path string, lines int) string {
+	if l == nil {
+		return ""
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+
+	// Paths reported by git are resolved, so resolve ours as well
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+
+	rel, err := filepath.Rel(l.toplevel, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+
+	var escaped []string
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		escaped = append(escaped, url.PathEscape(part))
+	}
+	rel = strings.Join(escaped, "/")
+
+	switch l.host {
+	case hostGitLab:
+		return fmt.Sprintf("%s/-/blob/%s/%s%s", l.baseURL, l.ref, rel, lineAnchor("#L", "-", lines))
+	case hostBitbucket:
+		return fmt.Sprintf("%s/src/%s/%s%s", l.baseURL, l.ref, rel, lineAnchor("#lines-", ":", lines))
+	default:
+		return fmt.Sprintf("%s/blob/%s/%s%s", l.baseURL, l.ref, rel, lineAnchor("#L", "-L", lines))
+	}
+}
+
+// lineAnchor builds the anchor selecting the lines of a file
+func lineAnchor(prefix, rangeSep string, lines int) string {
+	if lines < 1 {
+		return ""
+	}
+
+	if lines == 1 {
+		return prefix + "1"
+	}
+
+	return fmt.Sprintf("%s1%s%d", prefix, rangeSep, lines)
+}
+
+// countLines returns how many lines content has, counting a last line
+// without a trailing newline
+func countLines(content string) int {
+	n := strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/patrickdappollonio/context-generator/filter"
+)
+
+// discardLogger is used by runs that aren't verbose
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// newVerboseLogger returns the logger of --verbose, writing structured
+// lines to stderr so they never mix with the context on stdout
+func newVerboseLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+// log returns the logger for the run, which discards everything unless
+// --verbose is set
+func (o options) log() *slog.Logger {
+	if o.logger == nil {
+		return discardLogger
+	}
+
+	return o.logger
+}
+
+// traceDecisions logs every decision of the walk filters to logger
+func traceDecisions(logger *slog.Logger) func(d filter.Decision) {
+	return func(d filter.Decision) {
+		attrs := []any{"path", d.Path, "dir", d.Dir, "included", d.Included}
+		if d.Reason != "" {
+			attrs = append(attrs, "reason", d.Reason)
+		}
+
+		logger.Debug("walk", attrs...)
+	}
+}
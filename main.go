@@ -1,15 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
+	// Stop gracefully on Ctrl-C or termination so progress can be saved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Call the run function and handle any errors
-	if err := getMainCommand().Execute(); err != nil {
+	if err := getMainCommand().ExecuteContext(ctx); err != nil {
 		// Print the error to stderr and exit with code 1
 		fmt.Fprintln(os.Stderr, "Error:", err)
+		stop()
+
+		// Some errors, like going over a budget, have their own exit code
+		var coded interface{ ExitCode() int }
+		if errors.As(err, &coded) {
+			os.Exit(coded.ExitCode())
+		}
+
 		os.Exit(1)
 	}
 }
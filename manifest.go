@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// manifestVersion is bumped when the manifest changes in ways readers
+// of older ones can't handle
+const manifestVersion = 1
+
+// contextManifest is written next to a context by --manifest, listing
+// every file in it along with the settings that produced it, so the
+// context can be regenerated or audited later. A nil manifest records
+// nothing.
+type contextManifest struct {
+	path string
+
+	Version   int               `json:"version"`
+	Project   string            `json:"project"`
+	Generated string            `json:"generated"`
+	Root      string            `json:"root"`
+	Git       *gitMetadata      `json:"git,omitempty"`
+	Settings  frontMatterFilter `json:"settings"`
+	Totals    manifestTotals    `json:"totals"`
+	Files     []manifestFile    `json:"files"`
+}
+
+// manifestTotals sums up the files of a manifest
+type manifestTotals struct {
+	Files  int   `json:"files"`
+	Lines  int   `json:"lines"`
+	Bytes  int64 `json:"bytes"`
+	Tokens int64 `json:"tokens"`
+}
+
+// manifestFile is a file included in a context, as read from disk
+type manifestFile struct {
+	manifestEntry
+	IdenticalTo string `json:"identical_to,omitempty"`
+	SimilarTo   string `json:"similar_to,omitempty"`
+}
+
+// manifestPath returns where the manifest of a context written to
+// outputs goes: next to the first output, named after it, or in the
+// current directory when the context only goes to stdout
+func manifestPath(outputs []string) string {
+	if len(outputs) == 0 {
+		return "context.manifest.json"
+	}
+
+	output := outputs[0]
+	return strings.TrimSuffix(output, filepath.Ext(output)) + ".manifest.json"
+}
+
+// newContextManifest returns the manifest of a context generated with
+// opts, recording the settings the front matter would
+func newContextManifest(opts options, git *gitMetadata, generated time.Time) *contextManifest {
+	summary := newFrontMatter(opts, generated)
+
+	return &contextManifest{
+		path:      manifestPath(opts.outputs),
+		Version:   manifestVersion,
+		Project:   summary.Project,
+		Generated: summary.Generated,
+		Root:      summary.Root,
+		Git:       git,
+		Settings:  summary.Settings,
+		Files:     []manifestFile{},
+	}
+}
+
+// add records an included file in the manifest
+func (m *contextManifest) add(f contextFile) {
+	if m == nil {
+		return
+	}
+
+	m.Files = append(m.Files, manifestFile{manifestEntry: f.manifestEntry, IdenticalTo: f.IdenticalTo, SimilarTo: f.SimilarTo})
+}
+
+// write saves the manifest with the totals of the context
+func (m *contextManifest) write(totals contextTotals) error {
+	if m == nil {
+		return nil
+	}
+
+	m.Totals = manifestTotals{Files: totals.files, Lines: totals.lines, Bytes: totals.bytes, Tokens: totals.tokens()}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+
+	if err := os.WriteFile(m.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing manifest %q: %w", m.path, err)
+	}
+
+	return nil
+}
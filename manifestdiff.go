@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// readManifest reads a manifest written by --manifest
+func readManifest(path string) (*contextManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest %q: %w", path, err)
+	}
+
+	var m contextManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing manifest %q: %w", path, err)
+	}
+
+	if m.Version < 1 || m.Version > manifestVersion {
+		return nil, fmt.Errorf("manifest %q has version %d, but only versions up to %d can be read", path, m.Version, manifestVersion)
+	}
+
+	m.path = path
+	return &m, nil
+}
+
+// manifestChange is a file that differs between two manifests
+type manifestChange struct {
+	change string
+	path   string
+	old    *manifestFile
+	new    *manifestFile
+}
+
+// diffManifests lists the files added, deleted, modified and renamed
+// from one manifest to the other, sorted by path, and how many are unchanged. A file
+// deleted with the same contents as one added is taken as renamed.
+func diffManifests(from, to *contextManifest) ([]manifestChange, int) {
+	before := make(map[string]*manifestFile, len(from.Files))
+	for i := range from.Files {
+		before[from.Files[i].Path] = &from.Files[i]
+	}
+
+	after := make(map[string]*manifestFile, len(to.Files))
+	for i := range to.Files {
+		after[to.Files[i].Path] = &to.Files[i]
+	}
+
+	var (
+		changes   []manifestChange
+		unchanged int
+		added     []*manifestFile
+	)
+
+	for i := range to.Files {
+		f := &to.Files[i]
+
+		prev, found := before[f.Path]
+		switch {
+		case !found:
+			added = append(added, f)
+		case prev.SHA256 != f.SHA256 || prev.Size != f.Size:
+			changes = append(changes, manifestChange{change: changeModified, path: f.Path, old: prev, new: f})
+		default:
+			unchanged++
+		}
+	}
+
+	// Deleted files are indexed by contents to find the renamed ones
+	deleted := make(map[string][]*manifestFile)
+	for i := range from.Files {
+		f := &from.Files[i]
+		if _, found := after[f.Path]; !found {
+			deleted[f.SHA256] = append(deleted[f.SHA256], f)
+		}
+	}
+
+	for _, f := range added {
+		if candidates := deleted[f.SHA256]; f.SHA256 != "" && len(candidates) > 0 {
+			deleted[f.SHA256] = candidates[1:]
+			changes = append(changes, manifestChange{change: changeRenamed + candidates[0].Path, path: f.Path, old: candidates[0], new: f})
+			continue
+		}
+
+		changes = append(changes, manifestChange{change: changeAdded, path: f.Path, new: f})
+	}
+
+	for _, files := range deleted {
+		for _, f := range files {
+			changes = append(changes, manifestChange{change: changeDeleted, path: f.Path, old: f})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b manifestChange) int {
+		return strings.Compare(a.path, b.path)
+	})
+
+	return changes, unchanged
+}
+
+// changedSettings returns the names of the settings that differ between
+// two manifests, sorted
+func changedSettings(from, to frontMatterFilter) []string {
+	var before, after map[string]json.RawMessage
+	for _, s := range []struct {
+		settings frontMatterFilter
+		into     *map[string]json.RawMessage
+	}{{from, &before}, {to, &after}} {
+		data, _ := json.Marshal(s.settings)
+		_ = json.Unmarshal(data, s.into)
+	}
+
+	var names []string
+	for name, value := range after {
+		if !bytes.Equal(before[name], value) {
+			names = append(names, name)
+		}
+	}
+
+	for name := range before {
+		if _, found := after[name]; !found {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// printManifestDiff writes the changes between two manifests as a table,
+// followed by the settings that changed and a line totaling them
+func printManifestDiff(w io.Writer, from, to *contextManifest) error {
+	changes, unchanged := diffManifests(from, to)
+
+	counts := make(map[string]int)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range changes {
+		size := ""
+		switch {
+		case c.old != nil && c.new != nil && c.old.Size != c.new.Size:
+			size = humanBytes(c.old.Size) + " -> " + humanBytes(c.new.Size)
+		case c.new != nil:
+			size = humanBytes(c.new.Size)
+		case c.old != nil:
+			size = humanBytes(c.old.Size)
+		}
+
+		change := c.change
+		if strings.HasPrefix(change, changeRenamed) {
+			counts[changeRenamed]++
+		} else {
+			counts[change]++
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", change, c.path, size)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if settings := changedSettings(from.Settings, to.Settings); len(settings) > 0 {
+		fmt.Fprintf(w, "settings changed: %s\n", strings.Join(settings, ", "))
+	}
+
+	_, err := fmt.Fprintf(w, "%d added, %d deleted, %d modified, %d renamed, %d unchanged; %d -> %d tokens\n",
+		counts[changeAdded], counts[changeDeleted], counts[changeModified], counts[changeRenamed], unchanged,
+		from.Totals.Tokens, to.Totals.Tokens)
+	return err
+}
+
+func newManifestDiffCommand() *cobra.Command {
+	var exitCode bool
+
+	cmd := &cobra.Command{
+		Use:   "manifest-diff old.json new.json",
+		Short: "Report the files added, deleted, modified and renamed between the contexts two manifests written by --manifest describe, and the settings that changed",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, err := readManifest(args[0])
+			if err != nil {
+				return err
+			}
+
+			to, err := readManifest(args[1])
+			if err != nil {
+				return err
+			}
+
+			if err := printManifestDiff(cmd.OutOrStdout(), from, to); err != nil {
+				return err
+			}
+
+			if changes, _ := diffManifests(from, to); exitCode && len(changes) > 0 {
+				return fmt.Errorf("%s changed between %q and %q", plural(len(changes), "file"), from.path, to.path)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&exitCode, "exit-code", false, "fail when any file changed, like git diff --exit-code")
+
+	return cmd
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// labeledContext is a context read back from disk along with the label
+// its paths should be prefixed with
+type labeledContext struct {
+	label string
+	files []contextFile
+}
+
+// mergeContexts stitches the files of several contexts together in walk
+// order. Paths are prefixed with the label of their context, if any, and
+// files appearing more than once with the same contents are kept once.
+func mergeContexts(contexts []labeledContext) ([]contextFile, error) {
+	seen := make(map[string]contextFile)
+	var merged []contextFile
+
+	for _, c := range contexts {
+		for _, f := range c.files {
+			f.Path = labelPath(c.label, f.Path)
+
+			if prev, found := seen[f.Path]; found {
+				if prev.SHA256 != f.SHA256 {
+					return nil, fmt.Errorf("file %q appears more than once with different contents, generate the contexts with different --label values to keep both", f.Path)
+				}
+
+				continue
+			}
+
+			seen[f.Path] = f
+			merged = append(merged, f)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return walkOrderLess(merged[i].Path, merged[j].Path)
+	})
+
+	return merged, nil
+}
+
+// labelPath prefixes path with label, when there's one
+func labelPath(label, path string) string {
+	if label == "" {
+		return path
+	}
+
+	return strings.TrimSuffix(label, "/") + "/" + strings.TrimPrefix(filepath.ToSlash(path), "./")
+}
+
+// mergeManifests combines the checkpoints written by several shard runs
+// into a single, complete checkpoint
+func mergeManifests(paths []string, output string) error {
+	merged := &checkpoint{
+		Version:  checkpointVersion,
+		Complete: true,
+		Files:    []manifestEntry{},
+		path:     output,
+	}
+
+	seen := make(map[string]manifestEntry)
+
+	for i, path := range paths {
+		cp, err := readCheckpoint(path)
+		if err != nil {
+			return err
+		}
+
+		if !cp.Complete {
+			return fmt.Errorf("checkpoint %q belongs to a run that didn't finish", path)
+		}
+
+		if i == 0 {
+			merged.Root = cp.Root
+		} else if cp.Root != merged.Root {
+			return fmt.Errorf("checkpoint %q was created for directory %q, not %q", path, cp.Root, merged.Root)
+		}
+
+		for _, f := range cp.Files {
+			if prev, found := seen[f.Path]; found {
+				if prev.SHA256 != f.SHA256 {
+					return fmt.Errorf("file %q appears in more than one checkpoint with different contents", f.Path)
+				}
+
+				continue
+			}
+
+			seen[f.Path] = f
+			merged.Files = append(merged.Files, f)
+		}
+	}
+
+	sort.SliceStable(merged.Files, func(i, j int) bool {
+		return walkOrderLess(merged.Files[i].Path, merged.Files[j].Path)
+	})
+
+	merged.LastPath = ""
+	if n := len(merged.Files); n > 0 {
+		merged.LastPath = merged.Files[n-1].Path
+	}
+
+	return merged.write()
+}
+
+func newMergeCommand() *cobra.Command {
+	var manifests []string
+	var manifestOutput string
+	var format, label string
+
+	cmd := &cobra.Command{
+		Use:   "merge context...",
+		Short: "Combine contexts generated separately, like shards or other repositories, into a single context",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(manifests) > 0 && manifestOutput == "" {
+				return fmt.Errorf("flag --manifest requires --manifest-output to be set")
+			}
+
+			contexts := make([]labeledContext, 0, len(args))
+			for _, path := range args {
+				f, err := os.Open(path)
+				if err != nil {
+					return fmt.Errorf("error opening context %q: %w", path, err)
+				}
+
+				header, files, err := parseContext(f)
+				f.Close()
+				if err != nil {
+					return fmt.Errorf("error parsing context %q: %w", path, err)
+				}
+				warnExpired(path, header)
+
+				contexts = append(contexts, labeledContext{label: header.Label, files: files})
+			}
+
+			merged, err := mergeContexts(contexts)
+			if err != nil {
+				return err
+			}
+
+			cw, err := newContextWriter(format, cmd.OutOrStdout(), contextHeader{Label: label}, nil)
+			if err != nil {
+				return err
+			}
+
+			if len(manifests) > 0 {
+				if err := mergeManifests(manifests, manifestOutput); err != nil {
+					return err
+				}
+			}
+
+			for _, f := range merged {
+				if err := cw.writeFile(f); err != nil {
+					return err
+				}
+			}
+
+			return cw.close()
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", formatText, "output format: "+strings.Join(formats, ", "))
+	cmd.Flags().StringVar(&label, "label", "", "label recorded in the merged JSON output")
+	cmd.Flags().StringSliceVar(&manifests, "manifest", nil, "checkpoint files written by the shard runs to combine into a single manifest")
+	cmd.Flags().StringVar(&manifestOutput, "manifest-output", "", "write the combined manifest of the files given to --manifest to this file")
+
+	return cmd
+}
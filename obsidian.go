@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// formatObsidian writes a folder of notes rather than a single stream, so
+// it's only available when generating a context, not when merging
+const formatObsidian = "obsidian"
+
+// obsidianIndexNote is the name of the note linking to every file; file
+// notes keep their extension before ".md", so they can't collide with it
+const obsidianIndexNote = "index.md"
+
+// obsidianFrontMatter is the YAML front matter of a file note, which
+// note-taking tools show as the note properties
+type obsidianFrontMatter struct {
+	Path        string `yaml:"path"`
+	Language    string `yaml:"language"`
+	Link        string `yaml:"link,omitempty"`
+	IdenticalTo string `yaml:"identical-to,omitempty"`
+	SimilarTo   string `yaml:"similar-to,omitempty"`
+	Size        int64  `yaml:"size,omitempty"`
+	Modified    string `yaml:"modified,omitempty"`
+	Lines       int    `yaml:"lines,omitempty"`
+	Commit      string `yaml:"commit,omitempty"`
+	Author      string `yaml:"author,omitempty"`
+}
+
+// obsidianWriter writes one markdown note per file into a vault folder,
+// mirroring the tree being scanned, plus an index note linking to all of
+// them. Every file note links back to the index.
+type obsidianWriter struct {
+	dir    string
+	header contextHeader
+	notes  []string
+}
+
+// newObsidianWriter returns a writer creating the vault at dir
+func newObsidianWriter(dir string, header contextHeader) (*obsidianWriter, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("format %s requires --vault to be set", formatObsidian)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating vault directory %q: %w", dir, err)
+	}
+
+	return &obsidianWriter{dir: dir, header: header}, nil
+}
+
+// notePath returns the path of the note for the file at path, relative
+// to the vault and using forward slashes like note links do
+func (o *obsidianWriter) notePath(path string) string {
+	rel, err := filepath.Rel(o.header.Root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = path
+	}
+
+	return filepath.ToSlash(rel) + ".md"
+}
+
+func (o *obsidianWriter) writeFile(f contextFile) error {
+	note := o.notePath(f.Path)
+	o.notes = append(o.notes, note)
+
+	front := obsidianFrontMatter{
+		Path:        filepath.ToSlash(f.Path),
+		Language:    detectLanguage(f.Path),
+		Link:        f.Link,
+		IdenticalTo: f.IdenticalTo,
+		SimilarTo:   f.SimilarTo,
+	}
+
+	if m := f.Metadata; m != nil {
+		front.Size = m.Size
+		front.Modified = m.Modified.Format(time.RFC3339)
+		front.Lines = m.Lines
+	}
+
+	if g := f.Git; g != nil {
+		front.Commit = g.Commit
+		front.Author = g.Author
+	}
+
+	meta, err := yaml.Marshal(front)
+	if err != nil {
+		return fmt.Errorf("error encoding front matter of %q: %w", f.Path, err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "---\n%s---\n\n", meta)
+	fmt.Fprintf(&buf, "# %s\n\n", filepath.Base(f.Path))
+	fmt.Fprintf(&buf, "Part of [[%s|%s]]\n\n", obsidianIndexNote, o.title())
+
+	if f.IdenticalTo != "" {
+		fmt.Fprintf(&buf, "Identical to [[%s]]\n", strings.TrimSuffix(o.notePath(f.IdenticalTo), ".md"))
+		return o.write(note, buf.Bytes())
+	}
+
+	if f.SimilarTo != "" {
+		fmt.Fprintf(&buf, "Similar to [[%s]], %.0f%% alike\n", strings.TrimSuffix(o.notePath(f.SimilarTo), ".md"), f.Similarity*100)
+		return o.write(note, buf.Bytes())
+	}
+
+	fence := markdownFence(f.Content)
+	fmt.Fprintf(&buf, "%s%s\n", fence, markdownLanguage(f.Path))
+	eachLine(f.Content, func(line string) {
+		fmt.Fprintln(&buf, line)
+	})
+	fmt.Fprintln(&buf, fence)
+
+	return o.write(note, buf.Bytes())
+}
+
+func (o *obsidianWriter) close() error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", o.title())
+
+	// Group notes under a heading per directory, in walk order
+	current := "."
+	for _, note := range o.notes {
+		if dir := filepath.ToSlash(filepath.Dir(note)); dir != current {
+			fmt.Fprintf(&buf, "\n## %s/\n\n", dir)
+			current = dir
+		}
+
+		fmt.Fprintf(&buf, "- [[%s|%s]]\n", note, strings.TrimSuffix(filepath.Base(note), ".md"))
+	}
+
+	return o.write(obsidianIndexNote, buf.Bytes())
+}
+
+// title returns the name the context is shown with in the index note
+func (o *obsidianWriter) title() string {
+	if o.header.Label != "" {
+		return o.header.Label
+	}
+
+	if o.header.Root != "" && o.header.Root != "." {
+		return filepath.Base(o.header.Root)
+	}
+
+	return "Context"
+}
+
+// write writes a note to its path inside the vault
+func (o *obsidianWriter) write(note string, data []byte) error {
+	path := filepath.Join(o.dir, filepath.FromSlash(note))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating vault directory %q: %w", filepath.Dir(path), err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing note %q: %w", path, err)
+	}
+
+	return nil
+}
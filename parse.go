@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lineScanner reads lines like bufio.Scanner does, but of any length,
+// since files in a context, like minified bundles, may have lines far
+// longer than a scanner's buffer
+type lineScanner struct {
+	r    *bufio.Reader
+	line string
+	err  error
+}
+
+// newLineScanner returns a scanner reading lines from r
+func newLineScanner(r io.Reader) *lineScanner {
+	return &lineScanner{r: bufio.NewReader(r)}
+}
+
+// Scan reads the next line, returning false at the end of the input or
+// on an error
+func (s *lineScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+
+	line, err := s.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		s.err = err
+		return false
+	}
+
+	s.line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	return true
+}
+
+// Text returns the line read by the last call to Scan, without its line
+// ending
+func (s *lineScanner) Text() string {
+	return s.line
+}
+
+// Err returns the error that stopped the scanner, if it wasn't the end
+// of the input
+func (s *lineScanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+
+	return s.err
+}
+
+// parseContext reads back a context previously generated in the text,
+// JSON or JSON Lines format, detecting which one it is. Contexts in the
+// plain-compact format are rejected.
+func parseContext(r io.Reader) (contextHeader, []contextFile, error) {
+	br := bufio.NewReader(r)
+
+	// JSON contexts are the only ones starting with a brace
+	peek, _ := br.Peek(512)
+	if !bytes.HasPrefix(bytes.TrimSpace(peek), []byte("{")) {
+		return parseTextContext(br)
+	}
+
+	header, files, err := parseJSONContext(br)
+	if err != nil {
+		return contextHeader{}, nil, err
+	}
+
+	// Fill in the hashes of hand-written or older contexts
+	for i := range files {
+		if files[i].SHA256 == "" {
+			files[i].manifestEntry = contentEntry(files[i].Path, files[i].Content)
+		}
+	}
+
+	return header, files, nil
+}
+
+// parseJSONContext reads either a single JSON document holding every
+// file, or a stream of JSON values with one file each
+func parseJSONContext(r io.Reader) (contextHeader, []contextFile, error) {
+	dec := json.NewDecoder(r)
+
+	var first json.RawMessage
+	if err := dec.Decode(&first); err != nil {
+		return contextHeader{}, nil, err
+	}
+
+	// Only the single document format has a list of files
+	var doc jsonContext
+	if err := json.Unmarshal(first, &doc); err != nil {
+		return contextHeader{}, nil, err
+	}
+
+	if doc.Files != nil {
+		return doc.contextHeader, doc.Files, nil
+	}
+
+	var f contextFile
+	if err := json.Unmarshal(first, &f); err != nil {
+		return contextHeader{}, nil, err
+	}
+	files := []contextFile{f}
+
+	for {
+		var f contextFile
+		if err := dec.Decode(&f); err != nil {
+			if err == io.EOF {
+				return contextHeader{}, files, nil
+			}
+
+			return contextHeader{}, nil, fmt.Errorf("error reading file %d of JSON Lines context: %w", len(files)+1, err)
+		}
+
+		files = append(files, f)
+	}
+}
+
+// parseTextContext reads a context previously generated in the default
+// text format back into its files, along with the header found in its
+// front matter, if any
+func parseTextContext(r io.Reader) (contextHeader, []contextFile, error) {
+	var (
+		header      contextHeader
+		files       []contextFile
+		path        string
+		link        string
+		identicalTo string
+		similarTo   string
+		similarity  float64
+		metadata    *fileMetadata
+		git         *fileGitInfo
+		lines       []string
+		started     bool
+		lineNo      int
+	)
+
+	// finish records the file being read, if any
+	finish := func() {
+		if !started {
+			return
+		}
+
+		content := ""
+		if len(lines) > 0 {
+			content = strings.Join(lines, "\n") + "\n"
+		}
+
+		files = append(files, contextFile{manifestEntry: contentEntry(path, content), Link: link, IdenticalTo: identicalTo, SimilarTo: similarTo, Similarity: similarity, Metadata: metadata, Git: git, Content: content})
+		lines, started = nil, false
+	}
+
+	scanner := newLineScanner(r)
+
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+
+		// Read the header from the front matter written before any file
+		if lineNo == 1 && line == "---" {
+			var front []string
+			for scanner.Scan() {
+				lineNo++
+				if scanner.Text() == "---" {
+					break
+				}
+
+				front = append(front, scanner.Text())
+			}
+
+			var fm frontMatter
+			if err := yaml.Unmarshal([]byte(strings.Join(front, "\n")), &fm); err != nil {
+				return contextHeader{}, nil, fmt.Errorf("line %d: error reading front matter: %w", lineNo, err)
+			}
+
+			header = contextHeader{Root: fm.Root, Expires: fm.Expires, Git: fm.Git}
+			continue
+		}
+
+		// Content lines are always indented, so a bare separator either
+		// opens a new file header or closes the whole context
+		if line == separator {
+			finish()
+
+			if !scanner.Scan() {
+				break
+			}
+			lineNo++
+
+			// The summary written by --with-exclusion-summary and the
+			// totals line end the context
+			if scanner.Text() == exclusionSummaryTitle || strings.HasPrefix(scanner.Text(), footerTitle) {
+				break
+			}
+
+			var found bool
+			path, found = strings.CutPrefix(scanner.Text(), "file: ")
+			if !found {
+				return contextHeader{}, nil, fmt.Errorf("line %d: expected a %q header after the separator", lineNo, "file:")
+			}
+
+			// Read any extra "key: value" lines until the closing separator
+			link, identicalTo, similarTo, similarity, metadata, git = "", "", "", 0, nil, nil
+			for {
+				if !scanner.Scan() {
+					return contextHeader{}, nil, fmt.Errorf("line %d: expected a separator after the header for %q", lineNo+1, path)
+				}
+				lineNo++
+
+				if scanner.Text() == separator {
+					break
+				}
+
+				key, value, found := strings.Cut(scanner.Text(), ": ")
+				if !found || strings.HasPrefix(key, " ") {
+					return contextHeader{}, nil, fmt.Errorf("line %d: expected a separator after the header for %q", lineNo, path)
+				}
+
+				if key == "link" {
+					link = value
+					continue
+				}
+
+				if key == "last commit" {
+					git = parseGitInfoLine(value)
+					continue
+				}
+
+				if key == "identical to" {
+					identicalTo = value
+					continue
+				}
+
+				if key == "similar to" {
+					similarTo = value
+					if i := strings.LastIndex(value, " ("); i >= 0 {
+						similarTo = value[:i]
+						fmt.Sscanf(value[i:], " (%g%% alike)", &similarity)
+						similarity /= 100
+					}
+					continue
+				}
+
+				if m := parseMetadataLine(key, value, metadata); m != nil {
+					metadata = m
+				}
+			}
+
+			started = true
+			continue
+		}
+
+		if !started {
+			// The plain-compact format has nothing telling its headers
+			// apart from the contents of files, so it isn't read back
+			if strings.HasPrefix(line, "== ") && strings.HasSuffix(line, " ==") {
+				return contextHeader{}, nil, fmt.Errorf("line %d: %s contexts can't be read back, generate the context with --format %s, %s or %s instead", lineNo, formatPlainCompact, formatText, formatJSON, formatJSONL)
+			}
+
+			return contextHeader{}, nil, fmt.Errorf("line %d: content found before any file header", lineNo)
+		}
+
+		content, found := strings.CutPrefix(line, "    ")
+		if !found {
+			return contextHeader{}, nil, fmt.Errorf("line %d: content for %q is not indented", lineNo, path)
+		}
+
+		lines = append(lines, content)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return contextHeader{}, nil, err
+	}
+
+	// Contexts cut short, like those of interrupted runs, lack the
+	// closing separator but their last file is still usable
+	finish()
+
+	return header, files, nil
+}
+
+// parseMetadataLine reads a metadata header line written by
+// --with-metadata into m, creating it if needed. It returns nil for keys
+// that aren't metadata.
+func parseMetadataLine(key, value string, m *fileMetadata) *fileMetadata {
+	switch key {
+	case "size", "modified", "lines", "language":
+	default:
+		return nil
+	}
+
+	if m == nil {
+		m = &fileMetadata{}
+	}
+
+	switch key {
+	case "size":
+		// The exact size is given in parentheses after the readable one
+		if _, exact, found := strings.Cut(value, "("); found {
+			fmt.Sscanf(exact, "%d bytes)", &m.Size)
+		}
+	case "modified":
+		m.Modified, _ = time.Parse(time.RFC3339, value)
+	case "lines":
+		m.Lines, _ = strconv.Atoi(value)
+	case "language":
+		m.Language = value
+	}
+
+	return m
+}
+
+// parseGitInfoLine reads the last commit of a file written by
+// --with-git-info, returning nil when it isn't in the expected form
+func parseGitInfoLine(value string) *fileGitInfo {
+	commit, rest, found := strings.Cut(value, " (")
+	if !found {
+		return nil
+	}
+
+	date, author, found := strings.Cut(strings.TrimSuffix(rest, ")"), ", ")
+	if !found {
+		return nil
+	}
+
+	g := &fileGitInfo{Commit: commit, Author: author}
+	g.Date, _ = time.Parse(time.DateOnly, date)
+
+	return g
+}
+
+// contentEntry builds the manifest entry for a file read back from a
+// context, where only its contents are known
+func contentEntry(path, content string) manifestEntry {
+	sum := sha256.Sum256([]byte(content))
+
+	return manifestEntry{
+		Path:   path,
+		Size:   int64(len(content)),
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+}
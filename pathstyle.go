@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Styles the paths in a context can be written in
+const (
+	pathStyleUnix = "unix"
+	pathStyleOS   = "os"
+)
+
+// pathStyleFlag is the value of the --path-style flag. It implements
+// pflag.Value so invalid styles are rejected while parsing flags.
+type pathStyleFlag string
+
+func (p *pathStyleFlag) String() string {
+	if *p == "" {
+		return pathStyleUnix
+	}
+
+	return string(*p)
+}
+
+func (p *pathStyleFlag) Set(value string) error {
+	switch value {
+	case pathStyleUnix, pathStyleOS:
+		*p = pathStyleFlag(value)
+		return nil
+	}
+
+	return fmt.Errorf("must be one of: %s, %s", pathStyleUnix, pathStyleOS)
+}
+
+func (p *pathStyleFlag) Type() string {
+	return "style"
+}
+
+// displayPath returns path as it's written in a context: with forward
+// slashes, so contexts read the same wherever they were generated, or
+// with the separator of the operating system for --path-style os. With
+// --anonymize, it's anonymized too.
+func (o options) displayPath(path string) string {
+	// Files of a workspace root start with its prefix instead
+	if o.rootPrefix != "" {
+		if rel, err := filepath.Rel(o.root, path); err == nil {
+			path = filepath.Join(o.rootPrefix, rel)
+		}
+	}
+
+	if o.pathStyle != pathStyleOS {
+		path = filepath.ToSlash(path)
+	}
+
+	return o.anonymizer.path(path, false)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// filterPluginRequest is what a filter plugin is told about each file,
+// as a single line of JSON
+type filterPluginRequest struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Language string `json:"language,omitempty"`
+	Content  string `json:"content"`
+}
+
+// filterPluginResponse is what a filter plugin answers for each file, as
+// a single line of JSON
+type filterPluginResponse struct {
+	Include bool   `json:"include"`
+	Reason  string `json:"reason"`
+}
+
+// filterPlugin is a long-running process deciding which files to leave
+// out with logic of its own, like an organization policy excluding files
+// with PII markers. It's started once per run with the shell, and then
+// sent a request per file on stdin, answering each one with a response
+// on stdout:
+//
+//	{"path":"src/users.go","size":1234,"language":"go","content":"..."}
+//	{"include":false,"reason":"holds PII markers"}
+//
+// The scanned directory is in CONTEXT_GENERATOR_ROOT.
+type filterPlugin struct {
+	command string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  *bufio.Reader
+}
+
+// startFilterPlugins starts a plugin for every command; the ones started
+// are stopped if any of them fails to start
+func startFilterPlugins(ctx context.Context, root string, commands []string) ([]*filterPlugin, error) {
+	var plugins []*filterPlugin
+	for _, command := range commands {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			stopFilterPlugins(plugins)
+			return nil, fmt.Errorf("filter plugin command is empty")
+		}
+
+		if strings.EqualFold(filepath.Ext(fields[0]), ".wasm") {
+			stopFilterPlugins(plugins)
+			return nil, fmt.Errorf("filter plugin %q: WebAssembly plugins aren't supported, use an executable or a script instead", command)
+		}
+
+		p, err := startFilterPlugin(ctx, root, command)
+		if err != nil {
+			stopFilterPlugins(plugins)
+			return nil, err
+		}
+
+		plugins = append(plugins, p)
+	}
+
+	return plugins, nil
+}
+
+func startFilterPlugin(ctx context.Context, root, command string) (*filterPlugin, error) {
+	cmd := shellCommand(ctx, command)
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "CONTEXT_GENERATOR_ROOT="+root)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error starting filter plugin %q: %w", command, err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error starting filter plugin %q: %w", command, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting filter plugin %q: %w", command, err)
+	}
+
+	return &filterPlugin{command: command, cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}, nil
+}
+
+// decide asks the plugin whether to include the file f, at rel below the
+// scanned directory
+func (p *filterPlugin) decide(rel string, f contextFile) (filterPluginResponse, error) {
+	req, err := json.Marshal(filterPluginRequest{
+		Path:     rel,
+		Size:     f.Size,
+		Language: detectLanguage(f.Path),
+		Content:  f.Content,
+	})
+	if err != nil {
+		return filterPluginResponse{}, fmt.Errorf("error encoding request for filter plugin %q: %w", p.command, err)
+	}
+
+	// Failing to talk to the plugin isn't about the file, so the errors
+	// aren't wrapped, or they'd be skipped like unreadable files
+	if _, err := fmt.Fprintf(p.stdin, "%s\n", req); err != nil {
+		return filterPluginResponse{}, fmt.Errorf("error sending %q to filter plugin %q: %v", f.Path, p.command, err)
+	}
+
+	line, err := p.stdout.ReadBytes('\n')
+	if err != nil {
+		return filterPluginResponse{}, fmt.Errorf("filter plugin %q didn't answer for %q: %v", p.command, f.Path, err)
+	}
+
+	var resp filterPluginResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return filterPluginResponse{}, fmt.Errorf("filter plugin %q answered for %q with invalid JSON: %w", p.command, f.Path, err)
+	}
+
+	return resp, nil
+}
+
+// stop closes the input of the plugin, so it can exit, and waits for it
+func (p *filterPlugin) stop() error {
+	p.stdin.Close()
+	if err := p.cmd.Wait(); err != nil {
+		// The exit code of the plugin isn't ours to exit with, so the
+		// error isn't wrapped
+		return fmt.Errorf("filter plugin %q failed: %v", p.command, err)
+	}
+
+	return nil
+}
+
+// stopFilterPlugins stops every plugin, returning the first error
+func stopFilterPlugins(plugins []*filterPlugin) error {
+	var first error
+	for _, p := range plugins {
+		if err := p.stop(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// filterPluginExclusion returns the key a file left out by the plugin
+// running command is counted under, grouped by the reason it gave
+func filterPluginExclusion(command, reason string) string {
+	if reason == "" {
+		reason = "rejected"
+	}
+
+	return reason + " (--filter-plugin=" + command + ")"
+}
+
+// applyFilterPlugins asks every plugin whether to include the file f,
+// returning the key it's counted under when one of them leaves it out
+func (o options) applyFilterPlugins(f contextFile) (string, error) {
+	rel, err := filepath.Rel(o.root, f.Path)
+	if err != nil {
+		rel = f.Path
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, p := range o.plugins {
+		resp, err := p.decide(rel, f)
+		if err != nil {
+			return "", err
+		}
+
+		if !resp.Include {
+			o.log().Debug("plugin", "path", f.Path, "command", p.command, "reason", resp.Reason)
+			return filterPluginExclusion(p.command, resp.Reason), nil
+		}
+	}
+
+	return "", nil
+}
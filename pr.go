@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// githubFilesPerPage is how many changed files are requested per page,
+// the most the GitHub API allows
+const githubFilesPerPage = 100
+
+// pullRequestRef identifies a pull request on GitHub or GitHub Enterprise
+type pullRequestRef struct {
+	apiURL string
+	owner  string
+	repo   string
+	number int
+}
+
+// parsePullRequestURL reads the address of a pull request, like
+// https://github.com/org/repo/pull/123. The API of GitHub Enterprise
+// hosts is found below /api/v3, and GITHUB_API_URL, as set by GitHub
+// Actions, takes precedence over both.
+func parsePullRequestURL(address string) (pullRequestRef, error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return pullRequestRef{}, fmt.Errorf("invalid pull request URL %q", address)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 4 || parts[2] != "pull" {
+		return pullRequestRef{}, fmt.Errorf("invalid pull request URL %q, expected one like https://github.com/org/repo/pull/123", address)
+	}
+
+	number, err := strconv.Atoi(parts[3])
+	if err != nil || number <= 0 {
+		return pullRequestRef{}, fmt.Errorf("invalid pull request number %q in %q", parts[3], address)
+	}
+
+	apiURL := "https://api.github.com"
+	if !strings.EqualFold(u.Hostname(), "github.com") {
+		apiURL = u.Scheme + "://" + u.Host + "/api/v3"
+	}
+
+	if env := os.Getenv("GITHUB_API_URL"); env != "" {
+		apiURL = strings.TrimSuffix(env, "/")
+	}
+
+	return pullRequestRef{apiURL: apiURL, owner: parts[0], repo: parts[1], number: number}, nil
+}
+
+// pullRequest is the part of a GitHub pull request put in a context
+type pullRequest struct {
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	Head struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+}
+
+// pullRequestFile is a file changed by a GitHub pull request, with the
+// patch of its changes; binary and very large files have none
+type pullRequestFile struct {
+	Filename         string `json:"filename"`
+	PreviousFilename string `json:"previous_filename"`
+	Status           string `json:"status"`
+	Patch            string `json:"patch"`
+}
+
+// githubClient calls the GitHub REST API, authenticated with a token
+// when one is given
+type githubClient struct {
+	http  *http.Client
+	token string
+}
+
+// get fetches the API resource at address into v
+func (c *githubClient) get(ctx context.Context, address string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request to %q: %w", address, err)
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling the GitHub API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		// Private repositories look missing to anonymous requests
+		hint := ""
+		if c.token == "" && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden) {
+			hint = "; set GITHUB_TOKEN to access private repositories or avoid rate limits"
+		}
+
+		return fmt.Errorf("GitHub API returned %s for %q: %s%s", resp.Status, address, strings.TrimSpace(string(msg)), hint)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error reading GitHub API response from %q: %w", address, err)
+	}
+
+	return nil
+}
+
+// fetchPullRequest fetches the pull request ref along with every file it
+// changes
+func (c *githubClient) fetchPullRequest(ctx context.Context, ref pullRequestRef) (pullRequest, []pullRequestFile, error) {
+	base := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", ref.apiURL, url.PathEscape(ref.owner), url.PathEscape(ref.repo), ref.number)
+
+	var pr pullRequest
+	if err := c.get(ctx, base, &pr); err != nil {
+		return pullRequest{}, nil, err
+	}
+
+	var files []pullRequestFile
+	for page := 1; ; page++ {
+		var batch []pullRequestFile
+		if err := c.get(ctx, fmt.Sprintf("%s/files?per_page=%d&page=%d", base, githubFilesPerPage, page), &batch); err != nil {
+			return pullRequest{}, nil, err
+		}
+
+		files = append(files, batch...)
+		if len(batch) < githubFilesPerPage {
+			return pr, files, nil
+		}
+	}
+}
+
+// pullRequestContext turns a pull request into the files of a context:
+// its description first, then the patch of every changed file
+func pullRequestContext(pr pullRequest, files []pullRequestFile) []contextFile {
+	var desc strings.Builder
+	fmt.Fprintf(&desc, "# %s\n\n", pr.Title)
+	fmt.Fprintf(&desc, "Opened by @%s to merge %s into %s.\n", pr.User.Login, pr.Head.Ref, pr.Base.Ref)
+	if body := strings.TrimSpace(strings.ReplaceAll(pr.Body, "\r\n", "\n")); body != "" {
+		fmt.Fprintf(&desc, "\n%s\n", body)
+	}
+
+	out := make([]contextFile, 0, len(files)+1)
+	out = append(out, contextFile{
+		manifestEntry: manifestEntry{Path: pr.HTMLURL},
+		Change:        "pull request description",
+		Content:       desc.String(),
+	})
+
+	for _, f := range files {
+		cf := contextFile{manifestEntry: manifestEntry{Path: f.Filename}, Change: f.Status}
+
+		switch f.Status {
+		case "renamed":
+			cf.Change = changeRenamed + f.PreviousFilename
+		case "removed":
+			cf.Change = changeDeleted
+		}
+
+		if cf.Change != changeDeleted {
+			if f.Patch == "" {
+				cf.Change += ", no patch shown for binary or very large files"
+			}
+
+			cf.Diff, cf.Content = f.Patch != "", ensureNewline(f.Patch)
+		}
+
+		out = append(out, cf)
+	}
+
+	return out
+}
+
+// ensureNewline adds a line ending to s, unless it's empty or has one
+func ensureNewline(s string) string {
+	if s == "" || strings.HasSuffix(s, "\n") {
+		return s
+	}
+
+	return s + "\n"
+}
+
+func newPullRequestCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pr url",
+		Short: "Generate a review-ready context of a GitHub pull request, with its description and the diff of every changed file, authenticating with GITHUB_TOKEN when set",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(diffFormats, opts.format) {
+				return fmt.Errorf("pull requests can only be generated with --format %s", strings.Join(diffFormats, ", "))
+			}
+
+			ref, err := parsePullRequestURL(args[0])
+			if err != nil {
+				return err
+			}
+
+			client := &githubClient{http: http.DefaultClient, token: os.Getenv("GITHUB_TOKEN")}
+			pr, files, err := client.fetchPullRequest(cmd.Context(), ref)
+			if err != nil {
+				return err
+			}
+
+			label := fmt.Sprintf("%s/%s#%d", ref.owner, ref.repo, ref.number)
+			cw, err := newContextWriter(opts.format, cmd.OutOrStdout(), contextHeader{Label: label}, nil)
+			if err != nil {
+				return err
+			}
+
+			for _, f := range pullRequestContext(pr, files) {
+				if err := cw.writeFile(f); err != nil {
+					return err
+				}
+			}
+
+			return cw.close()
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.format, "format", formatText, "output format: "+strings.Join(diffFormats, ", "))
+	_ = cmd.RegisterFlagCompletionFunc("format", fixedCompletion(diffFormats...))
+
+	return cmd
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/patrickdappollonio/context-generator/filter"
+	"github.com/spf13/cobra"
+)
+
+// presetCounts is what a preset leaves out of a directory
+type presetCounts struct {
+	files int
+	bytes int64
+}
+
+// countPresetExclusions walks root with the filters in opts plus the
+// named preset, counting the files its patterns leave out, including
+// every file below the folders it excludes
+func countPresetExclusions(ctx context.Context, root, name string, opts filter.Options) (presetCounts, error) {
+	opts.Preset = name
+
+	decisions, err := filter.Simulate(ctx, root, opts)
+	if err != nil {
+		return presetCounts{}, err
+	}
+
+	var counts presetCounts
+	for _, d := range decisions {
+		if d.Included || !filter.PresetExclusion(d.Exclusion, name) {
+			continue
+		}
+
+		if !d.Dir {
+			if info, err := os.Lstat(d.Path); err == nil {
+				counts.files++
+				counts.bytes += info.Size()
+			}
+			continue
+		}
+
+		err := filepath.WalkDir(d.Path, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || !entry.Type().IsRegular() {
+				return nil
+			}
+
+			if info, err := entry.Info(); err == nil {
+				counts.files++
+				counts.bytes += info.Size()
+			}
+
+			return nil
+		})
+		if err != nil {
+			return presetCounts{}, fmt.Errorf("error walking %q: %w", d.Path, err)
+		}
+	}
+
+	return counts, nil
+}
+
+func newListPresetsCommand(opts *options) *cobra.Command {
+	var (
+		withCounts bool
+		dir        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list-presets",
+		Short: "List the presets --preset can exclude, each one including the patterns of the ones before it, and optionally how much of a directory each would leave out",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("dir") && !withCounts {
+				return fmt.Errorf("flag --dir requires --with-counts")
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			if withCounts {
+				fmt.Fprintln(tw, "PRESET\tPATTERNS\tFILES\tSIZE")
+			} else {
+				fmt.Fprintln(tw, "PRESET\tPATTERNS")
+			}
+
+			root := dir
+			if withCounts {
+				var err error
+				if root, err = checkRoot(dir); err != nil {
+					return err
+				}
+
+				if err := opts.restrictWalk(cmd.Context(), root); err != nil {
+					return err
+				}
+			}
+
+			for _, name := range filter.PresetNames() {
+				patterns, err := filter.PresetPatterns(name)
+				if err != nil {
+					return err
+				}
+
+				if !withCounts {
+					fmt.Fprintf(tw, "%s\t%d\n", name, len(patterns))
+					continue
+				}
+
+				// Count on top of the other filters, so the numbers tell
+				// what picking the preset would change
+				counts, err := countPresetExclusions(cmd.Context(), root, name, opts.filters)
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", name, len(patterns), counts.files, humanBytes(counts.bytes))
+			}
+
+			return tw.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&withCounts, "with-counts", false, "also show how many files, and how many bytes, each preset would leave out of the directory")
+	cmd.Flags().StringVar(&dir, "dir", ".", "with --with-counts, the directory to count in")
+
+	return cmd
+}
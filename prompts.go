@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// promptPreset wraps a context into a prompt ready to be pasted, with
+// instructions before it and a question after it
+type promptPreset struct {
+	Description string `yaml:"description"`
+	Preamble    string `yaml:"preamble"`
+	Question    string `yaml:"question"`
+}
+
+// promptPresets holds the built-in prompt presets, which the prompts in
+// the configuration file can replace or add to
+var promptPresets = map[string]promptPreset{
+	"code-review": {
+		Description: "review the code like a senior engineer would review a pull request",
+		Preamble: "You are a senior software engineer reviewing the code below. " +
+			"Look for bugs, unclear naming, missing error handling, security issues and code that doesn't follow the conventions of the rest of the project. " +
+			"Quote the file and the lines each comment refers to, and say how important it is.",
+		Question: "What are the most important changes you would ask for before this code is merged?",
+	},
+	"bug-hunt": {
+		Description: "look for bugs and edge cases that aren't handled",
+		Preamble: "You are debugging the code below. " +
+			"Trace how data flows through it and look for bugs: off-by-one errors, unhandled errors and edge cases, race conditions, resource leaks and wrong assumptions about inputs. " +
+			"Only report problems you can point to in the code, quoting the file and the lines involved.",
+		Question: "Which bugs did you find, and how would you fix each of them?",
+	},
+	"docs": {
+		Description: "write documentation for the code",
+		Preamble: "You are a technical writer documenting the code below for developers new to the project. " +
+			"Explain what it does, how it's organized and how its main pieces fit together, using the names found in the code.",
+		Question: "Write a README for this project, with an overview, how to use it and how the code is organized.",
+	},
+}
+
+// lookupPrompt returns the prompt preset with the given name, looking at
+// the ones in the configuration file before the built-in ones
+func lookupPrompt(name string, configured map[string]promptPreset) (promptPreset, error) {
+	if p, found := configured[name]; found {
+		return p, nil
+	}
+
+	if p, found := promptPresets[name]; found {
+		return p, nil
+	}
+
+	return promptPreset{}, fmt.Errorf("unknown prompt preset %q, must be one of: %s", name, strings.Join(promptNames(configured), ", "))
+}
+
+// promptNames returns the names of every prompt preset, sorted
+func promptNames(configured map[string]promptPreset) []string {
+	names := make([]string, 0, len(promptPresets)+len(configured))
+	for name := range promptPresets {
+		names = append(names, name)
+	}
+
+	for name := range configured {
+		if _, found := promptPresets[name]; !found {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// writePreamble writes the instructions that go before the context
+func (p *promptPreset) writePreamble(w io.Writer) error {
+	if p == nil || p.Preamble == "" {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(p.Preamble))
+	return err
+}
+
+// writeQuestion writes the question that goes after the context
+func (p *promptPreset) writeQuestion(w io.Writer) error {
+	if p == nil || p.Question == "" {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(p.Question))
+	return err
+}
+
+func newListPromptsCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list-prompts",
+		Short: "List the prompt presets --prompt-preset can wrap a context with, including the ones in the configuration file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := checkRoot(opts.root)
+			if err != nil {
+				return err
+			}
+
+			cfg, err := loadConfig(opts.configPath, root)
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			for _, name := range promptNames(cfg.Prompts) {
+				p, _ := lookupPrompt(name, cfg.Prompts)
+
+				source := "built-in"
+				if _, found := cfg.Prompts[name]; found {
+					source = "config"
+				}
+
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", name, source, p.Description)
+			}
+
+			return tw.Flush()
+		},
+	}
+}
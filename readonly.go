@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeTarget is a location a run may write to
+type writeTarget struct {
+	what string
+	path string
+}
+
+// writeTargets lists every location a run with opts may write to, other
+// than standard output. Any new output written by a run must be listed
+// here for --assert-read-only to keep its promise.
+func (o options) writeTargets() []writeTarget {
+	var targets []writeTarget
+
+	if o.checkpointPath != "" {
+		targets = append(targets, writeTarget{"checkpoint", o.checkpointPath})
+	}
+
+	if o.summaryPath != "" {
+		targets = append(targets, writeTarget{"summary", o.summaryPath})
+	}
+
+	for _, path := range o.outputs {
+		targets = append(targets, writeTarget{"output", path})
+	}
+
+	if o.manifest {
+		targets = append(targets, writeTarget{"manifest", manifestPath(o.outputs)})
+	}
+
+	if o.vault != "" {
+		targets = append(targets, writeTarget{"vault", o.vault})
+	}
+
+	if !o.noIndex || o.useCache {
+		if dir, err := cacheDir(); err == nil {
+			targets = append(targets, writeTarget{"state directory", dir})
+		}
+	}
+
+	if o.frontMatter || !o.noIndex {
+		targets = append(targets, writeTarget{"temporary directory", os.TempDir()})
+	}
+
+	return targets
+}
+
+// checkReadOnly makes sure, when --assert-read-only is set, that nothing
+// inside the scan root will be written to, and that no external command
+// which could write to it will be run
+func (o options) checkReadOnly() error {
+	if !o.assertReadOnly {
+		return nil
+	}
+
+	for _, target := range o.writeTargets() {
+		inside, err := insideDir(o.root, target.path)
+		if err != nil {
+			return err
+		}
+
+		if inside {
+			return fmt.Errorf("flag --assert-read-only is set but the %s %q is inside the scan root %q", target.what, target.path, o.root)
+		}
+	}
+
+	// Hooks only run when generating a context
+	if o.hookResult != nil && len(o.hookConfig.Pre)+len(o.hookConfig.Post) > 0 {
+		return fmt.Errorf("flag --assert-read-only is set but the config file has hooks, which could write to the scan root; skip them with --no-hooks")
+	}
+
+	for _, v := range o.validators {
+		if v.command != "" {
+			return fmt.Errorf("flag --assert-read-only is set but validator %q runs a command, which could write to the scan root", v.name)
+		}
+	}
+
+	// Flags running commands on the files could write to it too
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"transform-cmd", len(o.transformCmds) > 0},
+		{"filter-plugin", len(o.filterPlugins) > 0},
+		{"caption-cmd", o.captionCmd != ""},
+	} {
+		if f.set {
+			return fmt.Errorf("flag --assert-read-only is set but --%s runs a command, which could write to the scan root", f.name)
+		}
+	}
+
+	return nil
+}
+
+// checkNotOutput fails when the file at path, with info, is where
+// standard output is being written to, like when the output of a run is
+// redirected to a file inside the scan root
+func checkNotOutput(path string, info os.FileInfo, stdout os.FileInfo) error {
+	if stdout != nil && stdout.Mode().IsRegular() && os.SameFile(info, stdout) {
+		return fmt.Errorf("flag --assert-read-only is set but the output is being written to %q, inside the scan root", path)
+	}
+
+	return nil
+}
+
+// insideDir reports whether path is dir or is below it, following the
+// symbolic links of the parts of both that exist
+func insideDir(dir, path string) (bool, error) {
+	dir, err := resolvePath(dir)
+	if err != nil {
+		return false, err
+	}
+
+	path, err = resolvePath(path)
+	if err != nil {
+		return false, err
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false, nil
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
+
+// resolvePath returns the absolute form of path, with symbolic links
+// resolved in its longest existing prefix
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("error resolving path %q: %w", path, err)
+	}
+
+	var rest []string
+	for current := abs; ; current = filepath.Dir(current) {
+		if resolved, err := filepath.EvalSymlinks(current); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+
+		if current == filepath.Dir(current) {
+			return abs, nil
+		}
+
+		rest = append([]string{filepath.Base(current)}, rest...)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/patrickdappollonio/context-generator/filter"
+)
+
+// summaryTopDirs is how many directories the Markdown summary lists
+const summaryTopDirs = 10
+
+// scanSummary collects what --summary-md reports about a run: what was
+// included, where the tokens went and what went wrong. It holds nothing
+// that changes between identical runs, so summaries can be diffed. A nil
+// summary is valid and records nothing.
+type scanSummary struct {
+	path     string
+	files    int
+	size     int64
+	dirs     map[string]*dirStat
+	warnings []string
+}
+
+// dirStat aggregates the included files of a single directory
+type dirStat struct {
+	dir   string
+	files int
+	size  int64
+}
+
+// newScanSummary returns a summary to be written to path, or only kept
+// in memory when path is empty
+func newScanSummary(path string) *scanSummary {
+	return &scanSummary{path: path, dirs: make(map[string]*dirStat)}
+}
+
+// add counts an included file, grouped under the top-level directory
+// holding it below root
+func (s *scanSummary) add(root string, f contextFile) {
+	if s == nil {
+		return
+	}
+
+	dir := "."
+	if rel, err := filepath.Rel(root, f.Path); err == nil {
+		if top, _, found := strings.Cut(filepath.ToSlash(rel), "/"); found {
+			dir = top + "/"
+		}
+	}
+
+	g, found := s.dirs[dir]
+	if !found {
+		g = &dirStat{dir: dir}
+		s.dirs[dir] = g
+	}
+
+	size := int64(len(f.Content))
+	g.files++
+	g.size += size
+	s.files++
+	s.size += size
+}
+
+// warn records a warning printed during the run
+func (s *scanSummary) warn(msg string) {
+	if s == nil {
+		return
+	}
+
+	s.warnings = append(s.warnings, msg)
+}
+
+// write writes the summary as Markdown, meant to be posted as a comment
+// by bots running the tool, along with what the exclusions left out
+func (s *scanSummary) write(excluded filter.Exclusions) error {
+	if s == nil || s.path == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "## Context summary")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "| Files | Size | Estimated tokens |")
+	fmt.Fprintln(&buf, "| ---: | ---: | ---: |")
+	fmt.Fprintf(&buf, "| %d | %s | %d |\n", s.files, humanBytes(s.size), estimateTokens(s.size))
+
+	dirs := make([]dirStat, 0, len(s.dirs))
+	for _, d := range s.dirs {
+		dirs = append(dirs, *d)
+	}
+
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].size != dirs[j].size {
+			return dirs[i].size > dirs[j].size
+		}
+
+		return dirs[i].dir < dirs[j].dir
+	})
+
+	if len(dirs) > 0 {
+		fmt.Fprintln(&buf)
+		fmt.Fprintln(&buf, "### Top directories by tokens")
+		fmt.Fprintln(&buf)
+		fmt.Fprintln(&buf, "| Directory | Files | Estimated tokens |")
+		fmt.Fprintln(&buf, "| --- | ---: | ---: |")
+
+		for _, d := range dirs[:min(len(dirs), summaryTopDirs)] {
+			fmt.Fprintf(&buf, "| `%s` | %d | %d |\n", d.dir, d.files, estimateTokens(d.size))
+		}
+	}
+
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "### Excluded")
+	fmt.Fprintln(&buf)
+	writeExclusionList(&buf, excluded)
+
+	if len(s.warnings) > 0 {
+		fmt.Fprintln(&buf)
+		fmt.Fprintln(&buf, "### Warnings")
+		fmt.Fprintln(&buf)
+
+		for _, w := range s.warnings {
+			fmt.Fprintf(&buf, "- %s\n", w)
+		}
+	}
+
+	if err := os.WriteFile(s.path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("error writing summary %q: %w", s.path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/patrickdappollonio/context-generator/internal/archive"
+)
+
+// useRevision makes the run read the files below the directory as they
+// are in the git revision rev, without checking it out. The tree of the
+// revision is read into memory, like an archive is.
+func (o *options) useRevision(ctx context.Context, rev string) error {
+	root, err := checkRoot(o.root)
+	if err != nil {
+		return err
+	}
+
+	commit, err := gitOutput(ctx, root, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return fmt.Errorf("unknown git revision %q in %q", rev, root)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "-C", root, "archive", "--format=tar", commit)
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("error reading git revision %q: %w", rev, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error reading git revision %q: %w", rev, err)
+	}
+
+	// Not wrapped, so the exit code of git isn't taken for one of ours
+	fsys, readErr := archive.ReadTar(stdout)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("error reading git revision %q: %v: %s", rev, err, strings.TrimSpace(stderr.String()))
+	}
+
+	if readErr != nil {
+		return fmt.Errorf("error reading git revision %q: %w", rev, readErr)
+	}
+
+	o.rev = commit
+	o.filters.FS = fsys
+
+	// The cache tells files apart by their state on disk, which says
+	// nothing about their contents in another revision
+	o.useCache = false
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/spf13/cobra"
+)
+
+// searchOptions holds the settings for the search subcommand
+type searchOptions struct {
+	context    string
+	ignoreCase bool
+	filesOnly  bool
+}
+
+// openSearchContext opens the context given to --context or, when none
+// was given, the index saved by the last run, returning the name it goes
+// by in messages
+func openSearchContext(path, backend string) (io.ReadCloser, string, error) {
+	if path == "" {
+		r, err := readIndex(backend)
+		return r, indexKey, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, "", fmt.Errorf("context %q does not exist", path)
+		}
+
+		return nil, "", fmt.Errorf("error opening context %q: %w", path, err)
+	}
+
+	return f, path, nil
+}
+
+// searchContext prints the lines of files in the context read from r
+// that match the regular expression, and reports whether anything
+// matched. The context goes by name in messages.
+func searchContext(name string, r io.Reader, re *regexp.Regexp, filesOnly bool, w io.Writer) (bool, error) {
+	header, files, err := parseContext(r)
+	if err != nil {
+		return false, fmt.Errorf("error parsing context %q: %w", name, err)
+	}
+	warnExpired(name, header)
+
+	matched := false
+	for _, file := range files {
+		lineNo := 0
+		found := false
+
+		eachLine(file.Content, func(line string) {
+			lineNo++
+
+			if (filesOnly && found) || !re.MatchString(line) {
+				return
+			}
+
+			found = true
+			if !filesOnly {
+				fmt.Fprintf(w, "%s:%d: %s\n", file.Path, lineNo, line)
+			}
+		})
+
+		if found && filesOnly {
+			fmt.Fprintln(w, file.Path)
+		}
+
+		matched = matched || found
+	}
+
+	return matched, nil
+}
+
+func newSearchCommand(rootOpts *options) *cobra.Command {
+	var opts searchOptions
+
+	cmd := &cobra.Command{
+		Use:   "search regex",
+		Short: "Search the context generated by the last run, or the one given to --context",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			expr := args[0]
+			if opts.ignoreCase {
+				expr = "(?i)" + expr
+			}
+
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return fmt.Errorf("invalid regular expression %q: %w", args[0], err)
+			}
+
+			// Default to the index saved by the last run
+			r, name, err := openSearchContext(opts.context, rootOpts.store)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+
+			matched, err := searchContext(name, r, re, opts.filesOnly, cmd.OutOrStdout())
+			if err != nil {
+				return err
+			}
+
+			if !matched {
+				return fmt.Errorf("no matches found for %q", args[0])
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.context, "context", "", "search this generated context, in text, JSON or JSON Lines format, instead of the one from the last run")
+	cmd.Flags().BoolVarP(&opts.ignoreCase, "ignore-case", "i", false, "match case-insensitively")
+	cmd.Flags().BoolVarP(&opts.filesOnly, "files-with-matches", "l", false, "only print the paths of the files that matched")
+
+	return cmd
+}
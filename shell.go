@@ -0,0 +1,17 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+)
+
+// shellCommand prepares command to be run by the system shell, so users
+// can write hooks the same way they would in a terminal
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// outputSinks holds every destination a context is written to at once:
+// standard output, files and the clipboard
+type outputSinks struct {
+	writers   []io.Writer
+	files     []*os.File
+	clipboard *bytes.Buffer
+
+	// upload holds the context to upload with --upload once complete
+	upload *bytes.Buffer
+
+	// created lists the output files this run created or emptied, as
+	// opposed to appended to
+	created []string
+}
+
+// openSinks opens the destinations selected in opts, creating or, with
+// --append, appending to the output files
+func openSinks(opts options, stdout io.Writer) (*outputSinks, error) {
+	s := &outputSinks{}
+
+	if opts.stdout {
+		s.writers = append(s.writers, stdout)
+	}
+
+	for _, path := range opts.outputs {
+		w, err := s.openFile(path, opts)
+		if err != nil {
+			s.close(context.Background(), false)
+			return nil, err
+		}
+
+		s.writers = append(s.writers, w)
+	}
+
+	if opts.clipboard {
+		s.clipboard = &bytes.Buffer{}
+		s.writers = append(s.writers, s.clipboard)
+	}
+
+	if opts.upload != "" {
+		s.upload = &bytes.Buffer{}
+		s.writers = append(s.writers, s.upload)
+	}
+
+	// Hashing a context takes stdout over, and it doesn't need to be
+	// written anywhere else
+	if len(s.writers) == 0 && opts.hash != nil {
+		s.writers = append(s.writers, io.Discard)
+	}
+
+	if len(s.writers) == 0 {
+		return nil, fmt.Errorf("flag --stdout=false needs another output, like --output or --clipboard")
+	}
+
+	return s, nil
+}
+
+// openFile opens the output file at path, returning the writer for it
+func (s *outputSinks) openFile(path string, opts options) (io.Writer, error) {
+	// A resumed run continues the output of the run it resumes. Files
+	// appended to are read too, to check how they end.
+	appending := opts.appendOutput || opts.resume
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appending {
+		flags = os.O_RDWR | os.O_CREATE | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening output file %q: %w", path, err)
+	}
+	s.files = append(s.files, f)
+
+	if !appending {
+		s.created = append(s.created, path)
+	}
+
+	// The closing line of a text context appended to doubles as the
+	// opening line of the first file appended after it
+	sep := opts.layout.separator()
+	if appending && (opts.format == formatText || opts.format == "") && sep != "" && endsWith(f, sep+"\n") {
+		return &prefixSkipper{w: f, prefix: []byte(sep + "\n")}, nil
+	}
+
+	return f, nil
+}
+
+// writer returns the writer sending everything to every sink
+func (s *outputSinks) writer() io.Writer {
+	if len(s.writers) == 1 {
+		return s.writers[0]
+	}
+
+	return io.MultiWriter(s.writers...)
+}
+
+// close closes the output files and, when the context is complete,
+// copies it to the clipboard
+func (s *outputSinks) close(ctx context.Context, complete bool) error {
+	var errs []error
+	for _, f := range s.files {
+		if err := f.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("error closing output file %q: %w", f.Name(), err))
+		}
+	}
+
+	if s.clipboard != nil && complete {
+		if err := copyToClipboard(ctx, s.clipboard); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// discard removes the output files the run created, once closed, so an
+// interrupted run doesn't leave partial contexts behind. Files appended
+// to are kept since they held earlier contexts.
+func (s *outputSinks) discard() {
+	for _, path := range s.created {
+		os.Remove(path)
+	}
+}
+
+// endsWith reports whether the file f ends with suffix
+func endsWith(f *os.File, suffix string) bool {
+	info, err := f.Stat()
+	if err != nil || info.Size() < int64(len(suffix)) {
+		return false
+	}
+
+	buf := make([]byte, len(suffix))
+	if _, err := f.ReadAt(buf, info.Size()-int64(len(suffix))); err != nil {
+		return false
+	}
+
+	return string(buf) == suffix
+}
+
+// prefixSkipper drops prefix from the start of what's written through
+// it, if that's how it starts
+type prefixSkipper struct {
+	w       io.Writer
+	prefix  []byte
+	checked int
+	done    bool
+}
+
+func (p *prefixSkipper) Write(b []byte) (int, error) {
+	if p.done {
+		return p.w.Write(b)
+	}
+
+	// Hold back the bytes matching the prefix until it's complete
+	n := 0
+	for n < len(b) && p.checked < len(p.prefix) && b[n] == p.prefix[p.checked] {
+		n++
+		p.checked++
+	}
+
+	if p.checked == len(p.prefix) {
+		p.done = true
+		_, err := p.w.Write(b[n:])
+		return len(b), err
+	}
+
+	if n == len(b) {
+		return len(b), nil
+	}
+
+	// It didn't start with the prefix after all, so write what was held
+	p.done = true
+	if _, err := p.w.Write(p.prefix[:p.checked-n]); err != nil {
+		return 0, err
+	}
+
+	_, err := p.w.Write(b)
+	return len(b), err
+}
+
+// clipboardCommands lists the commands copying their input to the
+// clipboard on each platform, in order of preference
+var clipboardCommands = map[string][][]string{
+	"darwin":  {{"pbcopy"}},
+	"windows": {{"clip.exe"}},
+	"linux":   {{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}},
+}
+
+// copyToClipboard copies the contents of r to the system clipboard
+func copyToClipboard(ctx context.Context, r io.Reader) error {
+	for _, args := range clipboardCommands[runtime.GOOS] {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdin = r
+		cmd.Stderr = &stderr
+
+		// Not wrapped, so the exit code of the clipboard command isn't
+		// taken for one of ours
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("error copying to the clipboard with %s: %v: %s", args[0], err, bytes.TrimSpace(stderr.Bytes()))
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unable to copy to the clipboard: no clipboard command found for %s", runtime.GOOS)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// fileStat holds the size information of a single file that would be
+// included in a context
+type fileStat struct {
+	path     string
+	language string
+	size     int64
+
+	// lines is only counted when the contents are read
+	lines int
+}
+
+// languageStat aggregates the files of a single language
+type languageStat struct {
+	language string
+	files    int
+	lines    int
+	size     int64
+}
+
+// contextStats summarizes the files a context would contain
+type contextStats struct {
+	files []fileStat
+	size  int64
+}
+
+// collectStats walks the directory, or every root of the workspace,
+// with the filters in opts and gathers the size of every file that would
+// be emitted, without reading their contents
+func collectStats(ctx context.Context, opts options) (*contextStats, error) {
+	if err := opts.loadRoot(); err != nil {
+		return nil, err
+	}
+
+	roots, err := opts.walkRoots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &contextStats{}
+	for _, ro := range roots {
+		if err := stats.collect(ctx, ro); err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
+
+// collect adds the files of the directory opts walks to the stats
+func (s *contextStats) collect(ctx context.Context, opts options) error {
+	root := opts.root
+
+	var skipped skippedFiles
+	report, err := walkFiles(ctx, root, opts, func(path string, info os.FileInfo) error {
+		// Only text files end up in the context
+		encoding, err := fileEncoding(root, path, opts)
+		if err != nil {
+			return skipped.skip(opts, path, err)
+		}
+
+		if encoding == "" {
+			return nil
+		}
+
+		if !opts.includeGenerated {
+			heuristic, err := sniffGenerated(root, path, opts)
+			if err != nil {
+				return skipped.skip(opts, path, err)
+			}
+
+			if heuristic != "" {
+				return nil
+			}
+		}
+
+		if opts.maxLines > 0 {
+			lines, err := countFileLines(root, path, opts)
+			if err != nil {
+				return skipped.skip(opts, path, err)
+			}
+
+			if opts.overMaxLines(lines) {
+				return nil
+			}
+		}
+
+		s.files = append(s.files, fileStat{
+			path:     opts.displayPath(path),
+			language: detectLanguage(path),
+			size:     info.Size(),
+		})
+		s.size += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	warnUnmatched(opts, report.Excluded)
+	warnSkipped(opts, report.Skipped, skipped)
+	return nil
+}
+
+// byLanguage groups the files by language, largest first
+func (s *contextStats) byLanguage() []languageStat {
+	groups := make(map[string]*languageStat)
+
+	for _, f := range s.files {
+		g, found := groups[f.language]
+		if !found {
+			g = &languageStat{language: f.language}
+			groups[f.language] = g
+		}
+
+		g.files++
+		g.lines += f.lines
+		g.size += f.size
+	}
+
+	langs := make([]languageStat, 0, len(groups))
+	for _, g := range groups {
+		langs = append(langs, *g)
+	}
+
+	sort.Slice(langs, func(i, j int) bool {
+		if langs[i].size != langs[j].size {
+			return langs[i].size > langs[j].size
+		}
+
+		return langs[i].language < langs[j].language
+	})
+
+	return langs
+}
+
+// largest returns up to n files sorted by size, largest first
+func (s *contextStats) largest(n int) []fileStat {
+	files := make([]fileStat, len(s.files))
+	copy(files, s.files)
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].size > files[j].size
+	})
+
+	if n >= 0 && len(files) > n {
+		files = files[:n]
+	}
+
+	return files
+}
+
+// print writes a human-readable report of the stats to w
+func (s *contextStats) print(w io.Writer, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Files:\t%d\n", len(s.files))
+	fmt.Fprintf(tw, "Total size:\t%s\n", humanBytes(s.size))
+	fmt.Fprintf(tw, "Estimated tokens:\t%d\n", estimateTokens(s.size))
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "LANGUAGE\tFILES\tSIZE\tTOKENS")
+	for _, l := range s.byLanguage() {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\n", l.language, l.files, humanBytes(l.size), estimateTokens(l.size))
+	}
+
+	if top > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "LARGEST FILES\tSIZE\tTOKENS")
+		for _, f := range s.largest(top) {
+			fmt.Fprintf(tw, "%s\t%s\t%d\n", f.path, humanBytes(f.size), estimateTokens(f.size))
+		}
+	}
+
+	return tw.Flush()
+}
+
+// printLanguages writes the composition of the stats by language, with
+// the line counts of the files, to w
+func (s *contextStats) printLanguages(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	lines := 0
+	fmt.Fprintln(tw, "LANGUAGE\tFILES\tLINES\tSIZE\tTOKENS")
+	for _, l := range s.byLanguage() {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%d\n", l.language, l.files, l.lines, humanBytes(l.size), estimateTokens(l.size))
+		lines += l.lines
+	}
+	fmt.Fprintf(tw, "Total\t%d\t%d\t%s\t%d\n", len(s.files), lines, humanBytes(s.size), estimateTokens(s.size))
+
+	return tw.Flush()
+}
+
+// countFileLines counts the lines of the file at path, below root,
+// without holding all of it in memory
+func countFileLines(root, path string, opts options) (int, error) {
+	file, err := opts.filters.Open(root, path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var (
+		buf   = make([]byte, 32*1024)
+		lines int
+		last  byte
+	)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			lines += bytes.Count(buf[:n], []byte("\n"))
+			last = buf[n-1]
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("error reading file %q: %w", path, err)
+		}
+	}
+
+	// A last line without a line break still counts
+	if last != 0 && last != '\n' {
+		lines++
+	}
+
+	return lines, nil
+}
+
+// humanBytes formats a byte count using binary units
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func newStatsCommand(opts *options) *cobra.Command {
+	var top int
+
+	cmd := &cobra.Command{
+		Use:   "stats [directory]",
+		Short: "Summarize the files a context would contain without emitting their contents",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Use the directory given as an argument, if any
+			if len(args) == 1 {
+				opts.root = args[0]
+			}
+
+			stats, err := collectStats(cmd.Context(), *opts)
+			if err != nil {
+				return err
+			}
+
+			if err := stats.print(cmd.OutOrStdout(), top); err != nil {
+				return err
+			}
+
+			return checkBudget(estimateTokens(stats.size), opts.failOverTokens)
+		},
+	}
+
+	cmd.Flags().IntVar(&top, "top", 10, "number of largest files to list")
+
+	return cmd
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter is the YAML summary block written before the context by
+// --front-matter, recording how the context was produced
+type frontMatter struct {
+	Project   string            `yaml:"project"`
+	Generated string            `yaml:"generated"`
+	Root      string            `yaml:"root"`
+	Files     int               `yaml:"files"`
+	Tokens    int64             `yaml:"tokens"`
+	Expires   *time.Time        `yaml:"expires,omitempty"`
+	Git       *gitMetadata      `yaml:"git,omitempty"`
+	Settings  frontMatterFilter `yaml:"settings"`
+
+	// bytes counts the contents of the files, which Tokens is estimated
+	// from as a whole
+	bytes int64
+}
+
+// frontMatterFilter holds the settings that decide what the context
+// holds, enough to produce it again, in the front matter and manifest
+type frontMatterFilter struct {
+	Format         string   `json:"format" yaml:"format"`
+	ExcludeFolders []string `json:"exclude-folder" yaml:"exclude-folder"`
+	ExcludeFiles   []string `json:"exclude-file" yaml:"exclude-file"`
+	Exclude        []string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+	Globs          []string `json:"globs,omitempty" yaml:"globs,omitempty"`
+	Preset         string   `json:"preset,omitempty" yaml:"preset,omitempty"`
+	MaxDepth       int      `json:"max-depth,omitempty" yaml:"max-depth,omitempty"`
+	MaxFileSize    string   `json:"max-file-size,omitempty" yaml:"max-file-size,omitempty"`
+	MaxLines       int      `json:"max-lines,omitempty" yaml:"max-lines,omitempty"`
+	SampleLarge    string   `json:"sample-large-files,omitempty" yaml:"sample-large-files,omitempty"`
+	DataRows       int      `json:"data-rows,omitempty" yaml:"data-rows,omitempty"`
+	SummarizeOver  string   `json:"summarize-config-files,omitempty" yaml:"summarize-config-files,omitempty"`
+	MaxLineLength  int      `json:"max-line-length,omitempty" yaml:"max-line-length,omitempty"`
+	ReadmeFirst    bool     `json:"readme-first,omitempty" yaml:"readme-first,omitempty"`
+	TrackedOnly    bool     `json:"tracked-only,omitempty" yaml:"tracked-only,omitempty"`
+	FollowImports  []string `json:"follow-imports,omitempty" yaml:"follow-imports,omitempty"`
+	Entries        []string `json:"entry,omitempty" yaml:"entry,omitempty"`
+	CodeOwners     string   `json:"codeowners,omitempty" yaml:"codeowners,omitempty"`
+	Anonymize      bool     `json:"anonymize,omitempty" yaml:"anonymize,omitempty"`
+	Tests          string   `json:"tests" yaml:"tests"`
+	Hidden         string   `json:"hidden,omitempty" yaml:"hidden,omitempty"`
+	Shard          string   `json:"shard,omitempty" yaml:"shard,omitempty"`
+	Sample         float64  `json:"sample,omitempty" yaml:"sample,omitempty"`
+	Seed           uint64   `json:"seed,omitempty" yaml:"seed,omitempty"`
+	ContextIgnore  bool     `json:"contextignore" yaml:"contextignore"`
+	Transcode      bool     `json:"transcode" yaml:"transcode"`
+	Dedupe         bool     `json:"dedupe,omitempty" yaml:"dedupe,omitempty"`
+	Similarity     float64  `json:"collapse-similar,omitempty" yaml:"collapse-similar,omitempty"`
+	Generated      bool     `json:"include-generated,omitempty" yaml:"include-generated,omitempty"`
+	StripComments  bool     `json:"strip-comments,omitempty" yaml:"strip-comments,omitempty"`
+	Compact        bool     `json:"compact,omitempty" yaml:"compact,omitempty"`
+	SignaturesOnly bool     `json:"signatures-only,omitempty" yaml:"signatures-only,omitempty"`
+	Minify         []string `json:"minify,omitempty" yaml:"minify,omitempty"`
+	TransformCmds  []string `json:"transform-cmd,omitempty" yaml:"transform-cmd,omitempty"`
+	FilterPlugins  []string `json:"filter-plugin,omitempty" yaml:"filter-plugin,omitempty"`
+	CaptionCmd     string   `json:"caption-cmd,omitempty" yaml:"caption-cmd,omitempty"`
+	Config         string   `json:"config,omitempty" yaml:"config,omitempty"`
+	Roots          []string `json:"roots,omitempty" yaml:"roots,omitempty"`
+}
+
+// newFrontMatter returns the summary of a context generated with opts
+func newFrontMatter(opts options, generated time.Time) *frontMatter {
+	project := opts.label
+	if project == "" {
+		if abs, err := filepath.Abs(opts.root); err == nil {
+			project = opts.anonymizer.path(filepath.Base(abs), true)
+		}
+	}
+
+	format := opts.format
+	if format == "" {
+		format = formatText
+	}
+
+	// The similarity threshold only matters when collapsing is enabled
+	var similarity float64
+	if opts.collapseSimilar {
+		similarity = opts.similarity
+	}
+
+	// Config files are only summarized over the size
+	var summarizeOver string
+	if opts.summarizeConfig {
+		summarizeOver = "over " + opts.summarizeOver.String()
+	}
+
+	// Workspaces list the roots they were made of
+	var roots []string
+	for _, r := range opts.workspace {
+		roots = append(roots, opts.anonymizer.path(r.Path, true))
+	}
+
+	return &frontMatter{
+		Project:   project,
+		Generated: generated.UTC().Format(time.RFC3339),
+		Root:      opts.anonymizer.path(opts.root, true),
+		Settings: frontMatterFilter{
+			Format:         format,
+			ExcludeFolders: opts.filters.ExcludeFolders,
+			ExcludeFiles:   opts.filters.ExcludeFiles,
+			Exclude:        opts.filters.Exclude,
+			Globs:          opts.filters.Globs,
+			Preset:         opts.filters.Preset,
+			MaxDepth:       opts.filters.MaxDepth,
+			MaxFileSize:    opts.filters.MaxFileSize.String(),
+			MaxLines:       opts.maxLines,
+			SampleLarge:    opts.largeSample.String(),
+			DataRows:       opts.dataRows,
+			SummarizeOver:  summarizeOver,
+			MaxLineLength:  opts.maxLineLength,
+			ReadmeFirst:    opts.filters.ReadmeFirst,
+			TrackedOnly:    opts.trackedOnly,
+			FollowImports:  opts.followImports,
+			Entries:        opts.entries,
+			CodeOwners:     opts.filters.Owner,
+			Anonymize:      opts.anonymize,
+			Tests:          opts.filters.Tests.String(),
+			Hidden:         string(opts.filters.Hidden),
+			Shard:          opts.filters.Shard.String(),
+			Sample:         opts.filters.Sample.Fraction,
+			Seed:           opts.filters.Sample.Seed,
+			ContextIgnore:  !opts.filters.NoContextIgnore,
+			Transcode:      opts.transcode,
+			Dedupe:         opts.dedupe,
+			Similarity:     similarity,
+			Generated:      opts.includeGenerated,
+			StripComments:  opts.stripComments,
+			Compact:        opts.compact,
+			SignaturesOnly: opts.signaturesOnly,
+			Minify:         opts.minify.Strings(),
+			TransformCmds:  opts.transformCmds,
+			FilterPlugins:  opts.filterPlugins,
+			CaptionCmd:     opts.captionCmd,
+			Roots:          roots,
+			Config:         opts.configPath,
+		},
+	}
+}
+
+// add counts an included file in the summary
+func (s *frontMatter) add(f contextFile) {
+	if s == nil {
+		return
+	}
+
+	s.Files++
+	s.bytes += int64(len(f.Content))
+	s.Tokens = estimateTokens(s.bytes)
+}
+
+// write writes the summary as a YAML block enclosed in "---" lines
+func (s *frontMatter) write(w io.Writer) error {
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(s); err != nil {
+		return fmt.Errorf("error encoding front matter: %w", err)
+	}
+
+	_, err := fmt.Fprintf(w, "---\n%s---\n", buf.Bytes())
+	return err
+}
+
+// writeHeaderFrontMatter writes front matter holding only what the
+// header records, like the expiry and the git checkout, for contexts
+// generated without --front-matter
+func writeHeaderFrontMatter(w io.Writer, header contextHeader) error {
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(frontMatterHeader{Expires: header.Expires, Git: header.Git}); err != nil {
+		return fmt.Errorf("error encoding front matter: %w", err)
+	}
+
+	_, err := fmt.Fprintf(w, "---\n%s---\n", buf.Bytes())
+	return err
+}
+
+// frontMatterHeader is the front matter written by writeHeaderFrontMatter
+type frontMatterHeader struct {
+	Expires *time.Time   `yaml:"expires,omitempty"`
+	Git     *gitMetadata `yaml:"git,omitempty"`
+}
+
+// spoolFile holds the output while the context is generated, for it to
+// be written after something only known at the end, like the summary
+type spoolFile struct {
+	*os.File
+}
+
+// newSpoolFile creates an empty temporary spool file
+func newSpoolFile() (*spoolFile, error) {
+	f, err := os.CreateTemp("", "context-generator-*.spool")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary file: %w", err)
+	}
+
+	return &spoolFile{File: f}, nil
+}
+
+// copyTo writes everything written to the spool file so far to w
+func (s *spoolFile) copyTo(w io.Writer) error {
+	if _, err := s.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(w, s.File)
+	return err
+}
+
+// remove closes and deletes the spool file
+func (s *spoolFile) remove() {
+	s.Close()
+	os.Remove(s.Name())
+}
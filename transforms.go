@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/patrickdappollonio/context-generator/internal/transform"
+)
+
+// transforms reports whether any content transformation is enabled
+func (o options) transforms() bool {
+	return o.stripComments || o.compact || o.signaturesOnly || len(o.minify) > 0 || len(o.filters.Transformers) > 0
+}
+
+// transformContent applies the enabled transformations to the content of
+// the file at path
+func transformContent(path string, content []byte, opts options) []byte {
+	// Registered transformers, like formatters, expect the file as it
+	// was written
+	for _, t := range opts.filters.Transformers {
+		content, _ = t.Transform(path, content)
+	}
+
+	// Signatures come first since they rely on the file still parsing
+	if opts.signaturesOnly {
+		content, _ = transform.Signatures(path, content)
+	}
+
+	if opts.stripComments {
+		content, _ = transform.StripComments(path, content)
+	}
+
+	// Minified files have nothing left for compact to do
+	if len(opts.minify) > 0 && opts.minifies(path) {
+		var minified bool
+		if content, minified = transform.Minify(path, content); minified {
+			return content
+		}
+	}
+
+	if opts.compact {
+		content = transform.Compact(path, content)
+	}
+
+	return content
+}
+
+// minifies reports whether the file at path is selected by --minify
+func (o options) minifies(path string) bool {
+	rel, err := filepath.Rel(o.root, path)
+	if err != nil {
+		rel = path
+	}
+
+	return o.minify.Match(filepath.ToSlash(rel))
+}
+
+// commandTransformer pipes the contents of every file through a shell
+// command, like prettier --stdin-filepath "$CONTEXT_GENERATOR_FILE",
+// using what it prints instead. Like for validators, the path of the
+// file is in CONTEXT_GENERATOR_FILE.
+type commandTransformer struct {
+	ctx     context.Context
+	command string
+	opts    options
+}
+
+func (c commandTransformer) Transform(path string, content []byte) ([]byte, bool) {
+	cmd := shellCommand(c.ctx, c.command)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "CONTEXT_GENERATOR_FILE="+path)
+
+	// A file the command can't handle, like one in a language it
+	// doesn't know, is better left as is than left out
+	out, err := cmd.Output()
+	if err != nil {
+		warnf(c.opts, "leaving %q as is, --transform-cmd %q failed: %s", path, c.command, err)
+		return content, false
+	}
+
+	return out, true
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Services a context can be uploaded to with --upload
+const (
+	uploadGist  = "gist"
+	uploadPaste = "paste"
+)
+
+// uploadTargetFlag is the value of the --upload flag. It implements
+// pflag.Value so unknown services are rejected while parsing flags.
+type uploadTargetFlag string
+
+func (u *uploadTargetFlag) String() string {
+	return string(*u)
+}
+
+func (u *uploadTargetFlag) Set(value string) error {
+	switch value {
+	case uploadGist, uploadPaste:
+		*u = uploadTargetFlag(value)
+		return nil
+	}
+
+	return fmt.Errorf("must be one of: %s, %s", uploadGist, uploadPaste)
+}
+
+func (u *uploadTargetFlag) Type() string {
+	return "service"
+}
+
+// uploadFileName returns the name a context in format is uploaded as, so
+// services can highlight it
+func uploadFileName(format string) string {
+	switch format {
+	case formatMarkdown:
+		return "context.md"
+	case formatJSON:
+		return "context.json"
+	case formatJSONL, formatChunksJSONL:
+		return "context.jsonl"
+	case formatHTML:
+		return "context.html"
+	default:
+		return "context.txt"
+	}
+}
+
+// uploader uploads contexts to the service picked with --upload,
+// configured from the environment
+type uploader struct {
+	target  uploadTargetFlag
+	address string
+	token   string
+}
+
+// newUploader returns an uploader for target, failing before anything is
+// scanned when the environment lacks what the service needs
+func newUploader(target uploadTargetFlag) (*uploader, error) {
+	switch target {
+	case uploadGist:
+		// GITHUB_API_URL points it at GitHub Enterprise instead
+		u := &uploader{
+			target:  target,
+			address: strings.TrimSuffix(envOr("https://api.github.com", "GITHUB_API_URL"), "/") + "/gists",
+			token:   envOr("", "GITHUB_TOKEN", "GH_TOKEN"),
+		}
+
+		if u.token == "" {
+			return nil, fmt.Errorf("set GITHUB_TOKEN or GH_TOKEN, with the gist scope, to upload to a gist")
+		}
+
+		return u, nil
+	case uploadPaste:
+		u := &uploader{target: target, address: envOr("", "PASTE_URL"), token: envOr("", "PASTE_TOKEN")}
+		if u.address == "" {
+			return nil, fmt.Errorf("set PASTE_URL to the address of the paste service to upload to")
+		}
+
+		return u, nil
+	default:
+		return nil, fmt.Errorf("unknown upload service %q", target)
+	}
+}
+
+// upload uploads the context in content, named name, returning the URL
+// it can be read at
+func (u *uploader) upload(ctx context.Context, name, description string, content []byte) (string, error) {
+	if u.target == uploadGist {
+		return u.gist(ctx, name, description, content)
+	}
+
+	return u.paste(ctx, content)
+}
+
+// gist creates a secret gist holding the context as the file name
+func (u *uploader) gist(ctx context.Context, name, description string, content []byte) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"description": description,
+		"public":      false,
+		"files": map[string]any{
+			name: map[string]string{"content": string(content)},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error encoding gist: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.address, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("error creating request to %q: %w", u.address, err)
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+u.token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error uploading to a gist: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("GitHub returned %s for %q: %s", resp.Status, u.address, strings.TrimSpace(string(msg)))
+	}
+
+	var out struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("error reading gist response from %q: %w", u.address, err)
+	}
+
+	if out.HTMLURL == "" {
+		return "", fmt.Errorf("GitHub returned no URL for the gist created at %q", u.address)
+	}
+
+	return out.HTMLURL, nil
+}
+
+// paste posts the context as is to the paste service, which answers with
+// the URL of the paste on its first line, like most services meant for
+// curl do. PASTE_TOKEN, when set, is sent as a bearer token.
+func (u *uploader) paste(ctx context.Context, content []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.address, bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("error creating request to %q: %w", u.address, err)
+	}
+
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if u.token != "" {
+		req.Header.Set("Authorization", "Bearer "+u.token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error uploading to %q: %w", u.address, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("paste service returned %s for %q: %s", resp.Status, u.address, strings.TrimSpace(string(msg)))
+	}
+
+	line, err := bufio.NewReader(io.LimitReader(resp.Body, 4096)).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("error reading response from %q: %w", u.address, err)
+	}
+
+	url := strings.TrimSpace(line)
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return "", fmt.Errorf("paste service at %q answered with %q instead of a URL", u.address, url)
+	}
+
+	return url, nil
+}
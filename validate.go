@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+
+	"github.com/patrickdappollonio/context-generator/filter"
+)
+
+// Actions taken when a file doesn't pass a validator
+const (
+	validatorRedact = "redact"
+	validatorSkip   = "skip"
+	validatorFail   = "fail"
+)
+
+// validatorConfig declares a validator in the configuration file
+type validatorConfig struct {
+	// Name identifies the validator in warnings, errors and redactions
+	Name string `yaml:"name"`
+
+	// Pattern is a regular expression the file must not match
+	Pattern string `yaml:"pattern"`
+
+	// Command is run through the shell with the file contents on stdin
+	// and must exit successfully for the file to pass
+	Command string `yaml:"command"`
+
+	// Action is what to do with files that don't pass: redact the
+	// matches, skip the file or fail the whole run
+	Action string `yaml:"action"`
+
+	// Paths limits the validator to files matching these gitignore-style
+	// patterns; when empty it applies to every file
+	Paths []string `yaml:"paths"`
+}
+
+// validator is a compiled validatorConfig
+type validator struct {
+	name    string
+	pattern *regexp.Regexp
+	command string
+	action  string
+	paths   filter.Patterns
+}
+
+// compileValidators checks and compiles the validators in the config
+func compileValidators(configs []validatorConfig) ([]validator, error) {
+	validators := make([]validator, 0, len(configs))
+
+	for i, c := range configs {
+		v := validator{name: c.Name, command: c.Command, action: c.Action}
+
+		if v.name == "" {
+			v.name = fmt.Sprintf("validator #%d", i+1)
+		}
+
+		if (c.Pattern == "") == (c.Command == "") {
+			return nil, fmt.Errorf("%s: exactly one of pattern or command must be set", v.name)
+		}
+
+		if c.Pattern != "" {
+			re, err := regexp.Compile(c.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern %q: %w", v.name, c.Pattern, err)
+			}
+
+			v.pattern = re
+		}
+
+		switch v.action {
+		case "":
+			v.action = validatorFail
+		case validatorSkip, validatorFail:
+		case validatorRedact:
+			if v.pattern == nil {
+				return nil, fmt.Errorf("%s: action %q needs a pattern to know what to redact", v.name, validatorRedact)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unknown action %q, must be one of: %s, %s, %s", v.name, v.action, validatorRedact, validatorSkip, validatorFail)
+		}
+
+		if len(c.Paths) > 0 {
+			paths, err := filter.ParsePatterns(c.Paths)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", v.name, err)
+			}
+
+			v.paths = paths
+		}
+
+		validators = append(validators, v)
+	}
+
+	return validators, nil
+}
+
+// applies reports whether the validator should check the file at rel,
+// a slash-separated path relative to the scan root
+func (v validator) applies(rel string) bool {
+	if len(v.paths) == 0 {
+		return true
+	}
+
+	return v.paths.Match(rel)
+}
+
+// check runs the validator against content, returning whether the file
+// passed and, for redacting validators, the redacted content
+func (v validator) check(ctx context.Context, path string, content []byte) ([]byte, bool, error) {
+	if v.pattern != nil {
+		if !v.pattern.Match(content) {
+			return content, true, nil
+		}
+
+		if v.action == validatorRedact {
+			return v.pattern.ReplaceAll(content, []byte("[REDACTED:"+v.name+"]")), false, nil
+		}
+
+		return content, false, nil
+	}
+
+	cmd := shellCommand(ctx, v.command)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "CONTEXT_GENERATOR_FILE="+path)
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return content, false, nil
+		}
+
+		return nil, false, fmt.Errorf("%s: error running %q: %w", v.name, v.command, err)
+	}
+
+	return content, true, nil
+}
+
+// validateContent runs every validator that applies to the file at path,
+// returning the possibly redacted content and whether to include the file
+func validateContent(ctx context.Context, root, path string, content []byte, opts options) ([]byte, bool, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, v := range opts.validators {
+		if !v.applies(rel) {
+			continue
+		}
+
+		checked, passed, err := v.check(ctx, path, content)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if passed {
+			continue
+		}
+
+		switch v.action {
+		case validatorRedact:
+			content = checked
+		case validatorSkip:
+			warnf(opts, "skipping %q: it didn't pass validator %q", path, v.name)
+			return nil, false, nil
+		default:
+			return nil, false, fmt.Errorf("file %q didn't pass validator %q", path, v.name)
+		}
+	}
+
+	return content, true, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// verifyArtifact scans a generated context with the secret and personal
+// information detectors. Artifacts that aren't contexts, like prompts
+// built around one, are scanned as a single plain file.
+func verifyArtifact(path string, skip []string) ([]finding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading artifact %q: %w", path, err)
+	}
+
+	_, files, err := parseContext(bytes.NewReader(data))
+	if err != nil || len(files) == 0 {
+		files = []contextFile{{manifestEntry: manifestEntry{Path: path}, Content: string(data)}}
+	}
+
+	var findings []finding
+	for _, f := range files {
+		lineNo := 0
+		eachLine(f.Content, func(line string) {
+			lineNo++
+
+			detectInLine(line, skip, func(name, masked string) {
+				findings = append(findings, finding{Path: f.Path, Line: lineNo, Detector: name, Excerpt: masked})
+			})
+		})
+	}
+
+	return findings, nil
+}
+
+// printFindings writes one line per finding to w
+func printFindings(w io.Writer, findings []finding) {
+	for _, f := range findings {
+		fmt.Fprintf(w, "%s:%d: %s: %s\n", f.Path, f.Line, f.Detector, f.Excerpt)
+	}
+}
+
+func newVerifyRedactionCommand() *cobra.Command {
+	var skip []string
+
+	cmd := &cobra.Command{
+		Use:   "verify-redaction artifact...",
+		Short: "Fail if a generated context still contains secrets or personal information",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, name := range skip {
+				if !contains(detectorNames(), name) {
+					return fmt.Errorf("unknown detector %q, must be one of: %s", name, strings.Join(detectorNames(), ", "))
+				}
+			}
+
+			total := 0
+			for _, path := range args {
+				findings, err := verifyArtifact(path, skip)
+				if err != nil {
+					return err
+				}
+
+				printFindings(cmd.OutOrStdout(), findings)
+				total += len(findings)
+			}
+
+			if total > 0 {
+				return fmt.Errorf("found %d potential secrets or personal information in the generated output", total)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&skip, "skip-detector", nil, "detectors to skip, from: "+strings.Join(detectorNames(), ", "))
+
+	return cmd
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// Build information, set when building releases with:
+//
+//	go build -ldflags "-X main.version=1.2.3 -X main.commit=abc1234 -X main.date=2024-01-02T03:04:05Z"
+//
+// Builds without them, like those made with go install, fall back to
+// what the Go toolchain recorded in the binary.
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+// buildInfo describes the binary, for packaging and bug reports
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// readBuildInfo returns the build information of the running binary
+func readBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.Date == "":
+				info.Date = s.Value
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+
+	return info
+}
+
+func newVersionCommand() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version, commit, build date, Go version and platform of this binary",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := readBuildInfo()
+
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(info)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%s %s\n", getAppName(), info.Version)
+			if info.Commit != "" {
+				fmt.Fprintf(cmd.OutOrStdout(), "commit: %s\n", info.Commit)
+			}
+			if info.Date != "" {
+				fmt.Fprintf(cmd.OutOrStdout(), "built: %s\n", info.Date)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "go: %s\n", info.GoVersion)
+			fmt.Fprintf(cmd.OutOrStdout(), "platform: %s\n", info.Platform)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the build information as JSON")
+
+	return cmd
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/patrickdappollonio/context-generator/filter"
+)
+
+// restrictWalk loads the sets of files the walk of root is restricted
+// to, like the files git tracks or those reachable from entry points
+func (o *options) restrictWalk(ctx context.Context, root string) error {
+	if err := o.loadTracked(ctx, root); err != nil {
+		return err
+	}
+
+	if err := o.loadCodeOwners(root); err != nil {
+		return err
+	}
+
+	return o.loadReachable(root)
+}
+
+// loadCodeOwners reads the CODEOWNERS file of root when --codeowners is
+// set, so the walk only includes the files of the given owner
+func (o *options) loadCodeOwners(root string) error {
+	if o.filters.Owner == "" || o.filters.CodeOwners != nil {
+		return nil
+	}
+
+	fsys := o.filters.FS
+	if fsys == nil {
+		fsys = os.DirFS(root)
+	}
+
+	for _, name := range filter.CodeOwnersPaths {
+		f, err := fsys.Open(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error opening %q: %w", name, err)
+		}
+
+		owners, err := filter.ParseCodeOwners(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("error reading %q: %w", name, err)
+		}
+
+		o.filters.CodeOwners = owners
+		return nil
+	}
+
+	return fmt.Errorf("flag --codeowners requires a CODEOWNERS file in %s", strings.Join(filter.CodeOwnersPaths, ", "))
+}
+
+// loadReachable restricts the walk of root to the files reachable from
+// the entry points of --follow-imports or --entry, when set
+func (o *options) loadReachable(root string) error {
+	if (len(o.followImports) == 0 && len(o.entries) == 0) || o.filters.Reachable != nil {
+		return nil
+	}
+
+	fsys, onDisk := o.filters.FS, false
+	if fsys == nil {
+		fsys, onDisk = os.DirFS(root), true
+	}
+
+	var (
+		files []string
+		err   error
+	)
+	if len(o.followImports) > 0 {
+		files, err = followGoImports(fsys, root, onDisk, o.followImports)
+		o.filters.ReachableFrom = "--follow-imports=" + strings.Join(o.followImports, ",")
+	} else {
+		files, err = followJSImports(fsys, root, o.entries)
+		o.filters.ReachableFrom = "--entry=" + strings.Join(o.entries, ",")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	o.filters.Reachable = filter.NewPathSet(files)
+	return nil
+}
+
+// walkFiles walks root and calls fn for every file that passes the
+// exclusion filters in opts, reporting what the filters left out
+func walkFiles(ctx context.Context, root string, opts options, fn func(path string, info os.FileInfo) error) (*filter.Report, error) {
+	if err := opts.restrictWalk(ctx, root); err != nil {
+		return nil, err
+	}
+
+	return filter.Walk(ctx, root, opts.filters, fn)
+}
+
+// skippedFiles collects the files left out by --skip-errors because they
+// couldn't be read, on top of the paths the walk itself skipped
+type skippedFiles []filter.Note
+
+// skip records path as skipped when err comes from reading it and errors
+// can be skipped, returning err otherwise so the run fails
+func (s *skippedFiles) skip(opts options, path string, err error) error {
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		return err
+	}
+
+	if !opts.filters.SkipErrors {
+		return filter.Unreadable(err)
+	}
+
+	*s = append(*s, filter.Note{Path: path, Reason: pathErr.Err.Error()})
+	return nil
+}
+
+// walkOrderLess reports whether path a comes before path b in the order
+// a walk visits them by default
+func walkOrderLess(a, b string) bool {
+	return filter.Options{}.Less(a, b)
+}
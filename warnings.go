@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/patrickdappollonio/context-generator/filter"
+)
+
+// warnf prints a warning to stderr, unless warnings are disabled
+func warnf(opts options, format string, args ...any) {
+	// Record it for the summary even when it isn't printed
+	opts.summary.warn(fmt.Sprintf(format, args...))
+
+	if opts.noWarnings {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+}
+
+// warnUnmatched warns about exclusions given by the user that didn't match
+// anything, which usually means they have a typo or meant a pattern
+func warnUnmatched(opts options, excluded filter.Exclusions) {
+	if opts.explicitFolderNames {
+		for _, name := range opts.filters.ExcludeFolders {
+			if excluded.Count("exclude-folder", name) == 0 {
+				warnf(opts, "--exclude-folder %q didn't match any folder%s", name, patternHint(name))
+			}
+		}
+	}
+
+	if opts.explicitFileNames {
+		for _, name := range opts.filters.ExcludeFiles {
+			if excluded.Count("exclude-file", name) == 0 {
+				warnf(opts, "--exclude-file %q didn't match any file%s", name, patternHint(name))
+			}
+		}
+	}
+}
+
+// patternHint points users giving a path or a glob to a flag taking
+// plain names to --exclude, which takes patterns
+func patternHint(name string) string {
+	if !filter.IsGlob(name) && !strings.Contains(name, "/") {
+		return ""
+	}
+
+	return fmt.Sprintf(", it only matches names; use --exclude %q for paths and patterns", name)
+}
+
+// warnSkipped warns about every path left out because it couldn't be
+// read, once the walk is over so they aren't lost among its output
+func warnSkipped(opts options, skipped ...[]filter.Note) {
+	for _, notes := range skipped {
+		for _, n := range notes {
+			warnf(opts, "skipped %q, it couldn't be read: %s", n.Path, n.Reason)
+		}
+	}
+}
+
+// exclusionSummaryTitle starts the list of exclusions appended to a
+// context by --with-exclusion-summary
+const exclusionSummaryTitle = "Excluded from this context:"
+
+// contentExclusion is the key files left out after being read, rather
+// than by their path, are counted under
+const contentExclusion = "content checks (binary, rejected by a validator or changed while read)"
+
+// writeExclusionSummary writes how many paths each exclusion left out,
+// the most common ones first, so readers of the context know what they
+// aren't seeing. Excluded directories count as a single path.
+func writeExclusionSummary(w io.Writer, format string, excluded filter.Exclusions) error {
+	if format == formatMarkdown {
+		fmt.Fprintf(w, "\n## %s\n\n", strings.TrimSuffix(exclusionSummaryTitle, ":"))
+	} else {
+		fmt.Fprintln(w, exclusionSummaryTitle)
+	}
+
+	return writeExclusionList(w, excluded)
+}
+
+// writeExclusionList writes how many paths each exclusion left out as a
+// list, the most common ones first
+func writeExclusionList(w io.Writer, excluded filter.Exclusions) error {
+	keys := make([]string, 0, len(excluded))
+	for key, count := range excluded {
+		if count > 0 {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if excluded[keys[i]] != excluded[keys[j]] {
+			return excluded[keys[i]] > excluded[keys[j]]
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	if len(keys) == 0 {
+		_, err := fmt.Fprintln(w, "- nothing")
+		return err
+	}
+
+	for _, key := range keys {
+		noun := "paths"
+		if excluded[key] == 1 {
+			noun = "path"
+		}
+
+		if _, err := fmt.Fprintf(w, "- %s: %d %s\n", key, excluded[key], noun); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
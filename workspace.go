@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+// workspaceRoot is one of the directories of a multi-root workspace, set
+// in the roots section of the configuration file, like the repositories
+// of a polyrepo checked out side by side
+type workspaceRoot struct {
+	// Path is the directory, relative to the configuration file
+	Path string `yaml:"path"`
+
+	// Prefix replaces the directory in the paths of its files, like api
+	// for api/cmd/main.go; without one, paths start with the directory
+	Prefix string `yaml:"prefix"`
+
+	// Include only includes the files matching these globs, relative to
+	// the directory, like **/*.go
+	Include []string `yaml:"include"`
+
+	// Exclude leaves out the paths matching these gitignore-style
+	// patterns, relative to the directory, on top of --exclude
+	Exclude []string `yaml:"exclude"`
+}
+
+// setWorkspace resolves the roots of the configuration file found in
+// base, checking every directory exists and no two share a prefix
+func (o *options) setWorkspace(base string, roots []workspaceRoot) error {
+	if len(roots) == 0 {
+		return nil
+	}
+
+	// Revisions, archives and lists of files all stand in for a single
+	// directory
+	if o.filters.FS != nil || o.filters.Files != nil {
+		return fmt.Errorf("the roots of the config file can't be used with --rev, --from-archive or --files-from")
+	}
+
+	if len(o.filters.Globs) > 0 {
+		return fmt.Errorf("the roots of the config file can't be used with globs, set include patterns for each root instead")
+	}
+
+	prefixes := make(map[string]string)
+	for i, r := range roots {
+		if r.Path == "" {
+			return fmt.Errorf("root %d of the config file has no path", i+1)
+		}
+
+		if !filepath.IsAbs(r.Path) {
+			r.Path = filepath.Join(base, r.Path)
+		}
+
+		dir, err := checkRoot(r.Path)
+		if err != nil {
+			return fmt.Errorf("invalid root in config file: %w", err)
+		}
+		r.Path = dir
+
+		if r.Prefix != "" {
+			if other, found := prefixes[r.Prefix]; found {
+				return fmt.Errorf("roots %q and %q of the config file share the prefix %q", other, dir, r.Prefix)
+			}
+
+			prefixes[r.Prefix] = dir
+		}
+
+		o.workspace = append(o.workspace, r)
+	}
+
+	return nil
+}
+
+// walkRoots returns the options to walk each directory of the context
+// with, after restricting their walks: the scanned directory alone, or
+// every root of the workspace with its own rules
+func (o options) walkRoots(ctx context.Context) ([]options, error) {
+	if len(o.workspace) == 0 {
+		if err := o.restrictWalk(ctx, o.root); err != nil {
+			return nil, err
+		}
+
+		return []options{o}, nil
+	}
+
+	roots := make([]options, 0, len(o.workspace))
+	for _, r := range o.workspace {
+		ro := o
+		ro.root = r.Path
+		ro.rootPrefix = r.Prefix
+		ro.filters.Globs = r.Include
+		ro.filters.Exclude = append(slices.Clone(o.filters.Exclude), r.Exclude...)
+
+		if err := ro.restrictWalk(ctx, ro.root); err != nil {
+			return nil, fmt.Errorf("error preparing root %q: %w", r.Path, err)
+		}
+
+		roots = append(roots, ro)
+	}
+
+	return roots, nil
+}
+
+// contextRoot returns the directory the paths of the files walked with o
+// are grouped under: the walked directory, or the workspace itself when
+// paths start with the prefix of their root instead
+func (o options) contextRoot() string {
+	if o.rootPrefix != "" {
+		return "."
+	}
+
+	return o.root
+}